@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// fotosPublicacion arma las fotos de una publicación: primero su imagen, sin
+// descripción propia, y luego la de cada producto en el orden de la
+// publicación, con el nombre y el precio como descripción. Las URLs repetidas
+// y los productos sin imagen se omiten.
+func fotosPublicacion(publicacion Publicacion, productos []Producto) []FotoPublicacion {
+	porID := make(map[primitive.ObjectID]Producto, len(productos))
+	for _, producto := range productos {
+		porID[producto.ID] = producto
+	}
+
+	var fotos []FotoPublicacion
+	vistas := make(map[string]bool)
+	agregar := func(url, caption string) {
+		if url == "" || vistas[url] {
+			return
+		}
+		vistas[url] = true
+		fotos = append(fotos, FotoPublicacion{URL: url, Caption: caption})
+	}
+
+	agregar(publicacion.ImagenURL, "")
+	for _, p := range publicacion.Productos {
+		if producto, ok := porID[p.ProductoID]; ok {
+			agregar(producto.ImagenURL, captionProducto(producto))
+		}
+	}
+
+	return fotos
+}
+
+// captionProducto describe un producto en la foto de un álbum
+func captionProducto(producto Producto) string {
+	return fmt.Sprintf("%s - %s", producto.Nombre, formatearPrecio(producto.Precio))
+}
+
+// formatearPrecio muestra los precios enteros sin decimales
+func formatearPrecio(precio float64) string {
+	if precio == math.Trunc(precio) {
+		return fmt.Sprintf("$%.0f", precio)
+	}
+	return fmt.Sprintf("$%.2f", precio)
+}
+
+// productosDe obtiene los productos de una publicación
+func (s *SchedulerService) productosDe(publicacion Publicacion) ([]Producto, error) {
+	if len(publicacion.Productos) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]primitive.ObjectID, len(publicacion.Productos))
+	for i, p := range publicacion.Productos {
+		ids[i] = p.ProductoID
+	}
+	return s.productos.FindByIDs(ids)
+}
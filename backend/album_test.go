@@ -0,0 +1,96 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestFotosPublicacion(t *testing.T) {
+	remera := Producto{ID: primitive.NewObjectID(), Nombre: "Remera", Precio: 2500, ImagenURL: "https://example.com/remera.jpg"}
+	gorra := Producto{ID: primitive.NewObjectID(), Nombre: "Gorra", Precio: 1999.5, ImagenURL: "https://example.com/gorra.jpg"}
+	sinImagen := Producto{ID: primitive.NewObjectID(), Nombre: "Medias", Precio: 800}
+	productos := []Producto{remera, gorra, sinImagen}
+
+	items := func(ps ...Producto) []PublicacionProducto {
+		var items []PublicacionProducto
+		for _, p := range ps {
+			items = append(items, PublicacionProducto{ProductoID: p.ID, Cantidad: 1})
+		}
+		return items
+	}
+
+	casos := []struct {
+		nombre      string
+		publicacion Publicacion
+		fotos       []FotoPublicacion
+	}{
+		{nombre: "sin imágenes", publicacion: Publicacion{Productos: items(sinImagen)}},
+		{
+			nombre:      "solo la imagen de la publicación",
+			publicacion: Publicacion{ImagenURL: "https://example.com/portada.jpg"},
+			fotos:       []FotoPublicacion{{URL: "https://example.com/portada.jpg"}},
+		},
+		{
+			nombre:      "portada y productos en orden",
+			publicacion: Publicacion{ImagenURL: "https://example.com/portada.jpg", Productos: items(gorra, sinImagen, remera)},
+			fotos: []FotoPublicacion{
+				{URL: "https://example.com/portada.jpg"},
+				{URL: gorra.ImagenURL, Caption: "Gorra - $1999.50"},
+				{URL: remera.ImagenURL, Caption: "Remera - $2500"},
+			},
+		},
+		{
+			nombre:      "imagen repetida",
+			publicacion: Publicacion{ImagenURL: remera.ImagenURL, Productos: items(remera, gorra)},
+			fotos: []FotoPublicacion{
+				{URL: remera.ImagenURL},
+				{URL: gorra.ImagenURL, Caption: "Gorra - $1999.50"},
+			},
+		},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			fotos := fotosPublicacion(caso.publicacion, productos)
+			if !reflect.DeepEqual(fotos, caso.fotos) {
+				t.Errorf("fotos %v, se esperaban %v", fotos, caso.fotos)
+			}
+		})
+	}
+}
+
+func TestSchedulerPublicaAlbumDeProductos(t *testing.T) {
+	inicio := time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC)
+	sim := nuevaSimulacion(t, inicio, 1)
+
+	remera := Producto{ID: primitive.NewObjectID(), Nombre: "Remera", Precio: 2500, ImagenURL: "https://example.com/remera.jpg"}
+	gorra := Producto{ID: primitive.NewObjectID(), Nombre: "Gorra", Precio: 1800, ImagenURL: "https://example.com/gorra.jpg"}
+	sim.productos.datos[remera.ID] = remera
+	sim.productos.datos[gorra.ID] = gorra
+	sim.publicacion.Productos = []PublicacionProducto{{ProductoID: remera.ID, Cantidad: 1}, {ProductoID: gorra.ID, Cantidad: 1}}
+	sim.publicaciones.datos[sim.publicacion.ID] = sim.publicacion
+
+	id := sim.programar(ProgramacionPublicacion{Frecuencia: "diaria", FechaInicio: inicio})
+	if _, err := sim.scheduler.RunNow(id); err != nil {
+		t.Fatalf("RunNow: %v", err)
+	}
+	sim.vaciarCola()
+
+	if len(sim.facebook.publicaciones) != 1 {
+		t.Fatalf("se publicó %d veces, se esperaba 1", len(sim.facebook.publicaciones))
+	}
+	publicada := sim.facebook.publicaciones[0]
+	esperadas := []FotoPublicacion{
+		{URL: remera.ImagenURL, Caption: "Remera - $2500"},
+		{URL: gorra.ImagenURL, Caption: "Gorra - $1800"},
+	}
+	if !reflect.DeepEqual(publicada.fotos, esperadas) {
+		t.Errorf("fotos del álbum %v, se esperaban %v", publicada.fotos, esperadas)
+	}
+	if publicada.mensaje != "Oferta\n\nDescripción" {
+		t.Errorf("mensaje del álbum %q", publicada.mensaje)
+	}
+}
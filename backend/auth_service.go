@@ -99,14 +99,15 @@ func (a *AuthService) CreateUser(req RegisterRequest) (*Usuario, error) {
 
 	// Crear usuario
 	usuario := Usuario{
-		ID:        primitive.NewObjectID(),
-		Email:     req.Email,
-		Password:  hashedPassword,
-		Nombre:    req.Nombre,
-		Activo:    true,
-		Role:      "user",
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		ID:          primitive.NewObjectID(),
+		Email:       req.Email,
+		Password:    hashedPassword,
+		Nombre:      req.Nombre,
+		ZonaHoraria: req.ZonaHoraria,
+		Activo:      true,
+		Role:        "user",
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
 	}
 
 	_, err = a.userCollection.InsertOne(context.Background(), usuario)
@@ -177,3 +178,67 @@ func (a *AuthService) UpdateFacebookTokens(userID, facebookUserID, accessToken s
 	_, err = a.userCollection.UpdateOne(context.Background(), bson.M{"_id": objectID}, update)
 	return err
 }
+
+// ExpireFacebookToken marca vencido el token de Facebook del usuario; el
+// usuario sigue conectado pero tiene que volver a autorizar la aplicación
+func (a *AuthService) ExpireFacebookToken(userID string, now time.Time) error {
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return err
+	}
+
+	update := bson.M{
+		"$set": bson.M{
+			"token_expiracion": now,
+			"updated_at":       now,
+		},
+	}
+
+	_, err = a.userCollection.UpdateOne(context.Background(), bson.M{"_id": objectID}, update)
+	return err
+}
+
+// FindExpiringFacebookTokens devuelve los usuarios con Facebook conectado
+// cuyo token vence antes de hasta
+func (a *AuthService) FindExpiringFacebookTokens(hasta time.Time) ([]Usuario, error) {
+	filtro := bson.M{
+		"facebook_access_token": bson.M{"$exists": true, "$ne": ""},
+		"token_expiracion":      bson.M{"$lt": hasta},
+	}
+
+	cursor, err := a.userCollection.Find(context.Background(), filtro)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	var usuarios []Usuario
+	if err := cursor.All(context.Background(), &usuarios); err != nil {
+		return nil, err
+	}
+	return usuarios, nil
+}
+
+// UpdateProfile actualiza el nombre, la zona horaria y las restricciones de publicación del usuario
+func (a *AuthService) UpdateProfile(userID string, req UpdateProfileRequest) error {
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return err
+	}
+
+	campos := bson.M{
+		"updated_at": time.Now(),
+	}
+	if req.Nombre != "" {
+		campos["nombre"] = req.Nombre
+	}
+	if req.ZonaHoraria != nil {
+		campos["zona_horaria"] = *req.ZonaHoraria
+	}
+	if req.Restricciones != nil {
+		campos["restricciones"] = *req.Restricciones
+	}
+
+	_, err = a.userCollection.UpdateOne(context.Background(), bson.M{"_id": objectID}, bson.M{"$set": campos})
+	return err
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Estados de una ejecución
+const (
+	EjecucionEnCurso = "en_curso"
+	EjecucionExitosa = "exitosa"
+	EjecucionParcial = "parcial" // Algunos grupos fallaron
+	EjecucionFallida = "fallida"
+	EjecucionOmitida = "omitida"
+)
+
+// Estados del resultado de un grupo dentro de una ejecución
+const (
+	ResultadoPendiente = "pendiente"
+	ResultadoExitoso   = "exitosa"
+	ResultadoFallido   = "fallida"
+	ResultadoOmitido   = "omitida" // Descartado por un límite de publicación
+)
+
+// RunLedger es el registro de ejecuciones del scheduler: un documento por
+// horario planificado de cada programación con el resultado en cada grupo.
+// El scheduler lo usa para saber qué horarios ya ejecutó y cuántas
+// publicaciones exitosas lleva cada programación.
+type RunLedger struct {
+	collection *mongo.Collection
+	clock      Clock
+}
+
+func NewRunLedger(clock Clock) *RunLedger {
+	l := &RunLedger{collection: database.Collection("ejecuciones"), clock: clock}
+	l.ensureIndexes()
+	return l
+}
+
+// ensureIndexes crea el índice único que impide registrar dos veces el mismo horario
+func (l *RunLedger) ensureIndexes() {
+	index := mongo.IndexModel{
+		Keys:    bson.D{{Key: "programacion_id", Value: 1}, {Key: "fecha_programada", Value: -1}},
+		Options: options.Index().SetUnique(true),
+	}
+
+	if _, err := l.collection.Indexes().CreateOne(context.Background(), index); err != nil {
+		log.Printf("Error creando índices de ejecuciones: %v", err)
+	}
+}
+
+// Start registra el inicio de la ejecución de un horario con sus grupos
+// pendientes. Devuelve false si el horario ya estaba registrado. Las
+// ejecuciones manuales se registran igual pero no avanzan la recurrencia.
+func (l *RunLedger) Start(prog ProgramacionPublicacion, horario time.Time, grupos []GrupoFacebook, manual bool) (bool, error) {
+	now := l.clock.Now()
+
+	ejecucion := Ejecucion{
+		ID:              primitive.NewObjectID(),
+		ProgramacionID:  prog.ID,
+		PublicacionID:   prog.PublicacionID,
+		FechaProgramada: horario,
+		InicioReal:      now,
+		Estado:          EjecucionEnCurso,
+		Grupos:          make([]ResultadoGrupo, len(grupos)),
+		Manual:          manual,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	for i, grupo := range grupos {
+		ejecucion.Grupos[i] = ResultadoGrupo{GrupoID: grupo.ID, Estado: ResultadoPendiente}
+	}
+
+	if len(grupos) == 0 {
+		ejecucion.Estado = EjecucionFallida
+		ejecucion.Motivo = "la programación no tiene grupos objetivo"
+		ejecucion.FinReal = &now
+	}
+
+	return l.insert(ejecucion)
+}
+
+// Skip registra un horario que no se ejecutará. Devuelve false si el horario
+// ya estaba registrado.
+func (l *RunLedger) Skip(prog ProgramacionPublicacion, horario time.Time, motivo string) (bool, error) {
+	now := l.clock.Now()
+
+	ejecucion := Ejecucion{
+		ID:              primitive.NewObjectID(),
+		ProgramacionID:  prog.ID,
+		PublicacionID:   prog.PublicacionID,
+		FechaProgramada: horario,
+		InicioReal:      now,
+		FinReal:         &now,
+		Estado:          EjecucionOmitida,
+		Motivo:          motivo,
+		Grupos:          []ResultadoGrupo{},
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+
+	return l.insert(ejecucion)
+}
+
+// insert guarda una ejecución; devuelve false si su horario ya estaba registrado
+func (l *RunLedger) insert(ejecucion Ejecucion) (bool, error) {
+	_, err := l.collection.InsertOne(context.Background(), ejecucion)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// RecordGroupResult guarda el resultado final de un grupo y, cuando ya no
+// quedan grupos pendientes, cierra la ejecución con su estado global
+func (l *RunLedger) RecordGroupResult(trabajo TrabajoPublicacion, postID string, postErr error) error {
+	now := l.clock.Now()
+
+	resultado := ResultadoGrupo{
+		GrupoID:          trabajo.GrupoID,
+		Estado:           ResultadoExitoso,
+		FacebookPostID:   postID,
+		Intentos:         trabajo.Intentos,
+		FechaPublicacion: &now,
+	}
+	var omitido *errorOmitido
+	switch {
+	case errors.As(postErr, &omitido):
+		resultado.Estado = ResultadoOmitido
+		resultado.MensajeError = omitido.motivo
+		resultado.FechaPublicacion = nil
+	case postErr != nil:
+		resultado.Estado = ResultadoFallido
+		resultado.MensajeError = postErr.Error()
+	}
+
+	filter := bson.M{
+		"programacion_id":  trabajo.ProgramacionID,
+		"fecha_programada": trabajo.FechaProgramada,
+		"grupos.grupo_id":  trabajo.GrupoID,
+	}
+	update := bson.M{"$set": bson.M{"grupos.$": resultado, "updated_at": now}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var ejecucion Ejecucion
+	err := l.collection.FindOneAndUpdate(context.Background(), filter, update, opts).Decode(&ejecucion)
+	if err != nil {
+		return err
+	}
+
+	estado := estadoEjecucion(ejecucion.Grupos)
+	if estado == EjecucionEnCurso {
+		return nil
+	}
+
+	_, err = l.collection.UpdateOne(context.Background(),
+		bson.M{"_id": ejecucion.ID, "estado": EjecucionEnCurso},
+		bson.M{"$set": bson.M{"estado": estado, "fin_real": now, "updated_at": now}},
+	)
+	return err
+}
+
+// estadoEjecucion calcula el estado global a partir de los resultados por
+// grupo; los grupos omitidos por límites no cuentan como fallidos
+func estadoEjecucion(grupos []ResultadoGrupo) string {
+	exitosos, fallidos := 0, 0
+	for _, g := range grupos {
+		switch g.Estado {
+		case ResultadoPendiente:
+			return EjecucionEnCurso
+		case ResultadoExitoso:
+			exitosos++
+		case ResultadoOmitido:
+		default:
+			fallidos++
+		}
+	}
+
+	switch {
+	case exitosos == 0 && fallidos == 0:
+		return EjecucionOmitida
+	case fallidos == 0:
+		return EjecucionExitosa
+	case exitosos == 0:
+		return EjecucionFallida
+	default:
+		return EjecucionParcial
+	}
+}
+
+// Last devuelve la ejecución planificada con el horario más reciente de una
+// programación, o nil si nunca se ejecutó. Las ejecuciones manuales se ignoran.
+func (l *RunLedger) Last(programacionID primitive.ObjectID) (*Ejecucion, error) {
+	filter := bson.M{"programacion_id": programacionID, "manual": bson.M{"$ne": true}}
+	opts := options.FindOne().SetSort(bson.D{{Key: "fecha_programada", Value: -1}})
+
+	var ejecucion Ejecucion
+	err := l.collection.FindOne(context.Background(), filter, opts).Decode(&ejecucion)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &ejecucion, nil
+}
+
+// Get devuelve la ejecución de un horario de la programación
+func (l *RunLedger) Get(programacionID primitive.ObjectID, horario time.Time) (*Ejecucion, error) {
+	filter := bson.M{"programacion_id": programacionID, "fecha_programada": horario}
+
+	var ejecucion Ejecucion
+	if err := l.collection.FindOne(context.Background(), filter).Decode(&ejecucion); err != nil {
+		return nil, err
+	}
+
+	return &ejecucion, nil
+}
+
+// RecentPublicaciones devuelve las publicaciones de las últimas ejecuciones
+// no omitidas, de la más reciente a la más antigua
+func (l *RunLedger) RecentPublicaciones(programacionID primitive.ObjectID, limite int) ([]primitive.ObjectID, error) {
+	filter := bson.M{"programacion_id": programacionID, "estado": bson.M{"$ne": EjecucionOmitida}}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "fecha_programada", Value: -1}}).
+		SetLimit(int64(limite)).
+		SetProjection(bson.M{"publicacion_id": 1})
+
+	cursor, err := l.collection.Find(context.Background(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	var ejecuciones []Ejecucion
+	if err := cursor.All(context.Background(), &ejecuciones); err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, len(ejecuciones))
+	for i, e := range ejecuciones {
+		ids[i] = e.PublicacionID
+	}
+	return ids, nil
+}
+
+// CountPublished cuenta las ejecuciones no omitidas de una programación,
+// incluidas las manuales
+func (l *RunLedger) CountPublished(programacionID primitive.ObjectID) (int, error) {
+	filter := bson.M{"programacion_id": programacionID, "estado": bson.M{"$ne": EjecucionOmitida}}
+
+	total, err := l.collection.CountDocuments(context.Background(), filter)
+	return int(total), err
+}
+
+// CountInProgress cuenta las ejecuciones planificadas de una programación que
+// siguen en curso; pueden estar esperando un reintento o un diferimiento
+func (l *RunLedger) CountInProgress(programacionID primitive.ObjectID) (int, error) {
+	filter := bson.M{"programacion_id": programacionID, "estado": EjecucionEnCurso, "manual": bson.M{"$ne": true}}
+
+	total, err := l.collection.CountDocuments(context.Background(), filter)
+	return int(total), err
+}
+
+// CountSuccessful cuenta las ejecuciones exitosas de cada programación
+func (l *RunLedger) CountSuccessful(ids []primitive.ObjectID) (map[primitive.ObjectID]int, error) {
+	return contarEjecucionesExitosas(l.collection, ids)
+}
+
+// contarEjecucionesExitosas cuenta, por programación, las ejecuciones
+// planificadas en las que al menos un grupo recibió la publicación
+// correctamente; las manuales no consumen la cantidad de publicaciones
+func contarEjecucionesExitosas(collection *mongo.Collection, ids []primitive.ObjectID) (map[primitive.ObjectID]int, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"programacion_id": bson.M{"$in": ids},
+			"estado":          bson.M{"$in": []string{EjecucionExitosa, EjecucionParcial}},
+			"manual":          bson.M{"$ne": true},
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   "$programacion_id",
+			"total": bson.M{"$sum": 1},
+		}}},
+	}
+
+	cursor, err := collection.Aggregate(context.Background(), pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	var resultados []struct {
+		ID    primitive.ObjectID `bson:"_id"`
+		Total int                `bson:"total"`
+	}
+	if err := cursor.All(context.Background(), &resultados); err != nil {
+		return nil, err
+	}
+
+	conteos := make(map[primitive.ObjectID]int, len(resultados))
+	for _, r := range resultados {
+		conteos[r.ID] = r.Total
+	}
+
+	return conteos, nil
+}
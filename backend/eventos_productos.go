@@ -0,0 +1,257 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Tipos de eventos de productos que pueden disparar programaciones
+const (
+	EventoReposicion = "reposicion"  // El stock pasó de 0 a positivo
+	EventoBajaPrecio = "baja_precio" // El precio bajó
+)
+
+// Estados de un evento de producto
+const (
+	EventoPendiente = "pendiente"
+	EventoProcesado = "procesado"
+)
+
+// eventosPorCiclo limita los eventos procesados en cada ciclo del scheduler
+const eventosPorCiclo = 100
+
+// ErrProgramacionPorEventos indica una acción sobre horarios pedida a una
+// programación que publica ante eventos de productos
+var ErrProgramacionPorEventos = errors.New("la programación publica ante eventos de productos y no tiene horarios")
+
+// detectarEventosProducto compara un producto antes y después de
+// actualizarlo y devuelve los eventos que generó el cambio
+func detectarEventosProducto(anterior, actual Producto) []EventoProducto {
+	var tipos []string
+	if anterior.Stock <= 0 && actual.Stock > 0 {
+		tipos = append(tipos, EventoReposicion)
+	}
+	if actual.Precio < anterior.Precio {
+		tipos = append(tipos, EventoBajaPrecio)
+	}
+
+	// Los eventos de una misma actualización comparten la fecha, de modo que
+	// una programación que escucha ambos publica una sola vez
+	eventos := make([]EventoProducto, len(tipos))
+	for i, tipo := range tipos {
+		eventos[i] = EventoProducto{
+			ID:             primitive.NewObjectID(),
+			ProductoID:     anterior.ID,
+			Tipo:           tipo,
+			StockAnterior:  anterior.Stock,
+			StockNuevo:     actual.Stock,
+			PrecioAnterior: anterior.Precio,
+			PrecioNuevo:    actual.Precio,
+			Estado:         EventoPendiente,
+			CreatedAt:      actual.UpdatedAt,
+		}
+	}
+	return eventos
+}
+
+// validarDisparador verifica los eventos y la baja mínima de un disparador
+func validarDisparador(d *DisparadorEventos) error {
+	if len(d.Eventos) == 0 {
+		return errors.New("el disparador debe indicar al menos un evento")
+	}
+
+	for _, evento := range d.Eventos {
+		switch evento {
+		case EventoReposicion, EventoBajaPrecio:
+		default:
+			return fmt.Errorf("evento desconocido %q: use reposicion o baja_precio", evento)
+		}
+	}
+
+	if d.BajaMinimaPorcentaje < 0 || d.BajaMinimaPorcentaje > 100 {
+		return errors.New("la baja mínima de precio debe estar entre 0 y 100")
+	}
+
+	return nil
+}
+
+// coincide indica si el evento dispara la programación; no verifica que el
+// producto esté en sus publicaciones
+func (d *DisparadorEventos) coincide(evento EventoProducto) bool {
+	escucha := false
+	for _, tipo := range d.Eventos {
+		if tipo == evento.Tipo {
+			escucha = true
+			break
+		}
+	}
+	if !escucha {
+		return false
+	}
+
+	if len(d.ProductoIDs) > 0 && !contieneID(d.ProductoIDs, evento.ProductoID) {
+		return false
+	}
+
+	if evento.Tipo == EventoBajaPrecio && d.BajaMinimaPorcentaje > 0 && evento.PrecioAnterior > 0 {
+		baja := (evento.PrecioAnterior - evento.PrecioNuevo) / evento.PrecioAnterior * 100
+		if baja < d.BajaMinimaPorcentaje {
+			return false
+		}
+	}
+
+	return true
+}
+
+// contieneID indica si id está en ids
+func contieneID(ids []primitive.ObjectID, id primitive.ObjectID) bool {
+	for _, otro := range ids {
+		if otro == id {
+			return true
+		}
+	}
+	return false
+}
+
+// PublishProductEvents guarda los eventos de un producto para que el
+// scheduler los procese y despierta al scheduler de esta instancia. Si la
+// líder es otra instancia, los toma en su siguiente tick.
+func (s *SchedulerService) PublishProductEvents(eventos []EventoProducto) error {
+	if len(eventos) == 0 {
+		return nil
+	}
+
+	if err := s.eventos.Insert(eventos...); err != nil {
+		return err
+	}
+
+	select {
+	case s.eventosChan <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// ProcessEvents procesa los eventos de productos pendientes sin esperar al
+// siguiente tick
+func (s *SchedulerService) ProcessEvents() {
+	s.tickMu.Lock()
+	defer s.tickMu.Unlock()
+
+	s.processEvents()
+}
+
+// processEvents encola las publicaciones que disparan los eventos de
+// productos pendientes. Un evento se marca procesado después de encolar sus
+// publicaciones; si el proceso se cae antes, se vuelve a procesar y las
+// ejecuciones ya registradas no se duplican.
+func (s *SchedulerService) processEvents() {
+	eventos, err := s.eventos.Pending(eventosPorCiclo)
+	if err != nil {
+		log.Printf("Error obteniendo eventos de productos: %v", err)
+		return
+	}
+	if len(eventos) == 0 {
+		return
+	}
+
+	programaciones, err := s.getActiveProgramaciones()
+	if err != nil {
+		log.Printf("Error obteniendo programaciones: %v", err)
+		return
+	}
+
+	porEventos := programaciones[:0]
+	for _, prog := range programaciones {
+		if prog.Disparador != nil {
+			porEventos = append(porEventos, prog)
+		}
+	}
+
+	for _, evento := range eventos {
+		disparadas, err := s.processEvento(evento, porEventos)
+		if err != nil {
+			log.Printf("Error procesando evento %s del producto %s: %v", evento.Tipo, evento.ProductoID.Hex(), err)
+			continue
+		}
+
+		if err := s.eventos.MarkProcessed(evento.ID, disparadas, s.clock.Now()); err != nil {
+			log.Printf("Error marcando evento %s como procesado: %v", evento.ID.Hex(), err)
+		}
+	}
+}
+
+// processEvento encola las programaciones que dispara el evento y devuelve
+// sus IDs. Cada programación publica, según su rotación, una de sus
+// publicaciones que incluya el producto.
+func (s *SchedulerService) processEvento(evento EventoProducto, programaciones []ProgramacionPublicacion) ([]primitive.ObjectID, error) {
+	disparadas := []primitive.ObjectID{}
+	if len(programaciones) == 0 {
+		return disparadas, nil
+	}
+
+	conProducto, err := s.publicaciones.FindIDsByProducto(evento.ProductoID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, prog := range programaciones {
+		if !prog.Disparador.coincide(evento) || evento.CreatedAt.Before(prog.FechaInicio) {
+			continue
+		}
+
+		if prog.FechaFin != nil && evento.CreatedAt.After(*prog.FechaFin) {
+			s.completeProgramacion(prog, "fecha de fin alcanzada")
+			continue
+		}
+
+		var publicaciones []PublicacionRotacion
+		for _, p := range publicacionesDe(prog) {
+			if contieneID(conProducto, p.PublicacionID) {
+				publicaciones = append(publicaciones, p)
+			}
+		}
+		if len(publicaciones) == 0 {
+			continue
+		}
+		prog.Publicaciones = publicaciones
+		prog.PublicacionID = publicaciones[0].PublicacionID
+
+		realizadas, err := s.countSuccessfulRuns(prog.ID)
+		if err != nil {
+			return nil, err
+		}
+		if cuotaAlcanzada(prog, realizadas) {
+			s.completeProgramacion(prog, "cantidad de publicaciones alcanzada")
+			continue
+		}
+		restantes, err := s.remainingRuns(prog, realizadas)
+		if err != nil {
+			return nil, err
+		}
+		if restantes == 0 {
+			log.Printf("Programación %s: las publicaciones en curso completan su cantidad, se ignora el evento %s", prog.ID.Hex(), evento.Tipo)
+			continue
+		}
+
+		// Los horarios de silencio y las fechas bloqueadas difieren la publicación
+		disponible, motivo := s.calendarFor(prog).siguientePermitido(s.clock.Now())
+		if disponible.IsZero() {
+			if _, err := s.skipSlot(prog, evento.CreatedAt, motivo); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		log.Printf("Evento %s del producto %s dispara la programación %s", evento.Tipo, evento.ProductoID.Hex(), prog.ID.Hex())
+		if err := s.enqueueRun(prog, evento.CreatedAt, disponible, false); err != nil {
+			return nil, err
+		}
+		disparadas = append(disparadas, prog.ID)
+	}
+
+	return disparadas, nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestDetectarEventosProducto(t *testing.T) {
+	casos := []struct {
+		nombre   string
+		anterior Producto
+		actual   Producto
+		tipos    []string
+	}{
+		{nombre: "reposición desde 0", anterior: Producto{Stock: 0, Precio: 10}, actual: Producto{Stock: 4, Precio: 10}, tipos: []string{EventoReposicion}},
+		{nombre: "más stock sin agotarse", anterior: Producto{Stock: 2, Precio: 10}, actual: Producto{Stock: 6, Precio: 10}},
+		{nombre: "baja de precio", anterior: Producto{Stock: 3, Precio: 10}, actual: Producto{Stock: 3, Precio: 8}, tipos: []string{EventoBajaPrecio}},
+		{nombre: "suba de precio", anterior: Producto{Stock: 3, Precio: 10}, actual: Producto{Stock: 3, Precio: 12}},
+		{nombre: "reposición y baja juntas", anterior: Producto{Stock: 0, Precio: 10}, actual: Producto{Stock: 1, Precio: 9}, tipos: []string{EventoReposicion, EventoBajaPrecio}},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			caso.anterior.ID = primitive.NewObjectID()
+			caso.actual.UpdatedAt = time.Date(2025, 3, 3, 10, 0, 0, 0, time.UTC)
+
+			eventos := detectarEventosProducto(caso.anterior, caso.actual)
+			if len(eventos) != len(caso.tipos) {
+				t.Fatalf("se generaron %d eventos, se esperaban %d", len(eventos), len(caso.tipos))
+			}
+			for i, evento := range eventos {
+				if evento.Tipo != caso.tipos[i] || evento.ProductoID != caso.anterior.ID || evento.Estado != EventoPendiente || !evento.CreatedAt.Equal(caso.actual.UpdatedAt) {
+					t.Errorf("evento %d inesperado: %+v", i+1, evento)
+				}
+			}
+		})
+	}
+}
+
+func TestSchedulerEventosProductos(t *testing.T) {
+	inicio := time.Date(2025, 3, 3, 10, 0, 0, 0, time.UTC)
+	sim := nuevaSimulacion(t, inicio, 1)
+
+	producto := Producto{ID: primitive.NewObjectID(), Precio: 100, Stock: 0}
+	sim.publicacion.Productos = []PublicacionProducto{{ProductoID: producto.ID, Cantidad: 1}}
+	sim.publicaciones.datos[sim.publicacion.ID] = sim.publicacion
+	otra := Publicacion{ID: primitive.NewObjectID(), Titulo: "Otra"}
+	sim.publicaciones.datos[otra.ID] = otra
+
+	reposicion := sim.programar(ProgramacionPublicacion{
+		FechaInicio: inicio,
+		Disparador:  &DisparadorEventos{Eventos: []string{EventoReposicion}},
+	})
+	baja := sim.programar(ProgramacionPublicacion{
+		FechaInicio: inicio,
+		Disparador:  &DisparadorEventos{Eventos: []string{EventoBajaPrecio}, BajaMinimaPorcentaje: 20},
+	})
+	sinProducto := sim.programar(ProgramacionPublicacion{
+		FechaInicio: inicio,
+		Disparador:  &DisparadorEventos{Eventos: []string{EventoReposicion, EventoBajaPrecio}},
+	})
+	prog := sim.programaciones.datos[sinProducto]
+	prog.PublicacionID = otra.ID
+	sim.programaciones.datos[sinProducto] = prog
+
+	pasos := []struct {
+		nombre     string
+		cambiar    func(*Producto)
+		disparadas []primitive.ObjectID
+	}{
+		{nombre: "reposición", cambiar: func(p *Producto) { p.Stock = 5 }, disparadas: []primitive.ObjectID{reposicion}},
+		{nombre: "baja menor a la mínima", cambiar: func(p *Producto) { p.Precio = 90 }, disparadas: []primitive.ObjectID{}},
+		{nombre: "baja mayor a la mínima", cambiar: func(p *Producto) { p.Precio = 60 }, disparadas: []primitive.ObjectID{baja}},
+		{nombre: "venta sin agotarse", cambiar: func(p *Producto) { p.Stock = 3 }},
+	}
+
+	for _, paso := range pasos {
+		sim.reloj.Advance(time.Hour)
+		actual := producto
+		paso.cambiar(&actual)
+		actual.UpdatedAt = sim.reloj.Now()
+
+		eventos := detectarEventosProducto(producto, actual)
+		if err := sim.scheduler.PublishProductEvents(eventos); err != nil {
+			t.Fatalf("%s: PublishProductEvents: %v", paso.nombre, err)
+		}
+		sim.scheduler.Tick()
+		sim.vaciarCola()
+		producto = actual
+
+		if paso.disparadas == nil {
+			if len(eventos) != 0 {
+				t.Errorf("%s: se generaron %d eventos, no se esperaba ninguno", paso.nombre, len(eventos))
+			}
+			continue
+		}
+
+		evento := sim.eventos.eventos[len(sim.eventos.eventos)-1]
+		if evento.Estado != EventoProcesado {
+			t.Errorf("%s: el evento quedó %s", paso.nombre, evento.Estado)
+		}
+		if len(evento.Programaciones) != len(paso.disparadas) || (len(paso.disparadas) > 0 && evento.Programaciones[0] != paso.disparadas[0]) {
+			t.Errorf("%s: disparó %v, se esperaba %v", paso.nombre, evento.Programaciones, paso.disparadas)
+		}
+	}
+
+	// Las programaciones con disparador no publican por horario
+	sim.avanzar(inicio.AddDate(0, 0, 2))
+
+	if len(sim.facebook.publicaciones) != 2 {
+		t.Fatalf("se publicó %d veces, se esperaban 2", len(sim.facebook.publicaciones))
+	}
+	conteos, _ := sim.ledger.CountSuccessful([]primitive.ObjectID{reposicion, baja, sinProducto})
+	if conteos[reposicion] != 1 || conteos[baja] != 1 || conteos[sinProducto] != 0 {
+		t.Errorf("ejecuciones exitosas %v, se esperaba 1 por reposición, 1 por baja y ninguna sin el producto", conteos)
+	}
+}
@@ -1,47 +1,87 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"net/textproto"
 	"net/url"
+	"os"
+	"strings"
 	"time"
 )
 
-const (
-	FacebookAPIBaseURL = "https://graph.facebook.com/v18.0"
-)
+// duracionTokenLargo es la duración que se asume para un token de larga
+// duración cuando Facebook no informa su vencimiento
+const duracionTokenLargo = 60 * 24 * time.Hour
+
+// ErrFacebookAppNoConfigurada indica que faltan FACEBOOK_APP_ID o
+// FACEBOOK_APP_SECRET para intercambiar tokens
+var ErrFacebookAppNoConfigurada = errors.New("la aplicación de Facebook no está configurada")
+
+// FacebookApp son las credenciales de la aplicación de Facebook
+type FacebookApp struct {
+	ID     string
+	Secret string
+}
 
 // FacebookService maneja la integración con Facebook Graph API
 type FacebookService struct {
-	client *http.Client
+	graph GraphClient
+	uso   *MonitorUsoGraph
+	app   FacebookApp
 }
 
+// NewFacebookService crea el servicio contra la Graph API configurada en
+// FACEBOOK_GRAPH_URL y FACEBOOK_GRAPH_VERSION, con la aplicación de
+// FACEBOOK_APP_ID y FACEBOOK_APP_SECRET
 func NewFacebookService() *FacebookService {
-	return &FacebookService{
-		client: &http.Client{
-			Timeout: time.Second * 30,
-		},
-	}
+	uso := NewMonitorUsoGraph()
+	return NewFacebookServiceWith(NewGraphClient(
+		envString("FACEBOOK_GRAPH_URL", graphURLPorDefecto),
+		envString("FACEBOOK_GRAPH_VERSION", graphVersionPorDefecto),
+		uso,
+	), uso, FacebookApp{
+		ID:     os.Getenv("FACEBOOK_APP_ID"),
+		Secret: os.Getenv("FACEBOOK_APP_SECRET"),
+	})
+}
+
+// NewFacebookServiceWith crea el servicio sobre el GraphClient indicado, por
+// ejemplo uno que apunta a la Graph API simulada, el monitor donde ese
+// cliente registra el uso y las credenciales de la aplicación
+func NewFacebookServiceWith(graph GraphClient, uso *MonitorUsoGraph, app FacebookApp) *FacebookService {
+	return &FacebookService{graph: graph, uso: uso, app: app}
+}
+
+// Usage devuelve el uso de la Graph API de la aplicación y del token
+func (f *FacebookService) Usage(accessToken string) (app, token UsoGraph) {
+	return f.uso.App(), f.uso.Token(accessToken)
+}
+
+// UsageWait devuelve cuánto esperar antes de volver a llamar a la Graph API
+// con el token para no agotar su cuota, y el motivo
+func (f *FacebookService) UsageWait(accessToken string, now time.Time) (time.Duration, string) {
+	return f.uso.Wait(accessToken, now)
 }
 
 // GetUserInfo obtiene información del usuario de Facebook
 func (f *FacebookService) GetUserInfo(accessToken string) (*FacebookUserInfo, error) {
-	url := fmt.Sprintf("%s/me?fields=id,name,email&access_token=%s", FacebookAPIBaseURL, accessToken)
+	params := url.Values{}
+	params.Set("fields", "id,name,email")
+	params.Set("access_token", accessToken)
 
-	resp, err := f.client.Get(url)
+	resp, err := f.graph.Get("me", params)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("error al obtener información del usuario: %d", resp.StatusCode)
-	}
 
 	var userInfo FacebookUserInfo
-	if err := json.NewDecoder(resp.Body).Decode(&userInfo); err != nil {
+	if err := leerRespuesta(resp, "error al obtener información del usuario", &userInfo); err != nil {
 		return nil, err
 	}
 
@@ -50,19 +90,11 @@ func (f *FacebookService) GetUserInfo(accessToken string) (*FacebookUserInfo, er
 
 // GetUserGroups obtiene los grupos donde el usuario puede publicar
 func (f *FacebookService) GetUserGroups(accessToken string) ([]FacebookGroupInfo, error) {
-	url := fmt.Sprintf("%s/me/groups?fields=id,name,description,member_count,privacy&access_token=%s", FacebookAPIBaseURL, accessToken)
-
-	resp, err := f.client.Get(url)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("error al obtener grupos: %d", resp.StatusCode)
-	}
+	params := url.Values{}
+	params.Set("fields", "id,name,description,member_count,privacy")
+	params.Set("access_token", accessToken)
 
-	body, err := io.ReadAll(resp.Body)
+	resp, err := f.graph.Get("me/groups", params)
 	if err != nil {
 		return nil, err
 	}
@@ -71,7 +103,7 @@ func (f *FacebookService) GetUserGroups(accessToken string) ([]FacebookGroupInfo
 		Data []FacebookGroupInfo `json:"data"`
 	}
 
-	if err := json.Unmarshal(body, &response); err != nil {
+	if err := leerRespuesta(resp, "error al obtener grupos", &response); err != nil {
 		return nil, err
 	}
 
@@ -88,45 +120,163 @@ func (f *FacebookService) PostToGroup(accessToken, groupID string, req FacebookP
 	}
 	data.Set("access_token", accessToken)
 
-	// URL de la API
-	apiURL := fmt.Sprintf("%s/%s/feed", FacebookAPIBaseURL, groupID)
-
 	// Crear petición
-	resp, err := f.client.PostForm(apiURL, data)
+	resp, err := f.graph.PostForm(groupID+"/feed", data)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	return leerRespuestaPublicacion(resp, "error al publicar en grupo")
+}
+
+// PostWithPhoto publica una foto en un grupo con el mensaje como descripción.
+// La imagen se sube como multipart/form-data al endpoint /{group-id}/photos.
+func (f *FacebookService) PostWithPhoto(accessToken, groupID string, req FacebookPostRequest, imageData []byte) (*FacebookPostResponse, error) {
+	body, contentType, err := formularioFoto(req.Message, imageData)
 	if err != nil {
 		return nil, err
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("error al publicar en grupo: %d - %s", resp.StatusCode, string(body))
+	params := url.Values{}
+	params.Set("access_token", accessToken)
+
+	resp, err := f.graph.Post(groupID+"/photos", params, contentType, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return leerRespuestaPublicacion(resp, "error al publicar foto en grupo")
+}
+
+// PostPhotoFromURL publica en un grupo la foto de req.ImageURL con el mensaje
+// como descripción; Facebook descarga la imagen desde esa URL
+func (f *FacebookService) PostPhotoFromURL(accessToken, groupID string, req FacebookPostRequest) (*FacebookPostResponse, error) {
+	data := url.Values{}
+	data.Set("url", req.ImageURL)
+	data.Set("caption", req.Message)
+	data.Set("access_token", accessToken)
+
+	resp, err := f.graph.PostForm(groupID+"/photos", data)
+	if err != nil {
+		return nil, err
+	}
+
+	return leerRespuestaPublicacion(resp, "error al publicar foto en grupo")
+}
+
+// PostAlbum publica en un grupo una publicación con varias fotos: sube cada
+// foto sin publicar y crea la publicación del feed adjuntándolas con
+// attached_media
+func (f *FacebookService) PostAlbum(accessToken, groupID, message string, fotos []FotoPublicacion) (*FacebookPostResponse, error) {
+	data := url.Values{}
+	data.Set("message", message)
+	data.Set("access_token", accessToken)
+
+	for i, foto := range fotos {
+		photoID, err := f.uploadUnpublishedPhoto(accessToken, groupID, foto)
+		if err != nil {
+			return nil, fmt.Errorf("foto %d: %w", i+1, err)
+		}
+
+		media, err := json.Marshal(map[string]string{"media_fbid": photoID})
+		if err != nil {
+			return nil, err
+		}
+		data.Set(fmt.Sprintf("attached_media[%d]", i), string(media))
 	}
 
+	resp, err := f.graph.PostForm(groupID+"/feed", data)
+	if err != nil {
+		return nil, err
+	}
+
+	return leerRespuestaPublicacion(resp, "error al publicar álbum en grupo")
+}
+
+// uploadUnpublishedPhoto sube una foto al grupo sin publicarla y devuelve su ID
+func (f *FacebookService) uploadUnpublishedPhoto(accessToken, groupID string, foto FotoPublicacion) (string, error) {
+	data := url.Values{}
+	data.Set("url", foto.URL)
+	data.Set("caption", foto.Caption)
+	data.Set("published", "false")
+	data.Set("access_token", accessToken)
+
+	resp, err := f.graph.PostForm(groupID+"/photos", data)
+	if err != nil {
+		return "", err
+	}
+
+	response, err := leerRespuestaPublicacion(resp, "error al subir foto")
+	if err != nil {
+		return "", err
+	}
+	return response.ID, nil
+}
+
+// formularioFoto arma el cuerpo multipart de una foto con su descripción
+func formularioFoto(caption string, imageData []byte) (io.Reader, string, error) {
+	tipo := http.DetectContentType(imageData)
+	if !strings.HasPrefix(tipo, "image/") {
+		return nil, "", fmt.Errorf("el archivo no es una imagen (%s)", tipo)
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	if err := writer.WriteField("caption", caption); err != nil {
+		return nil, "", err
+	}
+
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", `form-data; name="source"; filename="imagen"`)
+	header.Set("Content-Type", tipo)
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := part.Write(imageData); err != nil {
+		return nil, "", err
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+	return &body, writer.FormDataContentType(), nil
+}
+
+// leerRespuestaPublicacion decodifica la respuesta de una publicación
+func leerRespuestaPublicacion(resp *http.Response, operacion string) (*FacebookPostResponse, error) {
 	var response FacebookPostResponse
-	if err := json.Unmarshal(body, &response); err != nil {
+	if err := leerRespuesta(resp, operacion, &response); err != nil {
 		return nil, err
 	}
 
 	return &response, nil
 }
 
-// PostWithPhoto publica un mensaje con foto en un grupo
-func (f *FacebookService) PostWithPhoto(accessToken, groupID string, req FacebookPostRequest, imageData []byte) (*FacebookPostResponse, error) {
-	// Para publicaciones con foto, necesitamos usar multipart/form-data
-	// Esto es más complejo, por ahora implementamos solo texto
-	return f.PostToGroup(accessToken, groupID, req)
+// leerRespuesta decodifica en destino la respuesta de la Graph API y cierra
+// su cuerpo; las respuestas de error se devuelven como *GraphError
+func leerRespuesta(resp *http.Response, operacion string, destino interface{}) error {
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nuevoGraphError(operacion, resp.StatusCode, body)
+	}
+
+	return json.Unmarshal(body, destino)
 }
 
 // ValidateAccessToken valida si el token de acceso es válido
 func (f *FacebookService) ValidateAccessToken(accessToken string) (bool, error) {
-	url := fmt.Sprintf("%s/me?access_token=%s", FacebookAPIBaseURL, accessToken)
+	params := url.Values{}
+	params.Set("access_token", accessToken)
 
-	resp, err := f.client.Get(url)
+	resp, err := f.graph.Get("me", params)
 	if err != nil {
 		return false, err
 	}
@@ -137,68 +287,96 @@ func (f *FacebookService) ValidateAccessToken(accessToken string) (bool, error)
 
 // GetTokenInfo obtiene información sobre el token de acceso
 func (f *FacebookService) GetTokenInfo(accessToken string) (*FacebookTokenInfo, error) {
-	url := fmt.Sprintf("%s/debug_token?input_token=%s&access_token=%s", FacebookAPIBaseURL, accessToken, accessToken)
+	params := url.Values{}
+	params.Set("input_token", accessToken)
+	params.Set("access_token", accessToken)
 
-	resp, err := f.client.Get(url)
+	resp, err := f.graph.Get("debug_token", params)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("error al obtener información del token: %d", resp.StatusCode)
-	}
 
 	var response struct {
 		Data FacebookTokenInfo `json:"data"`
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+	if err := leerRespuesta(resp, "error al obtener información del token", &response); err != nil {
 		return nil, err
 	}
 
 	return &response.Data, nil
 }
 
-// RefreshLongLivedToken convierte un token de corta duración en uno de larga duración
-func (f *FacebookService) RefreshLongLivedToken(accessToken, appID, appSecret string) (*FacebookTokenInfo, error) {
-	url := fmt.Sprintf("%s/oauth/access_token?grant_type=fb_exchange_token&client_id=%s&client_secret=%s&fb_exchange_token=%s",
-		FacebookAPIBaseURL, appID, appSecret, accessToken)
+// RefreshLongLivedToken intercambia un token de acceso por uno de larga
+// duración con las credenciales de la aplicación. Sirve tanto para el token
+// de corta duración del login como para renovar uno de larga duración que
+// todavía no venció.
+func (f *FacebookService) RefreshLongLivedToken(accessToken string) (*FacebookLongLivedToken, error) {
+	if f.app.ID == "" || f.app.Secret == "" {
+		return nil, ErrFacebookAppNoConfigurada
+	}
+
+	params := url.Values{}
+	params.Set("grant_type", "fb_exchange_token")
+	params.Set("client_id", f.app.ID)
+	params.Set("client_secret", f.app.Secret)
+	params.Set("fb_exchange_token", accessToken)
 
-	resp, err := f.client.Get(url)
+	resp, err := f.graph.Get("oauth/access_token", params)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("error al renovar token: %d", resp.StatusCode)
+	var token FacebookLongLivedToken
+	if err := leerRespuesta(resp, "error al renovar token", &token); err != nil {
+		return nil, err
+	}
+	if token.AccessToken == "" {
+		return nil, errors.New("error al renovar token: la respuesta no incluye access_token")
 	}
 
-	var tokenInfo FacebookTokenInfo
-	if err := json.NewDecoder(resp.Body).Decode(&tokenInfo); err != nil {
+	return &token, nil
+}
+
+// expiracionToken calcula el vencimiento de un token de larga duración
+// obtenido en now
+func expiracionToken(token *FacebookLongLivedToken, now time.Time) time.Time {
+	if token.ExpiresIn <= 0 {
+		return now.Add(duracionTokenLargo)
+	}
+	return now.Add(time.Duration(token.ExpiresIn) * time.Second)
+}
+
+// GetPostEngagement obtiene las reacciones, comentarios y compartidos de una publicación
+func (f *FacebookService) GetPostEngagement(accessToken, postID string) (*FacebookPostEngagement, error) {
+	params := url.Values{}
+	params.Set("fields", "reactions.summary(true).limit(0),comments.summary(true).limit(0),shares")
+	params.Set("access_token", accessToken)
+	resp, err := f.graph.Get(postID, params)
+	if err != nil {
 		return nil, err
 	}
 
-	return &tokenInfo, nil
+	var engagement FacebookPostEngagement
+	if err := leerRespuesta(resp, "error al obtener interacción", &engagement); err != nil {
+		return nil, err
+	}
+
+	return &engagement, nil
 }
 
 // GetPostInsights obtiene estadísticas de una publicación
 func (f *FacebookService) GetPostInsights(accessToken, postID string) (map[string]interface{}, error) {
-	url := fmt.Sprintf("%s/%s/insights?access_token=%s", FacebookAPIBaseURL, postID, accessToken)
+	params := url.Values{}
+	params.Set("access_token", accessToken)
 
-	resp, err := f.client.Get(url)
+	resp, err := f.graph.Get(postID+"/insights", params)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("error al obtener estadísticas: %d", resp.StatusCode)
-	}
 
 	var insights map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&insights); err != nil {
+	if err := leerRespuesta(resp, "error al obtener estadísticas", &insights); err != nil {
 		return nil, err
 	}
 
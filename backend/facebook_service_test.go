@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/vhPedroGitHub/ventas-ceili/internal/fakegraph"
+)
+
+// pngMinimo es la firma de un archivo PNG, suficiente para detectar el tipo
+var pngMinimo = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestFormularioFoto(t *testing.T) {
+	body, contentType, err := formularioFoto("Oferta", pngMinimo)
+	if err != nil {
+		t.Fatalf("formularioFoto: %v", err)
+	}
+
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		t.Fatalf("content type %q: %v", contentType, err)
+	}
+
+	campos := map[string]string{}
+	reader := multipart.NewReader(body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("NextPart: %v", err)
+		}
+		datos, _ := io.ReadAll(part)
+
+		if part.FormName() == "source" {
+			if tipo := part.Header.Get("Content-Type"); tipo != "image/png" {
+				t.Errorf("tipo de la foto %q, se esperaba image/png", tipo)
+			}
+			if !bytes.Equal(datos, pngMinimo) {
+				t.Error("el contenido de la foto no coincide")
+			}
+		}
+		campos[part.FormName()] = string(datos)
+	}
+
+	if campos["caption"] != "Oferta" {
+		t.Errorf("campos del formulario %v", campos)
+	}
+	if _, ok := campos["source"]; !ok {
+		t.Error("el formulario no incluye la foto")
+	}
+
+	if _, _, err := formularioFoto("Oferta", []byte("texto plano")); err == nil {
+		t.Error("se aceptó un archivo que no es una imagen")
+	}
+}
+
+// servicioSimulado crea un FacebookService contra una Graph API simulada con
+// DevToken y sus grupos
+func servicioSimulado(t *testing.T) (*FacebookService, *fakegraph.Server) {
+	t.Helper()
+
+	fake := fakegraph.New()
+	fake.Seed()
+	t.Cleanup(fake.Close)
+
+	uso := NewMonitorUsoGraph()
+	app := FacebookApp{ID: fake.AppID, Secret: fake.AppSecret}
+	return NewFacebookServiceWith(NewGraphClient(fake.URL, graphVersionPorDefecto, uso), uso, app), fake
+}
+
+func TestFacebookServiceGraphSimulada(t *testing.T) {
+	t.Run("usuario y grupos", func(t *testing.T) {
+		servicio, _ := servicioSimulado(t)
+
+		usuario, err := servicio.GetUserInfo(fakegraph.DevToken)
+		if err != nil || usuario.ID != "fake-user" {
+			t.Fatalf("GetUserInfo: %+v, %v", usuario, err)
+		}
+
+		grupos, err := servicio.GetUserGroups(fakegraph.DevToken)
+		if err != nil || len(grupos) != 2 || grupos[0].ID != "fake-group-1" {
+			t.Fatalf("GetUserGroups: %+v, %v", grupos, err)
+		}
+
+		if valido, _ := servicio.ValidateAccessToken("otro-token"); valido {
+			t.Error("se aceptó un token desconocido")
+		}
+	})
+
+	t.Run("texto en el feed", func(t *testing.T) {
+		servicio, fake := servicioSimulado(t)
+
+		respuesta, err := servicio.PostToGroup(fakegraph.DevToken, "fake-group-1", FacebookPostRequest{Message: "Oferta"})
+		if err != nil {
+			t.Fatalf("PostToGroup: %v", err)
+		}
+
+		posts := fake.Posts()
+		if len(posts) != 1 || posts[0].ID != respuesta.ID || posts[0].Message != "Oferta" {
+			t.Errorf("publicaciones %+v, respuesta %+v", posts, respuesta)
+		}
+	})
+
+	t.Run("foto subida", func(t *testing.T) {
+		servicio, fake := servicioSimulado(t)
+
+		respuesta, err := servicio.PostWithPhoto(fakegraph.DevToken, "fake-group-1", FacebookPostRequest{Message: "Oferta"}, pngMinimo)
+		if err != nil {
+			t.Fatalf("PostWithPhoto: %v", err)
+		}
+
+		fotos := fake.Photos()
+		if len(fotos) != 1 || fotos[0].ContentType != "image/png" || fotos[0].Size != len(pngMinimo) || fotos[0].Caption != "Oferta" {
+			t.Fatalf("fotos %+v", fotos)
+		}
+		if respuesta.PostID == "" || respuesta.PostID != fotos[0].PostID {
+			t.Errorf("respuesta %+v, foto %+v", respuesta, fotos[0])
+		}
+	})
+
+	t.Run("álbum con fotos sin publicar", func(t *testing.T) {
+		servicio, fake := servicioSimulado(t)
+
+		fotos := []FotoPublicacion{
+			{URL: "https://example.com/remera.jpg", Caption: "Remera - $2500"},
+			{URL: "https://example.com/gorra.jpg", Caption: "Gorra - $1800"},
+		}
+		respuesta, err := servicio.PostAlbum(fakegraph.DevToken, "fake-group-2", "Oferta", fotos)
+		if err != nil {
+			t.Fatalf("PostAlbum: %v", err)
+		}
+
+		posts := fake.Posts()
+		if len(posts) != 1 || posts[0].ID != respuesta.ID || len(posts[0].AttachedMedia) != 2 {
+			t.Fatalf("publicaciones %+v", posts)
+		}
+		for i, foto := range fake.Photos() {
+			if foto.URL != fotos[i].URL || foto.Caption != fotos[i].Caption || foto.PostID != respuesta.ID {
+				t.Errorf("foto %d: %+v", i+1, foto)
+			}
+		}
+	})
+
+	t.Run("información del token", func(t *testing.T) {
+		servicio, _ := servicioSimulado(t)
+
+		info, err := servicio.GetTokenInfo(fakegraph.DevToken)
+		if err != nil || !info.IsValid || info.AppID != "fake-app" {
+			t.Errorf("GetTokenInfo: %+v, %v", info, err)
+		}
+	})
+
+	t.Run("token de larga duración", func(t *testing.T) {
+		servicio, _ := servicioSimulado(t)
+
+		token, err := servicio.RefreshLongLivedToken(fakegraph.DevToken)
+		if err != nil {
+			t.Fatalf("RefreshLongLivedToken: %v", err)
+		}
+		if token.AccessToken == "" || token.AccessToken == fakegraph.DevToken || token.ExpiresIn != int64(duracionTokenLargo.Seconds()) {
+			t.Errorf("token de larga duración %+v", token)
+		}
+
+		// El token nuevo sirve para llamar a la API y para volver a renovarse
+		if _, err := servicio.GetUserGroups(token.AccessToken); err != nil {
+			t.Errorf("GetUserGroups con el token nuevo: %v", err)
+		}
+		if _, err := servicio.RefreshLongLivedToken(token.AccessToken); err != nil {
+			t.Errorf("renovación del token de larga duración: %v", err)
+		}
+
+		if _, err := servicio.RefreshLongLivedToken("token-desconocido"); comoGraphError(err) == nil || !comoGraphError(err).IsTokenExpired() {
+			t.Errorf("error con un token inválido %v", err)
+		}
+
+		sinApp := NewFacebookServiceWith(servicio.graph, servicio.uso, FacebookApp{})
+		if _, err := sinApp.RefreshLongLivedToken(fakegraph.DevToken); !errors.Is(err, ErrFacebookAppNoConfigurada) {
+			t.Errorf("error sin credenciales %v", err)
+		}
+	})
+
+	t.Run("uso de la cuota", func(t *testing.T) {
+		servicio, fake := servicioSimulado(t)
+		fake.SetAppUsage(fakegraph.Usage{CallCount: 35, TotalCPUTime: 12, TotalTime: 20})
+		fake.SetTokenUsage(fakegraph.DevToken, fakegraph.Usage{CallCount: 60, TotalCPUTime: 5, TotalTime: 8})
+
+		if _, err := servicio.GetUserGroups(fakegraph.DevToken); err != nil {
+			t.Fatalf("GetUserGroups: %v", err)
+		}
+		app, cuenta := servicio.Usage(fakegraph.DevToken)
+		if app.Porcentaje != 35 || cuenta.Porcentaje != 60 {
+			t.Errorf("uso de la aplicación %+v y de la cuenta %+v", app, cuenta)
+		}
+
+		// El límite de llamadas con Retry-After pausa las llamadas de la cuenta
+		limite := fakegraph.ErrRateLimited
+		limite.RetryAfter = 300
+		fake.FailNext(http.MethodPost, "fake-group-1/photos", limite)
+		_, err := servicio.PostWithPhoto(fakegraph.DevToken, "fake-group-1", FacebookPostRequest{Message: "Oferta"}, pngMinimo)
+		if graphErr := comoGraphError(err); graphErr == nil || !graphErr.IsRateLimited() {
+			t.Fatalf("error %v, se esperaba un límite de llamadas", err)
+		}
+		if espera, _ := servicio.UsageWait(fakegraph.DevToken, time.Now()); espera < 4*time.Minute {
+			t.Errorf("espera %s tras el Retry-After de 5 minutos", espera)
+		}
+	})
+
+	t.Run("errores de la API", func(t *testing.T) {
+		servicio, fake := servicioSimulado(t)
+
+		fake.FailNext(http.MethodPost, "fake-group-1/feed", fakegraph.ErrServer)
+		_, err := servicio.PostToGroup(fakegraph.DevToken, "fake-group-1", FacebookPostRequest{Message: "Oferta"})
+		var graphErr *GraphError
+		if !errors.As(err, &graphErr) || graphErr.StatusCode != http.StatusInternalServerError || !esErrorTransitorio(err) {
+			t.Errorf("error de servidor %v, se esperaba un error transitorio", err)
+		}
+
+		_, err = servicio.PostToGroup(fakegraph.DevToken, "grupo-inexistente", FacebookPostRequest{Message: "Oferta"})
+		if err == nil || esErrorTransitorio(err) {
+			t.Errorf("error con grupo inexistente %v, se esperaba un error permanente", err)
+		}
+
+		if len(fake.Posts()) != 0 {
+			t.Errorf("se publicó pese a los errores: %+v", fake.Posts())
+		}
+	})
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// graphURLPorDefecto es la URL base de la Graph API
+	graphURLPorDefecto = "https://graph.facebook.com"
+
+	// graphVersionPorDefecto es la versión de la Graph API que usa el backend
+	graphVersionPorDefecto = "v18.0"
+)
+
+// GraphClient hace las peticiones HTTP a la Graph API. Las rutas son
+// relativas a la versión ("me/groups", "{group-id}/photos") y el token de
+// acceso viaja en los parámetros.
+type GraphClient interface {
+	Get(path string, params url.Values) (*http.Response, error)
+	PostForm(path string, params url.Values) (*http.Response, error)
+	// Post envía un cuerpo ya armado, por ejemplo un formulario multipart,
+	// con los parámetros en la URL
+	Post(path string, params url.Values, contentType string, body io.Reader) (*http.Response, error)
+}
+
+// httpGraphClient implementa GraphClient sobre HTTP contra la URL base y la
+// versión configuradas, que pueden apuntar a la Graph API simulada. Registra
+// en el monitor los encabezados de uso de cada respuesta.
+type httpGraphClient struct {
+	client  *http.Client
+	baseURL string
+	version string
+	uso     *MonitorUsoGraph
+}
+
+// NewGraphClient crea un GraphClient contra baseURL, por ejemplo
+// https://graph.facebook.com, usando la versión indicada (v18.0)
+func NewGraphClient(baseURL, version string, uso *MonitorUsoGraph) GraphClient {
+	return &httpGraphClient{
+		client: &http.Client{
+			Timeout: time.Second * 30,
+		},
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		version: version,
+		uso:     uso,
+	}
+}
+
+// endpoint arma la URL de una ruta con sus parámetros de consulta
+func (g *httpGraphClient) endpoint(path string, params url.Values) (string, error) {
+	endpoint, err := url.JoinPath(g.baseURL, g.version, path)
+	if err != nil {
+		return "", err
+	}
+	if len(params) > 0 {
+		endpoint += "?" + params.Encode()
+	}
+	return endpoint, nil
+}
+
+// registrarUso guarda el uso informado por la respuesta para el token de la
+// petición
+func (g *httpGraphClient) registrarUso(params url.Values, resp *http.Response, err error) (*http.Response, error) {
+	if err == nil && g.uso != nil {
+		g.uso.Record(params.Get("access_token"), resp.Header, time.Now())
+	}
+	return resp, err
+}
+
+func (g *httpGraphClient) Get(path string, params url.Values) (*http.Response, error) {
+	endpoint, err := g.endpoint(path, params)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := g.client.Get(endpoint)
+	return g.registrarUso(params, resp, err)
+}
+
+func (g *httpGraphClient) PostForm(path string, params url.Values) (*http.Response, error) {
+	endpoint, err := g.endpoint(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := g.client.PostForm(endpoint, params)
+	return g.registrarUso(params, resp, err)
+}
+
+func (g *httpGraphClient) Post(path string, params url.Values, contentType string, body io.Reader) (*http.Response, error) {
+	endpoint, err := g.endpoint(path, params)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := g.client.Post(endpoint, contentType, body)
+	return g.registrarUso(params, resp, err)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// GraphError es una respuesta de error de la Graph API. Se arma con el objeto
+// error del cuerpo; si el cuerpo no lo tiene, solo se conservan el estado HTTP
+// y el cuerpo crudo.
+type GraphError struct {
+	Operacion   string `json:"-"`
+	StatusCode  int    `json:"status"`
+	Message     string `json:"message"`
+	Type        string `json:"type,omitempty"`
+	Code        int    `json:"code"`
+	Subcode     int    `json:"error_subcode,omitempty"`
+	FBTraceID   string `json:"fbtrace_id,omitempty"`
+	IsTransient bool   `json:"is_transient,omitempty"`
+	Body        string `json:"-"`
+}
+
+// nuevoGraphError interpreta la respuesta de error de una operación
+func nuevoGraphError(operacion string, statusCode int, body []byte) *GraphError {
+	graphErr := &GraphError{Operacion: operacion, StatusCode: statusCode, Body: string(body)}
+
+	var cuerpo struct {
+		Error *struct {
+			Message     string `json:"message"`
+			Type        string `json:"type"`
+			Code        int    `json:"code"`
+			Subcode     int    `json:"error_subcode"`
+			FBTraceID   string `json:"fbtrace_id"`
+			IsTransient bool   `json:"is_transient"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &cuerpo) != nil || cuerpo.Error == nil {
+		return graphErr
+	}
+
+	graphErr.Message = cuerpo.Error.Message
+	graphErr.Type = cuerpo.Error.Type
+	graphErr.Code = cuerpo.Error.Code
+	graphErr.Subcode = cuerpo.Error.Subcode
+	graphErr.FBTraceID = cuerpo.Error.FBTraceID
+	graphErr.IsTransient = cuerpo.Error.IsTransient
+	return graphErr
+}
+
+func (e *GraphError) Error() string {
+	if e.Code == 0 {
+		return fmt.Sprintf("%s: %d - %s", e.Operacion, e.StatusCode, e.Body)
+	}
+
+	mensaje := fmt.Sprintf("%s: %s (código %d", e.Operacion, e.Message, e.Code)
+	if e.Subcode != 0 {
+		mensaje += fmt.Sprintf(", subcódigo %d", e.Subcode)
+	}
+	if e.FBTraceID != "" {
+		mensaje += ", fbtrace_id " + e.FBTraceID
+	}
+	return mensaje + ")"
+}
+
+// IsTokenExpired indica que el token de acceso venció o ya no es válido
+// (contraseña cambiada, aplicación desautorizada); hay que volver a conectar
+// Facebook
+func (e *GraphError) IsTokenExpired() bool {
+	return e.Code == 190 || e.Code == 102
+}
+
+// IsRateLimited indica que se alcanzó un límite de llamadas de la aplicación,
+// del usuario, de la página o de un caso de uso
+func (e *GraphError) IsRateLimited() bool {
+	switch e.Code {
+	case 4, 17, 32, 341, 613:
+		return true
+	}
+	return e.StatusCode == http.StatusTooManyRequests || (e.Code >= 80001 && e.Code <= 80014)
+}
+
+// IsPermissionDenied indica que falta un permiso para la operación, por
+// ejemplo publish_to_groups o que la aplicación no esté instalada en el grupo
+func (e *GraphError) IsPermissionDenied() bool {
+	return e.Code == 3 || e.Code == 10 || (e.Code >= 200 && e.Code <= 299)
+}
+
+// IsTemporary indica que la operación puede funcionar si se reintenta:
+// errores del servidor, errores marcados como transitorios y límites de uso
+func (e *GraphError) IsTemporary() bool {
+	if e.IsTokenExpired() || e.IsPermissionDenied() {
+		return false
+	}
+	return e.StatusCode >= http.StatusInternalServerError || e.IsTransient || e.Code == 1 || e.Code == 2 || e.IsRateLimited()
+}
+
+// comoGraphError devuelve el GraphError dentro de err, o nil si no lo hay
+func comoGraphError(err error) *GraphError {
+	var graphErr *GraphError
+	if errors.As(err, &graphErr) {
+		return graphErr
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNuevoGraphError(t *testing.T) {
+	body := `{"error":{"message":"Error validating access token","type":"OAuthException","code":190,"error_subcode":463,"fbtrace_id":"Abc123"}}`
+	graphErr := nuevoGraphError("error al publicar en grupo", http.StatusBadRequest, []byte(body))
+
+	if graphErr.Code != 190 || graphErr.Subcode != 463 || graphErr.Type != "OAuthException" || graphErr.FBTraceID != "Abc123" {
+		t.Fatalf("error interpretado %+v", graphErr)
+	}
+	if mensaje := graphErr.Error(); !strings.Contains(mensaje, "código 190, subcódigo 463, fbtrace_id Abc123") {
+		t.Errorf("mensaje %q", mensaje)
+	}
+
+	// Un cuerpo que no es un error de Graph conserva el estado y el cuerpo crudo
+	crudo := nuevoGraphError("error al obtener grupos", http.StatusBadGateway, []byte("<html>Bad Gateway</html>"))
+	if crudo.Code != 0 || crudo.Error() != "error al obtener grupos: 502 - <html>Bad Gateway</html>" {
+		t.Errorf("error sin cuerpo de Graph %q", crudo.Error())
+	}
+	if !crudo.IsTemporary() {
+		t.Error("un 502 sin cuerpo de Graph debería reintentarse")
+	}
+}
+
+func TestGraphErrorClasificacion(t *testing.T) {
+	casos := []struct {
+		nombre      string
+		err         *GraphError
+		vencido     bool
+		limite      bool
+		permiso     bool
+		transitorio bool
+	}{
+		{nombre: "token vencido", err: &GraphError{StatusCode: 400, Code: 190, Subcode: 463}, vencido: true},
+		{nombre: "sesión inválida", err: &GraphError{StatusCode: 400, Code: 102}, vencido: true},
+		{nombre: "límite de la aplicación", err: &GraphError{StatusCode: 400, Code: 4, IsTransient: true}, limite: true, transitorio: true},
+		{nombre: "límite del usuario", err: &GraphError{StatusCode: 400, Code: 17}, limite: true, transitorio: true},
+		{nombre: "límite de caso de uso", err: &GraphError{StatusCode: 400, Code: 80001}, limite: true, transitorio: true},
+		{nombre: "demasiadas peticiones", err: &GraphError{StatusCode: http.StatusTooManyRequests}, limite: true, transitorio: true},
+		{nombre: "permiso faltante", err: &GraphError{StatusCode: 403, Code: 200}, permiso: true},
+		{nombre: "aplicación sin permiso", err: &GraphError{StatusCode: 400, Code: 10}, permiso: true},
+		{nombre: "error del servidor", err: &GraphError{StatusCode: 500, Code: 2}, transitorio: true},
+		{nombre: "error desconocido", err: &GraphError{StatusCode: 400, Code: 1}, transitorio: true},
+		{nombre: "parámetro inválido", err: &GraphError{StatusCode: 400, Code: 100}},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			if got := caso.err.IsTokenExpired(); got != caso.vencido {
+				t.Errorf("IsTokenExpired = %v", got)
+			}
+			if got := caso.err.IsRateLimited(); got != caso.limite {
+				t.Errorf("IsRateLimited = %v", got)
+			}
+			if got := caso.err.IsPermissionDenied(); got != caso.permiso {
+				t.Errorf("IsPermissionDenied = %v", got)
+			}
+			// El scheduler reintenta solo los errores temporales, también
+			// cuando llegan envueltos
+			if got := esErrorTransitorio(fmt.Errorf("foto 1: %w", caso.err)); got != caso.transitorio {
+				t.Errorf("esErrorTransitorio = %v", got)
+			}
+		})
+	}
+}
+
+func TestSchedulerTokenRechazadoNoReintenta(t *testing.T) {
+	inicio := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+	sim := nuevaSimulacion(t, inicio, 1)
+	sim.facebook.errores = []error{nuevoGraphError("error al publicar en grupo", http.StatusBadRequest,
+		[]byte(`{"error":{"message":"Error validating access token","type":"OAuthException","code":190,"error_subcode":460}}`))}
+	sim.programar(ProgramacionPublicacion{
+		Frecuencia:  "diaria",
+		Horarios:    []ConfiguracionHorario{{Hora: 9}},
+		FechaInicio: inicio,
+	})
+
+	sim.avanzar(inicio.AddDate(0, 0, 2))
+
+	if len(sim.facebook.publicaciones) != 0 || len(sim.facebook.errores) != 0 {
+		t.Fatalf("publicaciones %d, errores sin usar %d", len(sim.facebook.publicaciones), len(sim.facebook.errores))
+	}
+	if usuario := sim.usuarios.datos[sim.usuario.ID.Hex()]; !usuario.TokenExpiracion.Equal(time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("token vence el %s, se esperaba que se marcara vencido al fallar", usuario.TokenExpiracion)
+	}
+
+	// El primer trabajo falla sin reintentos y el siguiente ya no llama a Facebook
+	if len(sim.cola.trabajos) != 2 {
+		t.Fatalf("%d trabajos, se esperaban 2", len(sim.cola.trabajos))
+	}
+	for _, trabajo := range sim.cola.trabajos {
+		if trabajo.Estado != TrabajoFallido || trabajo.Intentos != 1 {
+			t.Errorf("trabajo %s con %d intentos, se esperaba fallido con 1", trabajo.Estado, trabajo.Intentos)
+		}
+	}
+}
+
+func TestResponderErrorGraph(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	casos := []struct {
+		nombre string
+		err    error
+		estado int
+		codigo string
+	}{
+		// Un token de Facebook vencido no es la sesión de la aplicación: no responde 401
+		{nombre: "token vencido", err: &GraphError{StatusCode: 400, Code: 190}, estado: http.StatusConflict, codigo: codigoFacebookTokenExpirado},
+		{nombre: "permiso faltante", err: &GraphError{StatusCode: 403, Code: 200}, estado: http.StatusForbidden},
+		{nombre: "límite de llamadas", err: &GraphError{StatusCode: 400, Code: 4}, estado: http.StatusTooManyRequests},
+		{nombre: "error de Facebook", err: &GraphError{StatusCode: 500, Code: 2}, estado: http.StatusBadGateway},
+		{nombre: "error de red", err: errors.New("timeout"), estado: http.StatusInternalServerError},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			responderErrorGraph(c, "Error al obtener grupos de Facebook", caso.err)
+
+			var cuerpo struct {
+				Codigo string `json:"codigo"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &cuerpo); err != nil {
+				t.Fatalf("respuesta %q: %v", w.Body.String(), err)
+			}
+			if w.Code != caso.estado || cuerpo.Codigo != caso.codigo {
+				t.Errorf("estado %d y código %q, se esperaba %d y %q", w.Code, cuerpo.Codigo, caso.estado, caso.codigo)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// pausaUsoAlto es la espera desde la última lectura de uso cuando la cuota
+	// llega al porcentaje de pausa; la siguiente llamada vuelve a leer el uso
+	pausaUsoAlto = 15 * time.Minute
+
+	// esperaUsoFreno es el tiempo mínimo entre llamadas mientras el uso está
+	// entre el porcentaje de freno y el de pausa
+	esperaUsoFreno = 2 * time.Minute
+
+	// ventanaUsoGraph es el periodo que miden los encabezados de uso; el uso
+	// de un token leído hace más tiempo ya no dice nada de su cuota
+	ventanaUsoGraph = time.Hour
+)
+
+// UsoGraph es el uso de la cuota de llamadas a la Graph API según los últimos
+// encabezados recibidos. Los valores son porcentajes de la cuota de la última
+// hora; Porcentaje es el mayor de ellos.
+type UsoGraph struct {
+	CallCount    int        `json:"call_count"`
+	TotalCPUTime int        `json:"total_cputime"`
+	TotalTime    int        `json:"total_time"`
+	Porcentaje   int        `json:"porcentaje"`
+	PausadoHasta *time.Time `json:"pausado_hasta,omitempty"` // Pedido por Retry-After o estimated_time_to_regain_access
+	Actualizado  time.Time  `json:"actualizado,omitempty"`
+}
+
+// MonitorUsoGraph guarda el uso de la Graph API de la aplicación
+// (X-App-Usage) y de cada token (X-Business-Use-Case-Usage y Retry-After) y
+// calcula cuánto esperar antes de volver a llamar para no agotar la cuota.
+// Los tokens se guardan por su hash para no retener los tokens en memoria.
+type MonitorUsoGraph struct {
+	freno int // Porcentaje desde el que se espacian las llamadas
+	pausa int // Porcentaje desde el que se dejan de hacer llamadas
+
+	mu     sync.Mutex
+	app    UsoGraph
+	tokens map[[sha256.Size]byte]UsoGraph
+}
+
+// NewMonitorUsoGraph crea el monitor con los porcentajes de
+// FACEBOOK_USO_FRENO_PORCENTAJE y FACEBOOK_USO_PAUSA_PORCENTAJE
+func NewMonitorUsoGraph() *MonitorUsoGraph {
+	m := &MonitorUsoGraph{
+		freno:  envInt("FACEBOOK_USO_FRENO_PORCENTAJE", 80),
+		pausa:  envInt("FACEBOOK_USO_PAUSA_PORCENTAJE", 95),
+		tokens: make(map[[sha256.Size]byte]UsoGraph),
+	}
+	if m.pausa < m.freno {
+		m.pausa = m.freno
+	}
+	return m
+}
+
+// cuotaUso es el formato de los encabezados de uso
+type cuotaUso struct {
+	CallCount    int `json:"call_count"`
+	TotalCPUTime int `json:"total_cputime"`
+	TotalTime    int `json:"total_time"`
+	// Minutos hasta recuperar el acceso, solo en X-Business-Use-Case-Usage
+	EstimatedTimeToRegainAccess int `json:"estimated_time_to_regain_access"`
+}
+
+// Record actualiza el uso con los encabezados de una respuesta hecha con el
+// token indicado, que puede estar vacío en las llamadas con las credenciales
+// de la aplicación
+func (m *MonitorUsoGraph) Record(accessToken string, header http.Header, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.prune(now)
+
+	if valor := header.Get("X-App-Usage"); valor != "" {
+		var cuota cuotaUso
+		if err := json.Unmarshal([]byte(valor), &cuota); err == nil {
+			m.app = usoDesde(m.app, []cuotaUso{cuota}, now)
+		}
+	}
+
+	pausa := retryAfter(header.Get("Retry-After"), now)
+
+	if accessToken == "" {
+		if pausa != nil {
+			m.app.PausadoHasta = pausa
+		}
+		return
+	}
+
+	clave := sha256.Sum256([]byte(accessToken))
+	uso, registrado := m.tokens[clave]
+	if valor := header.Get("X-Business-Use-Case-Usage"); valor != "" {
+		// Un objeto por negocio con el uso de cada tipo de llamada
+		var negocios map[string][]cuotaUso
+		if err := json.Unmarshal([]byte(valor), &negocios); err == nil {
+			var cuotas []cuotaUso
+			for _, porTipo := range negocios {
+				cuotas = append(cuotas, porTipo...)
+			}
+			uso = usoDesde(uso, cuotas, now)
+			registrado = true
+		}
+	}
+	if pausa != nil {
+		uso.PausadoHasta = pausa
+		registrado = true
+	}
+	if registrado {
+		uso.Actualizado = now
+		m.tokens[clave] = uso
+	}
+}
+
+// prune descarta el uso de los tokens leído hace más de ventanaUsoGraph, salvo
+// que Facebook haya pedido esperar más allá de now
+func (m *MonitorUsoGraph) prune(now time.Time) {
+	for clave, uso := range m.tokens {
+		vencido := now.Sub(uso.Actualizado) >= ventanaUsoGraph
+		if vencido && (uso.PausadoHasta == nil || !uso.PausadoHasta.After(now)) {
+			delete(m.tokens, clave)
+		}
+	}
+}
+
+// usoDesde arma el uso con el mayor valor de cada cuota; conserva una pausa
+// anterior que todavía no terminó
+func usoDesde(anterior UsoGraph, cuotas []cuotaUso, now time.Time) UsoGraph {
+	uso := UsoGraph{Actualizado: now}
+	if anterior.PausadoHasta != nil && anterior.PausadoHasta.After(now) {
+		uso.PausadoHasta = anterior.PausadoHasta
+	}
+
+	for _, cuota := range cuotas {
+		uso.CallCount = max(uso.CallCount, cuota.CallCount)
+		uso.TotalCPUTime = max(uso.TotalCPUTime, cuota.TotalCPUTime)
+		uso.TotalTime = max(uso.TotalTime, cuota.TotalTime)
+		if cuota.EstimatedTimeToRegainAccess > 0 {
+			hasta := now.Add(time.Duration(cuota.EstimatedTimeToRegainAccess) * time.Minute)
+			if uso.PausadoHasta == nil || hasta.After(*uso.PausadoHasta) {
+				uso.PausadoHasta = &hasta
+			}
+		}
+	}
+	uso.Porcentaje = max(uso.CallCount, uso.TotalCPUTime, uso.TotalTime)
+	return uso
+}
+
+// retryAfter interpreta el encabezado Retry-After, en segundos o como fecha
+func retryAfter(valor string, now time.Time) *time.Time {
+	if valor == "" {
+		return nil
+	}
+
+	var hasta time.Time
+	if segundos, err := strconv.Atoi(valor); err == nil {
+		hasta = now.Add(time.Duration(segundos) * time.Second)
+	} else if fecha, err := http.ParseTime(valor); err == nil {
+		hasta = fecha
+	}
+	if !hasta.After(now) {
+		return nil
+	}
+	return &hasta
+}
+
+// App devuelve el uso de la aplicación
+func (m *MonitorUsoGraph) App() UsoGraph {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.app
+}
+
+// Token devuelve el uso registrado para un token
+func (m *MonitorUsoGraph) Token(accessToken string) UsoGraph {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.tokens[sha256.Sum256([]byte(accessToken))]
+}
+
+// Wait devuelve cuánto esperar antes de llamar a la Graph API con el token
+// y el motivo, o 0 si se puede llamar ya. Se espera lo que pida Facebook,
+// pausaUsoAlto si el uso de la aplicación o del token llegó al porcentaje de
+// pausa y esperaUsoFreno entre llamadas si llegó al de freno.
+func (m *MonitorUsoGraph) Wait(accessToken string, now time.Time) (time.Duration, string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	espera, motivo := m.esperaPorUso(m.app, "de la aplicación", now)
+	if uso, ok := m.tokens[sha256.Sum256([]byte(accessToken))]; ok {
+		if esperaToken, motivoToken := m.esperaPorUso(uso, "de la cuenta", now); esperaToken > espera {
+			espera, motivo = esperaToken, motivoToken
+		}
+	}
+	return espera, motivo
+}
+
+// esperaPorUso calcula la espera que impone un uso
+func (m *MonitorUsoGraph) esperaPorUso(uso UsoGraph, origen string, now time.Time) (time.Duration, string) {
+	if uso.PausadoHasta != nil && uso.PausadoHasta.After(now) {
+		return uso.PausadoHasta.Sub(now), fmt.Sprintf("Facebook pidió esperar por el uso %s de la Graph API", origen)
+	}
+
+	var hasta time.Time
+	switch {
+	case uso.Porcentaje >= m.pausa:
+		hasta = uso.Actualizado.Add(pausaUsoAlto)
+	case uso.Porcentaje >= m.freno:
+		hasta = uso.Actualizado.Add(esperaUsoFreno)
+	default:
+		return 0, ""
+	}
+	if !hasta.After(now) {
+		return 0, ""
+	}
+	return hasta.Sub(now), fmt.Sprintf("el uso %s de la Graph API llegó al %d%%", origen, uso.Porcentaje)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestMonitorUsoGraph(t *testing.T) {
+	t.Setenv("FACEBOOK_USO_FRENO_PORCENTAJE", "80")
+	t.Setenv("FACEBOOK_USO_PAUSA_PORCENTAJE", "95")
+	ahora := time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC)
+
+	encabezados := func(pares ...string) http.Header {
+		header := http.Header{}
+		for i := 0; i < len(pares); i += 2 {
+			header.Set(pares[i], pares[i+1])
+		}
+		return header
+	}
+
+	t.Run("uso bajo", func(t *testing.T) {
+		uso := NewMonitorUsoGraph()
+		uso.Record("token", encabezados("X-App-Usage", `{"call_count":12,"total_cputime":30,"total_time":25}`), ahora)
+
+		if app := uso.App(); app.CallCount != 12 || app.TotalCPUTime != 30 || app.Porcentaje != 30 {
+			t.Errorf("uso de la aplicación %+v", app)
+		}
+		if espera, _ := uso.Wait("token", ahora); espera != 0 {
+			t.Errorf("espera %s con uso bajo", espera)
+		}
+	})
+
+	t.Run("freno y pausa de la aplicación", func(t *testing.T) {
+		uso := NewMonitorUsoGraph()
+		uso.Record("token", encabezados("X-App-Usage", `{"call_count":85,"total_cputime":10,"total_time":10}`), ahora)
+		if espera, _ := uso.Wait("otro-token", ahora.Add(30*time.Second)); espera != esperaUsoFreno-30*time.Second {
+			t.Errorf("espera %s con uso al 85%%, se esperaba espaciar las llamadas", espera)
+		}
+		if espera, _ := uso.Wait("otro-token", ahora.Add(esperaUsoFreno)); espera != 0 {
+			t.Errorf("espera %s después del espaciado", espera)
+		}
+
+		uso.Record("", encabezados("X-App-Usage", `{"call_count":40,"total_cputime":97,"total_time":60}`), ahora)
+		espera, motivo := uso.Wait("token", ahora)
+		if espera != pausaUsoAlto || motivo != "el uso de la aplicación de la Graph API llegó al 97%" {
+			t.Errorf("espera %s (%q) con uso al 97%%", espera, motivo)
+		}
+	})
+
+	t.Run("uso de la cuenta", func(t *testing.T) {
+		uso := NewMonitorUsoGraph()
+		uso.Record("token", encabezados("X-Business-Use-Case-Usage",
+			`{"123":[{"type":"pages","call_count":20,"total_cputime":5,"total_time":5,"estimated_time_to_regain_access":0},{"type":"groups","call_count":96,"total_cputime":5,"total_time":5,"estimated_time_to_regain_access":0}]}`), ahora)
+
+		if cuenta := uso.Token("token"); cuenta.Porcentaje != 96 {
+			t.Errorf("uso de la cuenta %+v", cuenta)
+		}
+		if espera, _ := uso.Wait("token", ahora); espera != pausaUsoAlto {
+			t.Errorf("espera %s con la cuenta al 96%%", espera)
+		}
+		if espera, _ := uso.Wait("otro-token", ahora); espera != 0 {
+			t.Errorf("el uso de una cuenta frenó a otra: espera %s", espera)
+		}
+	})
+
+	t.Run("Facebook pide esperar", func(t *testing.T) {
+		uso := NewMonitorUsoGraph()
+		uso.Record("token", encabezados("X-Business-Use-Case-Usage",
+			`{"123":[{"type":"pages","call_count":100,"total_cputime":5,"total_time":5,"estimated_time_to_regain_access":45}]}`), ahora)
+		if espera, _ := uso.Wait("token", ahora); espera != 45*time.Minute {
+			t.Errorf("espera %s, se esperaba estimated_time_to_regain_access", espera)
+		}
+
+		// Una lectura posterior con uso bajo no levanta la pausa pedida
+		uso.Record("token", encabezados("X-Business-Use-Case-Usage", `{"123":[{"type":"pages","call_count":10}]}`), ahora.Add(time.Minute))
+		if espera, _ := uso.Wait("token", ahora.Add(time.Minute)); espera != 44*time.Minute {
+			t.Errorf("espera %s tras una lectura con uso bajo", espera)
+		}
+
+		uso.Record("", encabezados("Retry-After", "120"), ahora)
+		if espera, _ := uso.Wait("otro-token", ahora); espera != 2*time.Minute {
+			t.Errorf("espera %s, se esperaba la del Retry-After", espera)
+		}
+	})
+
+	t.Run("descarta el uso de tokens viejos", func(t *testing.T) {
+		uso := NewMonitorUsoGraph()
+		uso.Record("viejo", encabezados("X-Business-Use-Case-Usage", `{"123":[{"type":"pages","call_count":50}]}`), ahora)
+		uso.Record("pausado", encabezados("Retry-After", "7200"), ahora)
+
+		// La siguiente lectura pasada la ventana descarta el uso del token
+		// viejo pero conserva la pausa que sigue vigente
+		uso.Record("nuevo", encabezados("X-Business-Use-Case-Usage", `{"123":[{"type":"pages","call_count":10}]}`), ahora.Add(ventanaUsoGraph))
+		if len(uso.tokens) != 2 {
+			t.Errorf("%d tokens registrados, se esperaban 2", len(uso.tokens))
+		}
+		if viejo := uso.Token("viejo"); viejo.Porcentaje != 0 {
+			t.Errorf("se conservó el uso del token viejo: %+v", viejo)
+		}
+		if espera, _ := uso.Wait("pausado", ahora.Add(ventanaUsoGraph)); espera != time.Hour {
+			t.Errorf("espera %s, se esperaba conservar la pausa", espera)
+		}
+	})
+}
+
+func TestSchedulerEsperaPorUsoGraph(t *testing.T) {
+	inicio := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+	sim := nuevaSimulacion(t, inicio, 1)
+	t.Setenv("FACEBOOK_USO_PAUSA_PORCENTAJE", "95")
+	sim.facebook.uso = NewMonitorUsoGraph()
+	sim.programar(ProgramacionPublicacion{
+		Frecuencia:  "diaria",
+		Horarios:    []ConfiguracionHorario{{Hora: 9}},
+		FechaInicio: inicio,
+	})
+
+	// La última respuesta antes del horario informó la cuota casi agotada
+	header := http.Header{}
+	header.Set("X-App-Usage", `{"call_count":98,"total_cputime":20,"total_time":20}`)
+	sim.facebook.uso.Record("token", header, time.Date(2025, 3, 3, 8, 55, 0, 0, time.UTC))
+
+	sim.avanzar(inicio.AddDate(0, 0, 2))
+
+	compararFechas(t, sim.fechasPublicadas(sim.grupos[0], time.UTC), []string{
+		"2025-03-03 09:10", "2025-03-04 09:00",
+	})
+	if trabajo := sim.cola.trabajos[0]; trabajo.Estado != TrabajoCompletado {
+		t.Errorf("trabajo pospuesto terminó %s", trabajo.Estado)
+	}
+}
@@ -26,6 +26,11 @@ func RegisterHandler(authService *AuthService) gin.HandlerFunc {
 			return
 		}
 
+		if _, err := cargarZonaHoraria(req.ZonaHoraria); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
 		// Crear usuario
 		usuario, err := authService.CreateUser(req)
 		if err != nil {
@@ -110,6 +115,8 @@ func ProfileHandler(authService *AuthService) gin.HandlerFunc {
 			"activo":             usuario.Activo,
 			"facebook_user_id":   usuario.FacebookUserID,
 			"facebook_conectado": usuario.FacebookAccessToken != "",
+			"zona_horaria":       usuario.ZonaHoraria,
+			"restricciones":      usuario.Restricciones,
 			"created_at":         usuario.CreatedAt,
 		}
 
@@ -117,6 +124,44 @@ func ProfileHandler(authService *AuthService) gin.HandlerFunc {
 	}
 }
 
+// UpdateProfileHandler actualiza el nombre, la zona horaria y las restricciones del usuario actual
+func UpdateProfileHandler(authService *AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := GetUserIDFromContext(c)
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+			return
+		}
+
+		var req UpdateProfileRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Datos inválidos"})
+			return
+		}
+
+		if req.ZonaHoraria != nil {
+			if _, err := cargarZonaHoraria(*req.ZonaHoraria); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		if req.Restricciones != nil {
+			if err := validarRestricciones(*req.Restricciones); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		if err := authService.UpdateProfile(userID, req); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al actualizar el perfil"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Perfil actualizado exitosamente"})
+	}
+}
+
 // RefreshTokenHandler renueva el token JWT
 func RefreshTokenHandler(authService *AuthService) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// tamanoMaximoCalendario limita el tamaño de los archivos .ics importados
+const tamanoMaximoCalendario = 1 << 20
+
+// ImportarFeriadosPerfilHandler agrega a las fechas bloqueadas del usuario
+// los eventos de un calendario .ics
+func ImportarFeriadosPerfilHandler(authService *AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := GetUserIDFromContext(c)
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+			return
+		}
+
+		usuario, err := authService.GetUserByID(userID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Usuario no encontrado"})
+			return
+		}
+
+		fechas, err := leerCalendarioICS(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		restricciones := usuario.Restricciones
+		restricciones.FechasBloqueadas = combinarFechasBloqueadas(restricciones.FechasBloqueadas, fechas, c.Query("reemplazar") == "true")
+
+		req := UpdateProfileRequest{Restricciones: &restricciones}
+		if err := authService.UpdateProfile(userID, req); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al actualizar el perfil"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"importadas":        len(fechas),
+			"fechas_bloqueadas": restricciones.FechasBloqueadas,
+		})
+	}
+}
+
+// importarFeriadosProgramacion agrega a las fechas bloqueadas de una
+// programación los eventos de un calendario .ics
+func importarFeriadosProgramacion(c *gin.Context) {
+	id := c.Param("id")
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	collection := database.Collection("programaciones")
+
+	var programacion ProgramacionPublicacion
+	if err := collection.FindOne(context.Background(), bson.M{"_id": objectID}).Decode(&programacion); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Programación no encontrada"})
+		return
+	}
+
+	fechas, err := leerCalendarioICS(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	bloqueadas := combinarFechasBloqueadas(programacion.Restricciones.FechasBloqueadas, fechas, c.Query("reemplazar") == "true")
+
+	_, err = collection.UpdateOne(context.Background(), bson.M{"_id": objectID}, bson.M{"$set": bson.M{
+		"restricciones.fechas_bloqueadas": bloqueadas,
+	}})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"importadas":        len(fechas),
+		"fechas_bloqueadas": bloqueadas,
+	})
+}
+
+// leerCalendarioICS lee el calendario del campo "archivo" de un formulario
+// multipart o, si no lo hay, del cuerpo de la petición
+func leerCalendarioICS(c *gin.Context) ([]FechaBloqueada, error) {
+	var origen io.Reader = c.Request.Body
+
+	if archivo, err := c.FormFile("archivo"); err == nil {
+		f, err := archivo.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		origen = f
+	}
+
+	contenido, err := io.ReadAll(io.LimitReader(origen, tamanoMaximoCalendario+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(contenido) > tamanoMaximoCalendario {
+		return nil, errors.New("el calendario supera el tamaño máximo de 1 MB")
+	}
+
+	return parsearICS(bytes.NewReader(contenido))
+}
+
+// combinarFechasBloqueadas agrega las fechas importadas a las existentes,
+// sin duplicar rangos, o las reemplaza
+func combinarFechasBloqueadas(actuales, importadas []FechaBloqueada, reemplazar bool) []FechaBloqueada {
+	if reemplazar {
+		return importadas
+	}
+
+	combinadas := append([]FechaBloqueada{}, actuales...)
+	for _, nueva := range importadas {
+		repetida := false
+		for _, f := range actuales {
+			if f.Desde == nueva.Desde && f.Hasta == nueva.Hasta && f.Anual == nueva.Anual {
+				repetida = true
+				break
+			}
+		}
+		if !repetida {
+			combinadas = append(combinadas, nueva)
+		}
+	}
+
+	return combinadas
+}
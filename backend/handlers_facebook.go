@@ -1,13 +1,18 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-// FacebookConnectHandler conecta la cuenta de Facebook del usuario
+// FacebookConnectHandler conecta la cuenta de Facebook del usuario. El token
+// recibido del login se intercambia por uno de larga duración, que es el que
+// se guarda y renueva el scheduler antes de que venza.
 func FacebookConnectHandler(authService *AuthService, facebookService *FacebookService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := GetUserIDFromContext(c)
@@ -35,22 +40,26 @@ func FacebookConnectHandler(authService *AuthService, facebookService *FacebookS
 		// Obtener información del usuario de Facebook
 		userInfo, err := facebookService.GetUserInfo(req.AccessToken)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Error al obtener información de Facebook"})
+			responderErrorGraph(c, "Error al obtener información de Facebook", err)
 			return
 		}
 
-		// Obtener información del token
-		tokenInfo, err := facebookService.GetTokenInfo(req.AccessToken)
+		// Intercambiar el token por uno de larga duración
+		tokenLargo, err := facebookService.RefreshLongLivedToken(req.AccessToken)
+		if errors.Is(err, ErrFacebookAppNoConfigurada) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "La aplicación de Facebook no está configurada"})
+			return
+		}
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Error al validar token"})
+			responderErrorGraph(c, "Error al obtener un token de larga duración", err)
 			return
 		}
 
 		// Calcular fecha de expiración
-		expiration := time.Now().Add(time.Duration(tokenInfo.ExpiresIn) * time.Second)
+		expiration := expiracionToken(tokenLargo, time.Now())
 
 		// Actualizar usuario con información de Facebook
-		err = authService.UpdateFacebookTokens(userID, userInfo.ID, req.AccessToken, expiration)
+		err = authService.UpdateFacebookTokens(userID, userInfo.ID, tokenLargo.AccessToken, expiration)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al guardar información de Facebook"})
 			return
@@ -88,14 +97,14 @@ func FacebookGroupsHandler(authService *AuthService, facebookService *FacebookSe
 
 		// Verificar si el token no ha expirado
 		if time.Now().After(usuario.TokenExpiracion) {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token de Facebook expirado"})
+			responderTokenFacebookExpirado(c, "Token de Facebook expirado", nil)
 			return
 		}
 
 		// Obtener grupos de Facebook
 		groups, err := facebookService.GetUserGroups(usuario.FacebookAccessToken)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener grupos de Facebook"})
+			responderErrorGraph(c, "Error al obtener grupos de Facebook", err)
 			return
 		}
 
@@ -105,7 +114,12 @@ func FacebookGroupsHandler(authService *AuthService, facebookService *FacebookSe
 	}
 }
 
-// FacebookPostHandler publica en Facebook
+// maxTamanoFoto es el tamaño máximo de una foto subida para publicar
+const maxTamanoFoto = 10 << 20
+
+// FacebookPostHandler publica en Facebook. Acepta JSON, con image_url para
+// publicar una foto desde una URL, o multipart/form-data con el archivo de la
+// foto en el campo imagen.
 func FacebookPostHandler(authService *AuthService, facebookService *FacebookService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := GetUserIDFromContext(c)
@@ -115,16 +129,26 @@ func FacebookPostHandler(authService *AuthService, facebookService *FacebookServ
 		}
 
 		var req struct {
-			GroupID string `json:"group_id" binding:"required"`
-			Message string `json:"message" binding:"required"`
-			Link    string `json:"link"`
+			GroupID  string `json:"group_id" form:"group_id" binding:"required"`
+			Message  string `json:"message" form:"message" binding:"required"`
+			Link     string `json:"link" form:"link"`
+			ImageURL string `json:"image_url" form:"image_url"`
 		}
 
-		if err := c.ShouldBindJSON(&req); err != nil {
+		if err := c.ShouldBind(&req); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Datos inválidos"})
 			return
 		}
 
+		var imagen []byte
+		if c.ContentType() == "multipart/form-data" {
+			var err error
+			if imagen, err = leerFotoFormulario(c); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
 		// Obtener usuario con token de Facebook
 		usuario, err := authService.GetUserByID(userID)
 		if err != nil {
@@ -139,30 +163,101 @@ func FacebookPostHandler(authService *AuthService, facebookService *FacebookServ
 
 		// Verificar si el token no ha expirado
 		if time.Now().After(usuario.TokenExpiracion) {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token de Facebook expirado"})
+			responderTokenFacebookExpirado(c, "Token de Facebook expirado", nil)
 			return
 		}
 
 		// Crear petición de publicación
 		postReq := FacebookPostRequest{
-			Message: req.Message,
-			Link:    req.Link,
+			Message:  req.Message,
+			Link:     req.Link,
+			ImageURL: req.ImageURL,
 		}
 
 		// Publicar en Facebook
-		response, err := facebookService.PostToGroup(usuario.FacebookAccessToken, req.GroupID, postReq)
+		var response *FacebookPostResponse
+		switch {
+		case imagen != nil:
+			response, err = facebookService.PostWithPhoto(usuario.FacebookAccessToken, req.GroupID, postReq, imagen)
+		case postReq.ImageURL != "":
+			response, err = facebookService.PostPhotoFromURL(usuario.FacebookAccessToken, req.GroupID, postReq)
+		default:
+			response, err = facebookService.PostToGroup(usuario.FacebookAccessToken, req.GroupID, postReq)
+		}
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al publicar en Facebook: " + err.Error()})
+			responderErrorGraph(c, "Error al publicar en Facebook: "+err.Error(), err)
 			return
 		}
 
 		c.JSON(http.StatusOK, gin.H{
 			"message": "Publicación exitosa",
-			"post_id": response.ID,
+			"post_id": idPublicacionFeed(response),
 		})
 	}
 }
 
+// leerFotoFormulario lee la foto del campo imagen de un formulario
+// multipart; devuelve nil si el formulario no incluye una foto
+func leerFotoFormulario(c *gin.Context) ([]byte, error) {
+	archivo, err := c.FormFile("imagen")
+	if err == http.ErrMissingFile {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if archivo.Size > maxTamanoFoto {
+		return nil, fmt.Errorf("la imagen supera el máximo de %d MB", maxTamanoFoto>>20)
+	}
+
+	f, err := archivo.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return io.ReadAll(f)
+}
+
+// codigoFacebookTokenExpirado identifica las respuestas que piden volver a
+// conectar Facebook. No se usa 401 porque el frontend lo interpreta como la
+// sesión de la aplicación vencida y cierra la sesión.
+const codigoFacebookTokenExpirado = "facebook_token_expirado"
+
+// responderTokenFacebookExpirado responde 409 con codigoFacebookTokenExpirado
+// y, si lo hay, el detalle del error de la Graph API
+func responderTokenFacebookExpirado(c *gin.Context, mensaje string, graphErr *GraphError) {
+	respuesta := gin.H{"error": mensaje, "codigo": codigoFacebookTokenExpirado}
+	if graphErr != nil {
+		respuesta["facebook"] = graphErr
+	}
+	c.JSON(http.StatusConflict, respuesta)
+}
+
+// responderErrorGraph responde un error de una llamada a Facebook. Los errores
+// de la Graph API usan un estado según su tipo e incluyen el detalle en el
+// campo facebook; los demás responden 500.
+func responderErrorGraph(c *gin.Context, mensaje string, err error) {
+	graphErr := comoGraphError(err)
+	if graphErr == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": mensaje})
+		return
+	}
+
+	estado := http.StatusBadGateway
+	switch {
+	case graphErr.IsTokenExpired():
+		responderTokenFacebookExpirado(c, mensaje, graphErr)
+		return
+	case graphErr.IsPermissionDenied():
+		estado = http.StatusForbidden
+	case graphErr.IsRateLimited():
+		estado = http.StatusTooManyRequests
+	}
+
+	c.JSON(estado, gin.H{"error": mensaje, "facebook": graphErr})
+}
+
 // FacebookDisconnectHandler desconecta la cuenta de Facebook
 func FacebookDisconnectHandler(authService *AuthService) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -185,7 +280,8 @@ func FacebookDisconnectHandler(authService *AuthService) gin.HandlerFunc {
 	}
 }
 
-// FacebookStatusHandler verifica el estado de conexión con Facebook
+// FacebookStatusHandler verifica el estado de conexión con Facebook e informa
+// el uso de la cuota de la Graph API de la aplicación y de la cuenta
 func FacebookStatusHandler(authService *AuthService, facebookService *FacebookService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := GetUserIDFromContext(c)
@@ -221,6 +317,17 @@ func FacebookStatusHandler(authService *AuthService, facebookService *FacebookSe
 			response["expires_at"] = usuario.TokenExpiracion
 		}
 
+		app, cuenta := facebookService.Usage(usuario.FacebookAccessToken)
+		uso := gin.H{"app": app}
+		if connected {
+			uso["cuenta"] = cuenta
+		}
+		if espera, motivo := facebookService.UsageWait(usuario.FacebookAccessToken, time.Now()); espera > 0 {
+			uso["publicaciones_pausadas_hasta"] = time.Now().Add(espera)
+			uso["motivo"] = motivo
+		}
+		response["uso_graph"] = uso
+
 		c.JSON(http.StatusOK, response)
 	}
 }
@@ -2,12 +2,16 @@ package main
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // Handlers para Grupos de Facebook
@@ -36,9 +40,15 @@ func createGrupo(c *gin.Context) {
 		return
 	}
 
+	if err := validarLimitesGrupo(grupo); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	grupo.ID = primitive.NewObjectID()
 	grupo.CreatedAt = time.Now()
 	grupo.Activo = true
+	grupo.UltimaPublicacion = nil
 
 	collection := database.Collection("grupos")
 	_, err := collection.InsertOne(context.Background(), grupo)
@@ -50,6 +60,60 @@ func createGrupo(c *gin.Context) {
 	c.JSON(http.StatusCreated, grupo)
 }
 
+func updateGrupo(c *gin.Context) {
+	id := c.Param("id")
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var grupo GrupoFacebook
+	if err := c.ShouldBindJSON(&grupo); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := validarLimitesGrupo(grupo); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// La última publicación la mantiene el scheduler
+	update := bson.M{"$set": bson.M{
+		"nombre":           grupo.Nombre,
+		"facebook_id":      grupo.FacebookID,
+		"url":              grupo.URL,
+		"descripcion":      grupo.Descripcion,
+		"activo":           grupo.Activo,
+		"intervalo_minimo": grupo.IntervaloMinimo,
+		"maximo_diario":    grupo.MaximoDiario,
+	}}
+
+	collection := database.Collection("grupos")
+	result, err := collection.UpdateOne(context.Background(), bson.M{"_id": objectID}, update)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if result.MatchedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Grupo no encontrado"})
+		return
+	}
+
+	grupo.ID = objectID
+	c.JSON(http.StatusOK, grupo)
+}
+
+// validarLimitesGrupo verifica los límites anti-spam propios del grupo
+func validarLimitesGrupo(grupo GrupoFacebook) error {
+	if grupo.IntervaloMinimo < 0 || grupo.MaximoDiario < 0 {
+		return errors.New("los límites del grupo no pueden ser negativos")
+	}
+	return nil
+}
+
 // Handlers para Programaciones
 func getProgramaciones(c *gin.Context) {
 	collection := database.Collection("programaciones")
@@ -66,7 +130,41 @@ func getProgramaciones(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, programaciones)
+	ids := make([]primitive.ObjectID, len(programaciones))
+	for i, prog := range programaciones {
+		ids[i] = prog.ID
+	}
+
+	realizadas, err := contarEjecucionesExitosas(database.Collection("ejecuciones"), ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	zonas := make(map[string]*time.Location)
+	resumenes := make([]ProgramacionResumen, len(programaciones))
+	for i, prog := range programaciones {
+		clave := prog.UserID.Hex() + "/" + prog.ZonaHoraria
+		loc, ok := zonas[clave]
+		if !ok {
+			loc = resolverZonaHoraria(prog)
+			zonas[clave] = loc
+		}
+
+		resumenes[i] = ProgramacionResumen{
+			ProgramacionPublicacion: programacionEnZona(prog, loc),
+			PublicacionesRealizadas: realizadas[prog.ID],
+		}
+		if prog.CantidadPublicaciones > 0 {
+			restantes := prog.CantidadPublicaciones - realizadas[prog.ID]
+			if restantes < 0 {
+				restantes = 0
+			}
+			resumenes[i].PublicacionesRestantes = &restantes
+		}
+	}
+
+	c.JSON(http.StatusOK, resumenes)
 }
 
 func createProgramacion(c *gin.Context) {
@@ -76,6 +174,18 @@ func createProgramacion(c *gin.Context) {
 		return
 	}
 
+	if programacion.Frecuencia == "" {
+		programacion.Frecuencia = string(FrecuenciaDiaria)
+	}
+
+	asignarPropietario(c, &programacion)
+	completarPublicacionPrincipal(&programacion)
+
+	if err := validarProgramacion(programacion); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	programacion.ID = primitive.NewObjectID()
 	programacion.CreatedAt = time.Now()
 	programacion.UpdatedAt = time.Now()
@@ -91,7 +201,7 @@ func createProgramacion(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusCreated, programacion)
+	c.JSON(http.StatusCreated, programacionEnZona(programacion, resolverZonaHoraria(programacion)))
 }
 
 func updateProgramacion(c *gin.Context) {
@@ -108,24 +218,63 @@ func updateProgramacion(c *gin.Context) {
 		return
 	}
 
+	if programacion.Frecuencia == "" {
+		programacion.Frecuencia = string(FrecuenciaDiaria)
+	}
+
+	asignarPropietario(c, &programacion)
+	completarPublicacionPrincipal(&programacion)
+
+	if err := validarProgramacion(programacion); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	programacion.UpdatedAt = time.Now()
 
+	campos, err := camposEditablesProgramacion(programacion)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	collection := database.Collection("programaciones")
 	filter := bson.M{"_id": objectID}
-	update := bson.M{"$set": programacion}
+	update := bson.M{"$set": campos}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
 
-	result, err := collection.UpdateOne(context.Background(), filter, update)
+	var actualizada ProgramacionPublicacion
+	err = collection.FindOneAndUpdate(context.Background(), filter, update, opts).Decode(&actualizada)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Programación no encontrada"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	if result.MatchedCount == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Programación no encontrada"})
-		return
+	c.JSON(http.StatusOK, programacionEnZona(actualizada, resolverZonaHoraria(actualizada)))
+}
+
+// camposEditablesProgramacion arma los campos que actualiza el PUT de una
+// programación. El estado y la fecha de reanudación no se editan: solo
+// cambian con las acciones de pausar y reanudar, que mantienen consistente
+// la recuperación de horarios.
+func camposEditablesProgramacion(programacion ProgramacionPublicacion) (bson.M, error) {
+	datos, err := bson.Marshal(programacion)
+	if err != nil {
+		return nil, err
 	}
 
-	c.JSON(http.StatusOK, programacion)
+	var campos bson.M
+	if err := bson.Unmarshal(datos, &campos); err != nil {
+		return nil, err
+	}
+	delete(campos, "_id")
+	delete(campos, "estado")
+	delete(campos, "reanudada_en")
+	return campos, nil
 }
 
 func deleteProgramacion(c *gin.Context) {
@@ -150,3 +299,282 @@ func deleteProgramacion(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "Programación eliminada exitosamente"})
 }
+
+// getEjecucionesProgramacion lista las ejecuciones registradas de una programación
+func getEjecucionesProgramacion(c *gin.Context) {
+	id := c.Param("id")
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "fecha_programada", Value: -1}}).
+		SetLimit(100)
+
+	collection := database.Collection("ejecuciones")
+	cursor, err := collection.Find(context.Background(), bson.M{"programacion_id": objectID}, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	var ejecuciones []Ejecucion
+	if err = cursor.All(context.Background(), &ejecuciones); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, ejecuciones)
+}
+
+// validarProgramacion verifica la frecuencia o el disparador, los horarios, las publicaciones y los límites de una programación
+func validarProgramacion(programacion ProgramacionPublicacion) error {
+	if _, err := cargarZonaHoraria(programacion.ZonaHoraria); err != nil {
+		return err
+	}
+
+	// Las programaciones con disparador no usan la recurrencia
+	if programacion.Disparador != nil {
+		if err := validarDisparador(programacion.Disparador); err != nil {
+			return err
+		}
+	} else if _, err := ParseRecurrencia(programacion, resolverZonaHoraria(programacion)); err != nil {
+		return err
+	}
+
+	if err := validarPoliticaRecuperacion(programacion); err != nil {
+		return err
+	}
+
+	if err := validarRestricciones(programacion.Restricciones); err != nil {
+		return err
+	}
+
+	if err := validarRotacion(programacion); err != nil {
+		return err
+	}
+
+	if programacion.CantidadPublicaciones < 0 {
+		return errors.New("la cantidad de publicaciones no puede ser negativa")
+	}
+
+	if programacion.FechaFin != nil && programacion.FechaFin.Before(programacion.FechaInicio) {
+		return errors.New("la fecha de fin debe ser posterior a la fecha de inicio")
+	}
+
+	return nil
+}
+
+// asignarPropietario asocia la programación al usuario autenticado si no indica otro
+func asignarPropietario(c *gin.Context, programacion *ProgramacionPublicacion) {
+	if !programacion.UserID.IsZero() {
+		return
+	}
+
+	if userID, exists := GetUserIDFromContext(c); exists {
+		if objectID, err := primitive.ObjectIDFromHex(userID); err == nil {
+			programacion.UserID = objectID
+		}
+	}
+}
+
+// Límites de la vista previa de programaciones
+const (
+	previewHorizontePorDefecto = 30 * 24 * time.Hour
+	previewLimitePorDefecto    = 100
+	previewLimiteMaximo        = 1000
+)
+
+// PreviewProgramacionHandler muestra los próximos horarios de una programación
+// guardada sin publicar nada
+func PreviewProgramacionHandler(schedulerService *SchedulerService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+			return
+		}
+
+		var programacion ProgramacionPublicacion
+		err = database.Collection("programaciones").FindOne(context.Background(), bson.M{"_id": objectID}).Decode(&programacion)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Programación no encontrada"})
+			return
+		}
+
+		responderPreview(c, schedulerService, programacion)
+	}
+}
+
+// PreviewNuevaProgramacionHandler muestra los horarios que tendría una
+// programación todavía no guardada
+func PreviewNuevaProgramacionHandler(schedulerService *SchedulerService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var programacion ProgramacionPublicacion
+		if err := c.ShouldBindJSON(&programacion); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if programacion.Frecuencia == "" {
+			programacion.Frecuencia = string(FrecuenciaDiaria)
+		}
+
+		asignarPropietario(c, &programacion)
+		completarPublicacionPrincipal(&programacion)
+
+		if err := validarProgramacion(programacion); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		responderPreview(c, schedulerService, programacion)
+	}
+}
+
+// responderPreview lee los parámetros until y limite y responde con la vista previa
+func responderPreview(c *gin.Context, schedulerService *SchedulerService, programacion ProgramacionPublicacion) {
+	hasta := time.Now().Add(previewHorizontePorDefecto)
+	if until := c.Query("until"); until != "" {
+		fecha, err := parsearFechaPreview(until, resolverZonaHoraria(programacion))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "until inválido, use RFC3339 o AAAA-MM-DD"})
+			return
+		}
+		hasta = fecha
+	}
+
+	limite := previewLimitePorDefecto
+	if valor := c.Query("limite"); valor != "" {
+		n, err := strconv.Atoi(valor)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limite inválido"})
+			return
+		}
+		limite = n
+	}
+	if limite > previewLimiteMaximo {
+		limite = previewLimiteMaximo
+	}
+
+	vista, err := schedulerService.Preview(programacion, hasta, limite)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, vista)
+}
+
+// parsearFechaPreview acepta una fecha RFC3339 o un día AAAA-MM-DD, que se
+// toma completo en la zona de la programación
+func parsearFechaPreview(valor string, loc *time.Location) (time.Time, error) {
+	if fecha, err := time.Parse(time.RFC3339, valor); err == nil {
+		return fecha, nil
+	}
+
+	dia, err := time.ParseInLocation("2006-01-02", valor, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return dia.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+}
+
+// EjecutarProgramacionHandler publica la programación ahora, fuera de su recurrencia
+func EjecutarProgramacionHandler(schedulerService *SchedulerService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+			return
+		}
+
+		fecha, err := schedulerService.RunNow(objectID)
+		if err != nil {
+			responderErrorAccion(c, err)
+			return
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"message":          "Publicación encolada",
+			"fecha_programada": fecha,
+		})
+	}
+}
+
+// PausarProgramacionHandler pausa una programación activa
+func PausarProgramacionHandler(schedulerService *SchedulerService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+			return
+		}
+
+		programacion, err := schedulerService.Pause(objectID)
+		if err != nil {
+			responderErrorAccion(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, programacionEnZona(*programacion, resolverZonaHoraria(*programacion)))
+	}
+}
+
+// ReanudarProgramacionHandler reactiva una programación pausada
+func ReanudarProgramacionHandler(schedulerService *SchedulerService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+			return
+		}
+
+		programacion, err := schedulerService.Resume(objectID)
+		if err != nil {
+			responderErrorAccion(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, programacionEnZona(*programacion, resolverZonaHoraria(*programacion)))
+	}
+}
+
+// OmitirSiguienteHandler omite el próximo horario de una programación
+func OmitirSiguienteHandler(schedulerService *SchedulerService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+			return
+		}
+
+		fecha, err := schedulerService.SkipNext(objectID)
+		if err != nil {
+			responderErrorAccion(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":       "Horario omitido",
+			"fecha_omitida": fecha,
+		})
+	}
+}
+
+// responderErrorAccion traduce los errores de las acciones sobre programaciones
+func responderErrorAccion(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrProgramacionNoEncontrada):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Programación no encontrada"})
+	case errors.Is(err, ErrEstadoProgramacion), errors.Is(err, ErrSinProximosHorarios), errors.Is(err, ErrProgramacionPorEventos):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrSinGruposObjetivo):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCamposEditablesProgramacion(t *testing.T) {
+	reanudada := time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC)
+	prog := ProgramacionPublicacion{
+		ID:          primitive.NewObjectID(),
+		Frecuencia:  "diaria",
+		Estado:      "activa",
+		ReanudadaEn: &reanudada,
+	}
+
+	campos, err := camposEditablesProgramacion(prog)
+	if err != nil {
+		t.Fatalf("camposEditablesProgramacion: %v", err)
+	}
+
+	// El estado solo cambia con las acciones de pausar y reanudar
+	for _, campo := range []string{"_id", "estado", "reanudada_en"} {
+		if _, ok := campos[campo]; ok {
+			t.Errorf("el PUT actualiza %q", campo)
+		}
+	}
+	if campos["frecuencia"] != "diaria" {
+		t.Errorf("frecuencia %v, se esperaba diaria", campos["frecuencia"])
+	}
+}
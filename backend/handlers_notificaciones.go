@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// getNotificaciones lista las notificaciones del usuario, de la más reciente
+// a la más antigua; con no_leidas=true solo las que no leyó
+func getNotificaciones(c *gin.Context) {
+	userID, exists := GetUserIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de usuario inválido"})
+		return
+	}
+
+	filter := bson.M{"user_id": objectID}
+	if c.Query("no_leidas") == "true" {
+		filter["leida"] = false
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetLimit(100)
+
+	collection := database.Collection("notificaciones")
+	cursor, err := collection.Find(context.Background(), filter, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	notificaciones := []Notificacion{}
+	if err = cursor.All(context.Background(), &notificaciones); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, notificaciones)
+}
+
+// marcarNotificacionLeida marca como leída una notificación del usuario
+func marcarNotificacionLeida(c *gin.Context) {
+	userID, exists := GetUserIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de usuario inválido"})
+		return
+	}
+
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	collection := database.Collection("notificaciones")
+	filter := bson.M{"_id": objectID, "user_id": userObjectID}
+	result, err := collection.UpdateOne(context.Background(), filter, bson.M{"$set": bson.M{"leida": true}})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if result.MatchedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notificación no encontrada"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notificación marcada como leída"})
+}
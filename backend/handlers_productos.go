@@ -2,12 +2,15 @@ package main
 
 import (
 	"context"
+	"log"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 func getProductos(c *gin.Context) {
@@ -49,38 +52,48 @@ func createProducto(c *gin.Context) {
 	c.JSON(http.StatusCreated, producto)
 }
 
-func updateProducto(c *gin.Context) {
-	id := c.Param("id")
-	objectID, err := primitive.ObjectIDFromHex(id)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
-		return
+// UpdateProductoHandler actualiza un producto y publica los eventos de
+// reposición o baja de precio que generó el cambio
+func UpdateProductoHandler(schedulerService *SchedulerService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		objectID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+			return
+		}
+
+		var producto Producto
+		if err := c.ShouldBindJSON(&producto); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		producto.UpdatedAt = time.Now()
+
+		collection := database.Collection("productos")
+		filter := bson.M{"_id": objectID}
+		update := bson.M{"$set": producto}
+
+		// El documento anterior permite detectar la reposición y la baja de precio
+		var anterior Producto
+		err = collection.FindOneAndUpdate(context.Background(), filter, update, options.FindOneAndUpdate().SetReturnDocument(options.Before)).Decode(&anterior)
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Producto no encontrado"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		// El producto ya quedó actualizado: un error al guardar los eventos solo se registra
+		if err := schedulerService.PublishProductEvents(detectarEventosProducto(anterior, producto)); err != nil {
+			log.Printf("Error guardando eventos del producto %s: %v", id, err)
+		}
+
+		c.JSON(http.StatusOK, producto)
 	}
-
-	var producto Producto
-	if err := c.ShouldBindJSON(&producto); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	producto.UpdatedAt = time.Now()
-
-	collection := database.Collection("productos")
-	filter := bson.M{"_id": objectID}
-	update := bson.M{"$set": producto}
-
-	result, err := collection.UpdateOne(context.Background(), filter, update)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	if result.MatchedCount == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Producto no encontrado"})
-		return
-	}
-
-	c.JSON(http.StatusOK, producto)
 }
 
 func deleteProducto(c *gin.Context) {
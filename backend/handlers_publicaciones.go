@@ -2,12 +2,16 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 func getPublicaciones(c *gin.Context) {
@@ -35,6 +39,9 @@ func createPublicacion(c *gin.Context) {
 		return
 	}
 
+	completarVariantes(&publicacion)
+	publicacion.VariantesGanadoras = nil
+
 	publicacion.ID = primitive.NewObjectID()
 	publicacion.CreatedAt = time.Now()
 	publicacion.UpdatedAt = time.Now()
@@ -61,30 +68,64 @@ func updatePublicacion(c *gin.Context) {
 		return
 	}
 
-	var publicacion Publicacion
-	if err := c.ShouldBindJSON(&publicacion); err != nil {
+	campos, err := leerCamposPublicacion(c)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	publicacion.UpdatedAt = time.Now()
-
 	collection := database.Collection("publicaciones")
 	filter := bson.M{"_id": objectID}
-	update := bson.M{"$set": publicacion}
+	update := bson.M{"$set": campos}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
 
-	result, err := collection.UpdateOne(context.Background(), filter, update)
+	var publicacion Publicacion
+	err = collection.FindOneAndUpdate(context.Background(), filter, update, opts).Decode(&publicacion)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Publicación no encontrada"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	if result.MatchedCount == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Publicación no encontrada"})
-		return
+	c.JSON(http.StatusOK, publicacion)
+}
+
+// leerCamposPublicacion arma los campos que actualiza el PUT de una
+// publicación. Las variantes y la promoción de la ganadora solo se
+// actualizan si vienen en el pedido: el formulario de edición no las envía y
+// borrarlas dejaría sin variante a su historial.
+func leerCamposPublicacion(c *gin.Context) (bson.M, error) {
+	var publicacion Publicacion
+	if err := c.ShouldBindBodyWith(&publicacion, binding.JSON); err != nil {
+		return nil, err
+	}
+	var enviados map[string]json.RawMessage
+	if err := c.ShouldBindBodyWith(&enviados, binding.JSON); err != nil {
+		return nil, err
 	}
 
-	c.JSON(http.StatusOK, publicacion)
+	completarVariantes(&publicacion)
+	publicacion.UpdatedAt = time.Now()
+
+	datos, err := bson.Marshal(publicacion)
+	if err != nil {
+		return nil, err
+	}
+	var campos bson.M
+	if err := bson.Unmarshal(datos, &campos); err != nil {
+		return nil, err
+	}
+
+	delete(campos, "_id")
+	for _, campo := range []string{"variantes", "promover_ganadora"} {
+		if _, ok := enviados[campo]; !ok {
+			delete(campos, campo)
+		}
+	}
+	return campos, nil
 }
 
 func deletePublicacion(c *gin.Context) {
@@ -109,3 +150,34 @@ func deletePublicacion(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "Publicación eliminada exitosamente"})
 }
+
+// getRendimientoVariantes compara la interacción de las variantes de una publicación en cada grupo
+func getRendimientoVariantes(c *gin.Context) {
+	id := c.Param("id")
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var publicacion Publicacion
+	collection := database.Collection("publicaciones")
+	if err := collection.FindOne(context.Background(), bson.M{"_id": objectID}).Decode(&publicacion); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Publicación no encontrada"})
+		return
+	}
+
+	rendimiento, err := reporteVariantes(newMongoHistorial(), publicacion)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"publicacion_id":      publicacion.ID,
+		"variantes":           publicacion.Variantes,
+		"promover_ganadora":   publicacion.PromoverGanadora,
+		"variantes_ganadoras": publicacion.VariantesGanadoras,
+		"rendimiento":         rendimiento,
+	})
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestLeerCamposPublicacion(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	casos := []struct {
+		nombre     string
+		cuerpo     string
+		conservar  []string // Campos que el PUT no debe tocar
+		actualizar []string
+	}{
+		{
+			// Así edita el formulario de publicaciones
+			nombre:     "sin variantes mantiene las guardadas",
+			cuerpo:     `{"titulo":"Ofertas","descripcion":"Todo a mitad de precio","estado":"activa"}`,
+			conservar:  []string{"_id", "variantes", "promover_ganadora"},
+			actualizar: []string{"titulo", "descripcion", "estado", "updated_at"},
+		},
+		{
+			nombre:     "con variantes las reemplaza",
+			cuerpo:     `{"titulo":"Ofertas","variantes":[{"titulo":"Rebajas"}],"promover_ganadora":false}`,
+			conservar:  []string{"_id"},
+			actualizar: []string{"titulo", "variantes", "promover_ganadora"},
+		},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPut, "/api/publicaciones/1", strings.NewReader(caso.cuerpo))
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			campos, err := leerCamposPublicacion(c)
+			if err != nil {
+				t.Fatalf("leerCamposPublicacion: %v", err)
+			}
+			for _, campo := range caso.conservar {
+				if _, ok := campos[campo]; ok {
+					t.Errorf("el PUT actualiza %q", campo)
+				}
+			}
+			for _, campo := range caso.actualizar {
+				if _, ok := campos[campo]; !ok {
+					t.Errorf("el PUT no actualiza %q", campo)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SchedulerStatusHandler devuelve el estado del scheduler (solo administradores)
+func SchedulerStatusHandler(schedulerService *SchedulerService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		estado, err := schedulerService.Status()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, estado)
+	}
+}
+
+// SchedulerTickHandler ejecuta un tick del scheduler de inmediato, aunque
+// esta instancia no sea la líder; el registro de ejecuciones evita encolar
+// dos veces el mismo horario
+func SchedulerTickHandler(schedulerService *SchedulerService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		duracion := schedulerService.Tick()
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":     "Tick ejecutado",
+			"duracion_ms": duracion.Milliseconds(),
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// getTrabajos lista los trabajos de la cola de publicaciones, opcionalmente
+// filtrados por estado y programación
+func getTrabajos(c *gin.Context) {
+	filter := bson.M{}
+
+	if estado := c.Query("estado"); estado != "" {
+		filter["estado"] = estado
+	}
+
+	if id := c.Query("programacion_id"); id != "" {
+		objectID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ID de programación inválido"})
+			return
+		}
+		filter["programacion_id"] = objectID
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "fecha_programada", Value: -1}}).
+		SetLimit(200)
+
+	collection := database.Collection("trabajos_publicacion")
+	cursor, err := collection.Find(context.Background(), filter, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	var trabajos []TrabajoPublicacion
+	if err = cursor.All(context.Background(), &trabajos); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, trabajos)
+}
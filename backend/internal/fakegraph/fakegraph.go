@@ -0,0 +1,546 @@
+// Package fakegraph es una Graph API de Facebook simulada sobre httptest para
+// las pruebas y el desarrollo local. Implementa los endpoints que usa el
+// backend (usuario, grupos, feed, fotos, debug_token, intercambio de tokens e
+// interacción de publicaciones), guarda en memoria lo publicado y puede
+// devolver respuestas de error con el formato de la Graph API y encabezados
+// de uso de la cuota.
+package fakegraph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DevToken es el token de acceso que registra Seed para el desarrollo local
+const DevToken = "fake-token"
+
+// Errores frecuentes de la Graph API, para usar con FailNext
+var (
+	ErrTokenExpired     = Error{Status: http.StatusBadRequest, Type: "OAuthException", Code: 190, Subcode: 463, Message: "Error validating access token: Session has expired."}
+	ErrRateLimited      = Error{Status: http.StatusBadRequest, Type: "OAuthException", Code: 4, IsTransient: true, Message: "(#4) Application request limit reached"}
+	ErrPermissionDenied = Error{Status: http.StatusForbidden, Type: "OAuthException", Code: 200, Message: "(#200) The user hasn't authorized the application to perform this action"}
+	ErrServer           = Error{Status: http.StatusInternalServerError, Type: "FacebookApiException", Code: 2, IsTransient: true, Message: "An unexpected error has occurred. Please retry your request later."}
+)
+
+// Token es un token de acceso aceptado por el servidor
+type Token struct {
+	UserID    string
+	Name      string
+	Email     string
+	ExpiresAt time.Time
+	Scopes    []string
+}
+
+// Group es un grupo en el que el usuario puede publicar
+type Group struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Privacy     string `json:"privacy"`
+	MemberCount int    `json:"member_count"`
+}
+
+// Post es una publicación creada en el feed de un grupo
+type Post struct {
+	ID            string
+	GroupID       string
+	Message       string
+	Link          string
+	AttachedMedia []string // IDs de las fotos adjuntas
+	CreatedAt     time.Time
+}
+
+// Photo es una foto subida a un grupo
+type Photo struct {
+	ID          string
+	GroupID     string
+	URL         string // Si se subió por URL
+	ContentType string // Si se subió el archivo
+	Size        int
+	Caption     string
+	Published   bool
+	PostID      string // Publicación del feed que la muestra
+}
+
+// Engagement es la interacción que informa el servidor para una publicación
+type Engagement struct {
+	Reactions int
+	Comments  int
+	Shares    int
+}
+
+// Error es una respuesta de error con el formato de la Graph API
+type Error struct {
+	Status      int
+	Message     string
+	Type        string
+	Code        int
+	Subcode     int
+	IsTransient bool
+	RetryAfter  int // Segundos del encabezado Retry-After; 0 lo omite
+}
+
+// Usage es el uso de la cuota en porcentaje que informan los encabezados
+// X-App-Usage y X-Business-Use-Case-Usage
+type Usage struct {
+	CallCount    int `json:"call_count"`
+	TotalCPUTime int `json:"total_cputime"`
+	TotalTime    int `json:"total_time"`
+	// Minutos hasta recuperar el acceso; solo en X-Business-Use-Case-Usage
+	EstimatedTimeToRegainAccess int `json:"estimated_time_to_regain_access,omitempty"`
+}
+
+// falla es un error programado para la siguiente petición que coincida
+type falla struct {
+	method string
+	path   string
+	err    Error
+}
+
+// Server es la Graph API simulada. Las rutas aceptan cualquier prefijo de
+// versión (/v18.0/me, /v19.0/me).
+type Server struct {
+	*httptest.Server
+
+	// AppID y AppSecret son las credenciales que exige el intercambio de tokens
+	AppID     string
+	AppSecret string
+
+	mu         sync.Mutex
+	tokens     map[string]Token
+	groups     []Group
+	posts      []Post
+	photos     []Photo
+	engagement map[string]Engagement
+	fallas     []falla
+	secuencia  int
+	appUsage   *Usage
+	tokenUsage map[string]Usage
+}
+
+// New inicia un servidor sin tokens ni grupos
+func New() *Server {
+	s := &Server{
+		AppID:      "fake-app",
+		AppSecret:  "fake-secret",
+		tokens:     make(map[string]Token),
+		engagement: make(map[string]Engagement),
+		tokenUsage: make(map[string]Usage),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Seed registra DevToken y dos grupos para el desarrollo local
+func (s *Server) Seed() {
+	s.AddToken(DevToken, Token{
+		UserID:    "fake-user",
+		Name:      "Usuario de Prueba",
+		Email:     "prueba@example.com",
+		ExpiresAt: time.Now().AddDate(0, 0, 60),
+		Scopes:    []string{"publish_to_groups", "groups_access_member_info"},
+	})
+	s.AddGroup(Group{ID: "fake-group-1", Name: "Ventas del Barrio", Privacy: "CLOSED", MemberCount: 1200})
+	s.AddGroup(Group{ID: "fake-group-2", Name: "Compra y Venta", Privacy: "OPEN", MemberCount: 5400})
+}
+
+// AddToken registra un token de acceso válido
+func (s *Server) AddToken(accessToken string, token Token) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[accessToken] = token
+}
+
+// AddGroup registra un grupo del usuario. Si hay grupos registrados, las
+// publicaciones en grupos desconocidos fallan.
+func (s *Server) AddGroup(group Group) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.groups = append(s.groups, group)
+}
+
+// SetEngagement fija la interacción que se informa para una publicación
+func (s *Server) SetEngagement(postID string, engagement Engagement) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.engagement[postID] = engagement
+}
+
+// FailNext hace que la siguiente petición con el método y la ruta indicados
+// (sin versión, por ejemplo "fake-group-1/feed") responda con err. Una ruta
+// vacía coincide con cualquier petición del método.
+func (s *Server) FailNext(method, path string, err Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fallas = append(s.fallas, falla{method: method, path: path, err: err})
+}
+
+// SetAppUsage hace que todas las respuestas informen el uso de la aplicación
+// en X-App-Usage
+func (s *Server) SetAppUsage(usage Usage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.appUsage = &usage
+}
+
+// SetTokenUsage hace que las respuestas a las peticiones con el token
+// informen su uso en X-Business-Use-Case-Usage
+func (s *Server) SetTokenUsage(accessToken string, usage Usage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokenUsage[accessToken] = usage
+}
+
+// Posts devuelve las publicaciones creadas
+func (s *Server) Posts() []Post {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Post(nil), s.posts...)
+}
+
+// Photos devuelve las fotos subidas
+func (s *Server) Photos() []Photo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Photo(nil), s.photos...)
+}
+
+var prefijoVersion = regexp.MustCompile(`^/v\d+\.\d+/`)
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(prefijoVersion.ReplaceAllString(r.URL.Path, "/"), "/")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			writeError(w, invalidParameter("multipart inválido: "+err.Error()))
+			return
+		}
+	} else if err := r.ParseForm(); err != nil {
+		writeError(w, invalidParameter(err.Error()))
+		return
+	}
+
+	s.writeUsage(w, r.Form.Get("access_token"))
+
+	if err, ok := s.takeFalla(r.Method, path); ok {
+		writeError(w, err)
+		return
+	}
+
+	partes := strings.Split(path, "/")
+	switch {
+	case r.Method == http.MethodGet && path == "oauth/access_token":
+		s.exchangeToken(w, r)
+	case r.Method == http.MethodGet && path == "debug_token":
+		s.debugToken(w, r)
+	case r.Method == http.MethodGet && path == "me":
+		s.me(w, r)
+	case r.Method == http.MethodGet && path == "me/groups":
+		s.myGroups(w, r)
+	case r.Method == http.MethodPost && len(partes) == 2 && partes[1] == "feed":
+		s.createPost(w, r, partes[0])
+	case r.Method == http.MethodPost && len(partes) == 2 && partes[1] == "photos":
+		s.createPhoto(w, r, partes[0])
+	case r.Method == http.MethodGet && len(partes) == 2 && partes[1] == "insights":
+		if _, ok := s.authorize(w, r); ok {
+			writeJSON(w, map[string]interface{}{"data": []interface{}{}})
+		}
+	case r.Method == http.MethodGet && len(partes) == 1:
+		s.postEngagement(w, r, partes[0])
+	default:
+		writeError(w, Error{Status: http.StatusBadRequest, Type: "GraphMethodException", Code: 100, Subcode: 33, Message: fmt.Sprintf("Unsupported %s request.", strings.ToLower(r.Method))})
+	}
+}
+
+// takeFalla quita y devuelve el primer error programado para la petición
+func (s *Server) takeFalla(method, path string) (Error, bool) {
+	for i, f := range s.fallas {
+		if f.method == method && (f.path == "" || f.path == path) {
+			s.fallas = append(s.fallas[:i], s.fallas[i+1:]...)
+			return f.err, true
+		}
+	}
+	return Error{}, false
+}
+
+// writeUsage agrega los encabezados de uso configurados para el token
+func (s *Server) writeUsage(w http.ResponseWriter, accessToken string) {
+	if s.appUsage != nil {
+		valor, _ := json.Marshal(s.appUsage)
+		w.Header().Set("X-App-Usage", string(valor))
+	}
+	if usage, ok := s.tokenUsage[accessToken]; ok {
+		valor, _ := json.Marshal(map[string][]interface{}{
+			"fake-business": {struct {
+				Type string `json:"type"`
+				Usage
+			}{Type: "pages", Usage: usage}},
+		})
+		w.Header().Set("X-Business-Use-Case-Usage", string(valor))
+	}
+}
+
+// authorize verifica el access_token de la petición
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) (Token, bool) {
+	token, ok := s.tokens[r.Form.Get("access_token")]
+	switch {
+	case !ok:
+		writeError(w, Error{Status: http.StatusBadRequest, Type: "OAuthException", Code: 190, Message: "Invalid OAuth access token - Cannot parse access token"})
+		return Token{}, false
+	case !token.ExpiresAt.IsZero() && time.Now().After(token.ExpiresAt):
+		writeError(w, ErrTokenExpired)
+		return Token{}, false
+	}
+	return token, true
+}
+
+func (s *Server) me(w http.ResponseWriter, r *http.Request) {
+	token, ok := s.authorize(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, map[string]string{"id": token.UserID, "name": token.Name, "email": token.Email})
+}
+
+func (s *Server) myGroups(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.authorize(w, r); !ok {
+		return
+	}
+	writeJSON(w, map[string]interface{}{"data": append([]Group{}, s.groups...)})
+}
+
+func (s *Server) debugToken(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.authorize(w, r); !ok {
+		return
+	}
+
+	token, ok := s.tokens[r.Form.Get("input_token")]
+	datos := map[string]interface{}{"app_id": s.AppID, "is_valid": false}
+	if ok {
+		datos["is_valid"] = token.ExpiresAt.IsZero() || time.Now().Before(token.ExpiresAt)
+		datos["user_id"] = token.UserID
+		datos["scopes"] = token.Scopes
+		if !token.ExpiresAt.IsZero() {
+			datos["expires_at"] = token.ExpiresAt.Unix()
+		}
+	}
+	writeJSON(w, map[string]interface{}{"data": datos})
+}
+
+// exchangeToken canjea un token por uno de larga duración (60 días)
+func (s *Server) exchangeToken(w http.ResponseWriter, r *http.Request) {
+	if r.Form.Get("grant_type") != "fb_exchange_token" {
+		writeError(w, invalidParameter("grant_type no soportado"))
+		return
+	}
+	if r.Form.Get("client_id") != s.AppID || r.Form.Get("client_secret") != s.AppSecret {
+		writeError(w, Error{Status: http.StatusBadRequest, Type: "OAuthException", Code: 1, Message: "Error validating client secret."})
+		return
+	}
+
+	token, ok := s.tokens[r.Form.Get("fb_exchange_token")]
+	if !ok || (!token.ExpiresAt.IsZero() && time.Now().After(token.ExpiresAt)) {
+		writeError(w, ErrTokenExpired)
+		return
+	}
+
+	duracion := 60 * 24 * time.Hour
+	token.ExpiresAt = time.Now().Add(duracion)
+	nuevo := "long-lived-" + s.nextID()
+	s.tokens[nuevo] = token
+
+	writeJSON(w, map[string]interface{}{
+		"access_token": nuevo,
+		"token_type":   "bearer",
+		"expires_in":   int(duracion.Seconds()),
+	})
+}
+
+// checkGroup verifica que el grupo exista si hay grupos registrados
+func (s *Server) checkGroup(w http.ResponseWriter, groupID string) bool {
+	if len(s.groups) == 0 {
+		return true
+	}
+	for _, group := range s.groups {
+		if group.ID == groupID {
+			return true
+		}
+	}
+	writeError(w, Error{Status: http.StatusBadRequest, Type: "GraphMethodException", Code: 100, Subcode: 33, Message: fmt.Sprintf("Unsupported post request. Object with ID '%s' does not exist", groupID)})
+	return false
+}
+
+func (s *Server) createPost(w http.ResponseWriter, r *http.Request, groupID string) {
+	if _, ok := s.authorize(w, r); !ok || !s.checkGroup(w, groupID) {
+		return
+	}
+
+	post := Post{
+		ID:        groupID + "_" + s.nextID(),
+		GroupID:   groupID,
+		Message:   r.Form.Get("message"),
+		Link:      r.Form.Get("link"),
+		CreatedAt: time.Now(),
+	}
+
+	// attached_media[0], attached_media[1], ...
+	var adjuntas []int
+	for i := 0; r.Form.Has("attached_media[" + strconv.Itoa(i) + "]"); i++ {
+		var media struct {
+			MediaFBID string `json:"media_fbid"`
+		}
+		if err := json.Unmarshal([]byte(r.Form.Get("attached_media["+strconv.Itoa(i)+"]")), &media); err != nil {
+			writeError(w, invalidParameter("attached_media inválido"))
+			return
+		}
+
+		indice := s.findPhoto(media.MediaFBID)
+		if indice < 0 || s.photos[indice].GroupID != groupID || s.photos[indice].Published {
+			writeError(w, invalidParameter("la foto "+media.MediaFBID+" no es una foto sin publicar del grupo"))
+			return
+		}
+		adjuntas = append(adjuntas, indice)
+		post.AttachedMedia = append(post.AttachedMedia, media.MediaFBID)
+	}
+
+	if post.Message == "" && post.Link == "" && len(adjuntas) == 0 {
+		writeError(w, invalidParameter("la publicación está vacía"))
+		return
+	}
+
+	for _, indice := range adjuntas {
+		s.photos[indice].Published = true
+		s.photos[indice].PostID = post.ID
+	}
+	s.posts = append(s.posts, post)
+	writeJSON(w, map[string]string{"id": post.ID})
+}
+
+func (s *Server) createPhoto(w http.ResponseWriter, r *http.Request, groupID string) {
+	if _, ok := s.authorize(w, r); !ok || !s.checkGroup(w, groupID) {
+		return
+	}
+
+	photo := Photo{
+		ID:        s.nextID(),
+		GroupID:   groupID,
+		URL:       r.Form.Get("url"),
+		Caption:   r.Form.Get("caption"),
+		Published: r.Form.Get("published") != "false",
+	}
+
+	if r.MultipartForm != nil {
+		if archivos := r.MultipartForm.File["source"]; len(archivos) > 0 {
+			f, err := archivos[0].Open()
+			if err != nil {
+				writeError(w, invalidParameter(err.Error()))
+				return
+			}
+			datos, err := io.ReadAll(f)
+			f.Close()
+			if err != nil {
+				writeError(w, invalidParameter(err.Error()))
+				return
+			}
+			photo.ContentType = archivos[0].Header.Get("Content-Type")
+			photo.Size = len(datos)
+		}
+	}
+	if photo.URL == "" && photo.Size == 0 {
+		writeError(w, Error{Status: http.StatusBadRequest, Type: "OAuthException", Code: 324, Message: "(#324) Requires upload file"})
+		return
+	}
+
+	respuesta := map[string]string{"id": photo.ID}
+	if photo.Published {
+		photo.PostID = groupID + "_" + s.nextID()
+		s.posts = append(s.posts, Post{ID: photo.PostID, GroupID: groupID, Message: photo.Caption, AttachedMedia: []string{photo.ID}, CreatedAt: time.Now()})
+		respuesta["post_id"] = photo.PostID
+	}
+
+	s.photos = append(s.photos, photo)
+	writeJSON(w, respuesta)
+}
+
+func (s *Server) postEngagement(w http.ResponseWriter, r *http.Request, postID string) {
+	if _, ok := s.authorize(w, r); !ok {
+		return
+	}
+
+	existe := false
+	for _, post := range s.posts {
+		if post.ID == postID {
+			existe = true
+			break
+		}
+	}
+	if !existe {
+		writeError(w, Error{Status: http.StatusBadRequest, Type: "GraphMethodException", Code: 100, Subcode: 33, Message: fmt.Sprintf("Unsupported get request. Object with ID '%s' does not exist", postID)})
+		return
+	}
+
+	e := s.engagement[postID]
+	writeJSON(w, map[string]interface{}{
+		"id":        postID,
+		"reactions": map[string]interface{}{"data": []interface{}{}, "summary": map[string]int{"total_count": e.Reactions}},
+		"comments":  map[string]interface{}{"data": []interface{}{}, "summary": map[string]int{"total_count": e.Comments}},
+		"shares":    map[string]int{"count": e.Shares},
+	})
+}
+
+func (s *Server) findPhoto(id string) int {
+	for i, photo := range s.photos {
+		if photo.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// nextID devuelve un ID numérico nuevo, como los de la Graph API
+func (s *Server) nextID() string {
+	s.secuencia++
+	return strconv.Itoa(100000 + s.secuencia)
+}
+
+func invalidParameter(mensaje string) Error {
+	return Error{Status: http.StatusBadRequest, Type: "OAuthException", Code: 100, Message: "(#100) " + mensaje}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err Error) {
+	cuerpo := map[string]interface{}{
+		"message":    err.Message,
+		"type":       err.Type,
+		"code":       err.Code,
+		"fbtrace_id": "FakeTrace" + strconv.Itoa(err.Code),
+	}
+	if err.Subcode != 0 {
+		cuerpo["error_subcode"] = err.Subcode
+	}
+	if err.IsTransient {
+		cuerpo["is_transient"] = true
+	}
+
+	if err.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(err.RetryAfter))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(err.Status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"error": cuerpo})
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Estados de un trabajo de publicación
+const (
+	TrabajoPendiente  = "pendiente"
+	TrabajoEnProceso  = "en_proceso"
+	TrabajoCompletado = "completado"
+	TrabajoFallido    = "fallido"
+	TrabajoOmitido    = "omitido" // Descartado por un límite de publicación
+)
+
+// JobQueue es la cola persistente de publicaciones sobre MongoDB. Cada
+// trabajo corresponde a una programación, un grupo y un horario; los workers
+// lo toman con un lease que vence si el proceso se cae, de modo que otro
+// worker pueda retomarlo tras un reinicio.
+type JobQueue struct {
+	collection *mongo.Collection
+	lease      time.Duration
+	clock      Clock
+}
+
+func NewJobQueue(lease time.Duration, clock Clock) *JobQueue {
+	q := &JobQueue{
+		collection: database.Collection("trabajos_publicacion"),
+		lease:      lease,
+		clock:      clock,
+	}
+	q.ensureIndexes()
+	return q
+}
+
+// ensureIndexes crea los índices de la cola; el índice único evita encolar
+// dos veces el mismo grupo para el mismo horario
+func (q *JobQueue) ensureIndexes() {
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "programacion_id", Value: 1}, {Key: "grupo_id", Value: 1}, {Key: "fecha_programada", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "estado", Value: 1}, {Key: "disponible_desde", Value: 1}},
+		},
+	}
+
+	if _, err := q.collection.Indexes().CreateMany(context.Background(), indexes); err != nil {
+		log.Printf("Error creando índices de la cola de publicaciones: %v", err)
+	}
+}
+
+// Enqueue agrega trabajos a la cola ignorando los que ya estaban encolados.
+// Devuelve la cantidad de trabajos nuevos.
+func (q *JobQueue) Enqueue(trabajos []TrabajoPublicacion) (int, error) {
+	if len(trabajos) == 0 {
+		return 0, nil
+	}
+
+	now := q.clock.Now()
+	docs := make([]interface{}, len(trabajos))
+	for i := range trabajos {
+		trabajos[i].ID = primitive.NewObjectID()
+		trabajos[i].Estado = TrabajoPendiente
+		if trabajos[i].DisponibleDesde.IsZero() {
+			trabajos[i].DisponibleDesde = now
+		}
+		trabajos[i].CreatedAt = now
+		trabajos[i].UpdatedAt = now
+		docs[i] = trabajos[i]
+	}
+
+	result, err := q.collection.InsertMany(context.Background(), docs, options.InsertMany().SetOrdered(false))
+	if err != nil && !soloClavesDuplicadas(err) {
+		return 0, err
+	}
+	if result == nil {
+		return 0, nil
+	}
+
+	return len(result.InsertedIDs), nil
+}
+
+// soloClavesDuplicadas indica si todos los errores de una inserción múltiple
+// se deben a documentos ya existentes
+func soloClavesDuplicadas(err error) bool {
+	bulkErr, ok := err.(mongo.BulkWriteException)
+	if !ok {
+		return mongo.IsDuplicateKeyError(err)
+	}
+
+	for _, e := range bulkErr.WriteErrors {
+		if e.Code != 11000 {
+			return false
+		}
+	}
+	return bulkErr.WriteConcernError == nil
+}
+
+// Claim toma el siguiente trabajo disponible para la instancia indicada. Un
+// trabajo en proceso cuyo lease venció se considera abandonado y se retoma.
+// Devuelve nil si no hay trabajos disponibles.
+func (q *JobQueue) Claim(instancia string) (*TrabajoPublicacion, error) {
+	now := q.clock.Now()
+
+	filter := bson.M{
+		"$or": []bson.M{
+			{"estado": TrabajoPendiente, "disponible_desde": bson.M{"$lte": now}},
+			{"estado": TrabajoEnProceso, "lease_hasta": bson.M{"$lt": now}},
+		},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"estado":      TrabajoEnProceso,
+			"instancia":   instancia,
+			"lease_hasta": now.Add(q.lease),
+			"updated_at":  now,
+		},
+		"$inc": bson.M{"intentos": 1},
+	}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "disponible_desde", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var trabajo TrabajoPublicacion
+	err := q.collection.FindOneAndUpdate(context.Background(), filter, update, opts).Decode(&trabajo)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &trabajo, nil
+}
+
+// Ack marca un trabajo tomado por la instancia como terminado
+func (q *JobQueue) Ack(trabajo TrabajoPublicacion, estado, mensajeError string) error {
+	filter := bson.M{"_id": trabajo.ID, "instancia": trabajo.Instancia, "estado": TrabajoEnProceso}
+	update := bson.M{
+		"$set": bson.M{
+			"estado":        estado,
+			"mensaje_error": mensajeError,
+			"updated_at":    q.clock.Now(),
+		},
+	}
+
+	_, err := q.collection.UpdateOne(context.Background(), filter, update)
+	return err
+}
+
+// Defer devuelve a la cola un trabajo que no pudo publicarse por un límite de
+// publicación; la espera no cuenta como intento
+func (q *JobQueue) Defer(trabajo TrabajoPublicacion, disponibleDesde time.Time, motivo string) error {
+	filter := bson.M{"_id": trabajo.ID, "instancia": trabajo.Instancia, "estado": TrabajoEnProceso}
+	update := bson.M{
+		"$set": bson.M{
+			"estado":           TrabajoPendiente,
+			"disponible_desde": disponibleDesde,
+			"mensaje_error":    motivo,
+			"updated_at":       q.clock.Now(),
+		},
+		"$inc": bson.M{"intentos": -1},
+	}
+
+	_, err := q.collection.UpdateOne(context.Background(), filter, update)
+	return err
+}
+
+// Retry devuelve a la cola un trabajo fallido para reintentarlo desde disponibleDesde
+func (q *JobQueue) Retry(trabajo TrabajoPublicacion, disponibleDesde time.Time, mensajeError string) error {
+	filter := bson.M{"_id": trabajo.ID, "instancia": trabajo.Instancia, "estado": TrabajoEnProceso}
+	update := bson.M{
+		"$set": bson.M{
+			"estado":           TrabajoPendiente,
+			"disponible_desde": disponibleDesde,
+			"mensaje_error":    mensajeError,
+			"updated_at":       q.clock.Now(),
+		},
+	}
+
+	_, err := q.collection.UpdateOne(context.Background(), filter, update)
+	return err
+}
+
+// Extend renueva el lease de un trabajo que la instancia sigue publicando,
+// para que otro worker no lo retome mientras la publicación está en curso
+func (q *JobQueue) Extend(trabajo TrabajoPublicacion) error {
+	now := q.clock.Now()
+	filter := bson.M{"_id": trabajo.ID, "instancia": trabajo.Instancia, "estado": TrabajoEnProceso}
+	update := bson.M{
+		"$set": bson.M{
+			"lease_hasta": now.Add(q.lease),
+			"updated_at":  now,
+		},
+	}
+
+	_, err := q.collection.UpdateOne(context.Background(), filter, update)
+	return err
+}
+
+// CountByEstado cuenta los trabajos de la cola en los estados indicados; los
+// estados sin trabajos aparecen con 0
+func (q *JobQueue) CountByEstado(estados ...string) (map[string]int, error) {
+	conteos, err := contarPorEstado(q.collection, bson.M{"estado": bson.M{"$in": estados}})
+	if err != nil {
+		return nil, err
+	}
+	for _, estado := range estados {
+		if _, ok := conteos[estado]; !ok {
+			conteos[estado] = 0
+		}
+	}
+	return conteos, nil
+}
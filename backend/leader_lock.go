@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// LeaderLock implementa la elección de líder entre réplicas del backend sobre
+// MongoDB: un documento por lock con la instancia dueña y su vencimiento. La
+// dueña debe renovarlo antes de que venza; si deja de hacerlo (caída, corte de
+// red) otra instancia lo toma al vencer.
+type LeaderLock struct {
+	collection *mongo.Collection
+	nombre     string
+	instancia  string
+	ttl        time.Duration
+	clock      Clock
+}
+
+func NewLeaderLock(nombre string, ttl time.Duration, clock Clock) *LeaderLock {
+	return &LeaderLock{
+		collection: database.Collection("scheduler_locks"),
+		nombre:     nombre,
+		instancia:  identificadorInstancia(),
+		ttl:        ttl,
+		clock:      clock,
+	}
+}
+
+// identificadorInstancia identifica de forma única a este proceso
+func identificadorInstancia() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "desconocido"
+	}
+
+	sufijo := make([]byte, 4)
+	rand.Read(sufijo)
+
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(sufijo))
+}
+
+// Instancia devuelve el identificador de esta instancia
+func (l *LeaderLock) Instancia() string {
+	return l.instancia
+}
+
+// Acquire obtiene el lock o renueva su vencimiento si ya pertenece a esta
+// instancia. Devuelve false si otra instancia lo tiene vigente.
+func (l *LeaderLock) Acquire() (bool, error) {
+	now := l.clock.Now()
+
+	filter := bson.M{
+		"_id": l.nombre,
+		"$or": []bson.M{
+			{"instancia": l.instancia},
+			{"expira_en": bson.M{"$lt": now}},
+		},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"instancia":   l.instancia,
+			"expira_en":   now.Add(l.ttl),
+			"renovado_en": now,
+		},
+	}
+
+	_, err := l.collection.UpdateOne(context.Background(), filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		// El upsert choca con el documento de otra instancia que tiene el lock vigente
+		if mongo.IsDuplicateKeyError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Release libera el lock si pertenece a esta instancia
+func (l *LeaderLock) Release() error {
+	_, err := l.collection.DeleteOne(context.Background(), bson.M{"_id": l.nombre, "instancia": l.instancia})
+	return err
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// errorDiferido indica que el trabajo debe esperar hasta una fecha antes de publicarse
+type errorDiferido struct {
+	hasta  time.Time
+	motivo string
+}
+
+func (e *errorDiferido) Error() string {
+	return e.motivo
+}
+
+// errorOmitido indica que la publicación no se realizará por un límite de
+// publicación; se registra como "omitida" en el historial
+type errorOmitido struct {
+	motivo string
+}
+
+func (e *errorOmitido) Error() string {
+	return e.motivo
+}
+
+// omitir devuelve un error permanente que marca la publicación como omitida
+func omitir(format string, args ...interface{}) error {
+	return permanente(&errorOmitido{motivo: fmt.Sprintf(format, args...)})
+}
+
+// LimitesPublicacion aplica los límites anti-spam del scheduler: espaciado
+// aleatorio entre los grupos de un mismo horario, intervalo mínimo y máximo
+// diario por grupo (entre todas las programaciones) y máximo diario por
+// cuenta. Los grupos pueden sobrescribir sus límites; un valor de 0 en la
+// configuración, el valor por defecto, desactiva el límite.
+type LimitesPublicacion struct {
+	espaciadoMin   time.Duration
+	espaciadoMax   time.Duration
+	intervaloGrupo time.Duration
+	maximoGrupo    int
+	maximoCuenta   int
+	historial      HistorialRepository
+	grupos         GrupoRepository
+	clock          Clock
+}
+
+func NewLimitesPublicacion(historial HistorialRepository, grupos GrupoRepository, clock Clock) *LimitesPublicacion {
+	l := &LimitesPublicacion{
+		historial:      historial,
+		grupos:         grupos,
+		clock:          clock,
+		espaciadoMin:   time.Duration(envIntNoNegativo("SCHEDULER_ESPACIADO_MIN_SEGUNDOS", 30)) * time.Second,
+		espaciadoMax:   time.Duration(envIntNoNegativo("SCHEDULER_ESPACIADO_MAX_SEGUNDOS", 120)) * time.Second,
+		intervaloGrupo: time.Duration(envIntNoNegativo("SCHEDULER_INTERVALO_GRUPO_MINUTOS", 0)) * time.Minute,
+		maximoGrupo:    envIntNoNegativo("SCHEDULER_MAXIMO_DIARIO_GRUPO", 0),
+		maximoCuenta:   envIntNoNegativo("SCHEDULER_MAXIMO_DIARIO_CUENTA", 0),
+	}
+	if l.espaciadoMax < l.espaciadoMin {
+		l.espaciadoMax = l.espaciadoMin
+	}
+	return l
+}
+
+// espaciado devuelve una espera aleatoria entre el espaciado mínimo y el máximo
+func (l *LimitesPublicacion) espaciado() time.Duration {
+	rango := l.espaciadoMax - l.espaciadoMin
+	if rango <= 0 {
+		return l.espaciadoMin
+	}
+	return l.espaciadoMin + time.Duration(rand.Int63n(int64(rango)))
+}
+
+// escalonar reparte n publicaciones a partir de inicio separándolas por un
+// espaciado aleatorio, para no publicar en todos los grupos en el mismo segundo
+func (l *LimitesPublicacion) escalonar(inicio time.Time, n int) []time.Time {
+	fechas := make([]time.Time, n)
+	fecha := inicio
+	for i := range fechas {
+		if i > 0 {
+			fecha = fecha.Add(l.espaciado())
+		}
+		fechas[i] = fecha
+	}
+	return fechas
+}
+
+// check verifica los límites antes de publicar el trabajo en el grupo; los
+// máximos diarios cuentan desde la medianoche en loc, la zona de la
+// programación. Devuelve un errorOmitido si se alcanzó un máximo diario y un
+// errorDiferido si el grupo recibió otra publicación hace menos del
+// intervalo mínimo; en otro caso reserva el grupo para este trabajo.
+func (l *LimitesPublicacion) check(trabajo TrabajoPublicacion, grupo GrupoFacebook, loc *time.Location) error {
+	now := l.clock.Now()
+	inicio := inicioDelDia(now, loc)
+
+	if l.maximoCuenta > 0 && !trabajo.UserID.IsZero() {
+		publicadas, err := l.historial.CountSuccessfulByUser(trabajo.UserID, inicio)
+		if err != nil {
+			return err
+		}
+		if publicadas >= l.maximoCuenta {
+			return omitir("la cuenta alcanzó su máximo de %d publicaciones diarias", l.maximoCuenta)
+		}
+	}
+
+	maximoGrupo := l.maximoGrupo
+	if grupo.MaximoDiario > 0 {
+		maximoGrupo = grupo.MaximoDiario
+	}
+	intervalo := l.intervaloGrupo
+	if grupo.IntervaloMinimo > 0 {
+		intervalo = time.Duration(grupo.IntervaloMinimo) * time.Minute
+	}
+	if maximoGrupo <= 0 && intervalo <= 0 {
+		return nil
+	}
+
+	return l.reservarGrupo(trabajo, grupo, limiteGrupo{intervalo: intervalo, desde: inicio, maximo: maximoGrupo}, now)
+}
+
+// reservarGrupo marca al grupo como publicado por el trabajo si pasó el
+// intervalo mínimo desde su última publicación y le quedan publicaciones en
+// el día; los reintentos del mismo trabajo conservan su reserva.
+func (l *LimitesPublicacion) reservarGrupo(trabajo TrabajoPublicacion, grupo GrupoFacebook, limite limiteGrupo, now time.Time) error {
+	reservado, actual, err := l.grupos.Reserve(grupo.ID, trabajo.ID, now, limite)
+	if err != nil || reservado {
+		return err
+	}
+
+	if limite.maximo > 0 && actual.reservasDesde(limite.desde) >= limite.maximo {
+		return omitir("el grupo %s alcanzó su máximo de %d publicaciones diarias", grupo.Nombre, limite.maximo)
+	}
+
+	// Otro trabajo publicó hace poco: esperar a que se cumpla el intervalo
+	hasta := now.Add(limite.intervalo)
+	if actual.UltimaPublicacion != nil {
+		hasta = actual.UltimaPublicacion.Add(limite.intervalo)
+	}
+
+	return &errorDiferido{
+		hasta:  hasta.Add(l.espaciado()),
+		motivo: fmt.Sprintf("el grupo %s recibió una publicación hace menos de %s", grupo.Nombre, limite.intervalo),
+	}
+}
+
+// release libera la reserva del grupo cuando el trabajo no llegó a publicar,
+// para que no cuente en el máximo diario ni en el intervalo mínimo
+func (l *LimitesPublicacion) release(trabajo TrabajoPublicacion) error {
+	return l.grupos.Release(trabajo.GrupoID, trabajo.ID)
+}
+
+// limiteGrupo son los límites de un grupo que se verifican al reservarlo
+type limiteGrupo struct {
+	intervalo time.Duration // Mínimo desde la última publicación; 0 sin intervalo
+	desde     time.Time     // Inicio del día para el máximo diario
+	maximo    int           // Reservas desde el inicio del día; 0 sin máximo
+}
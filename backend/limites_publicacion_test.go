@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLimitesDiariosEnZonaDeLaProgramacion(t *testing.T) {
+	t.Setenv("DEFAULT_TIMEZONE", "UTC")
+	tokio, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skipf("zona horaria Asia/Tokyo no disponible: %v", err)
+	}
+
+	// Las 23:00 y la 01:00 de Tokio son el mismo día en UTC, pero no en la
+	// zona de la programación, donde el máximo de una por día no se supera
+	inicio := time.Date(2025, 3, 3, 12, 0, 0, 0, tokio)
+	sim := nuevaSimulacion(t, inicio, 1)
+	sim.scheduler.limites.maximoGrupo = 1
+	sim.programar(ProgramacionPublicacion{
+		Frecuencia:  "diaria",
+		Horarios:    []ConfiguracionHorario{{Hora: 23}, {Hora: 1}},
+		FechaInicio: inicio,
+		ZonaHoraria: "Asia/Tokyo",
+	})
+
+	sim.avanzar(inicio.Add(14 * time.Hour))
+
+	compararFechas(t, sim.fechasPublicadas(sim.grupos[0], tokio), []string{"2025-03-03 23:00", "2025-03-04 01:00"})
+}
+
+func TestLimitesLiberanReservaFallida(t *testing.T) {
+	inicio := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+	sim := nuevaSimulacion(t, inicio, 1)
+	sim.scheduler.limites.intervaloGrupo = time.Hour
+	sim.facebook.errores = []error{permanente(errors.New("permiso denegado"))}
+	sim.programar(ProgramacionPublicacion{
+		Frecuencia:  "diaria",
+		Horarios:    []ConfiguracionHorario{{Hora: 9}, {Hora: 9, Minuto: 30}},
+		FechaInicio: inicio,
+	})
+
+	sim.avanzar(inicio.Add(12 * time.Hour))
+
+	// La publicación fallida de las 09:00 no frena la de las 09:30
+	compararFechas(t, sim.fechasPublicadas(sim.grupos[0], time.UTC), []string{"2025-03-03 09:30"})
+}
+
+func TestLimitesMaximoDiarioCuentaTrabajosEnCurso(t *testing.T) {
+	inicio := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+	sim := nuevaSimulacion(t, inicio, 1)
+	sim.scheduler.limites.maximoGrupo = 1
+	for i := 0; i < 2; i++ {
+		sim.programar(ProgramacionPublicacion{
+			Frecuencia:  "diaria",
+			Horarios:    []ConfiguracionHorario{{Hora: 9}},
+			FechaInicio: inicio,
+		})
+	}
+
+	// Otro worker toma el segundo trabajo mientras el primero publica
+	otroWorker := true
+	sim.facebook.antesDePublicar = func() {
+		if !otroWorker {
+			return
+		}
+		otroWorker = false
+		trabajo, err := sim.cola.Claim("otro")
+		if err != nil || trabajo == nil {
+			t.Fatalf("Claim: %v, %v", trabajo, err)
+		}
+		sim.scheduler.processJob(*trabajo)
+	}
+
+	sim.avanzar(inicio.Add(10 * time.Hour))
+
+	compararFechas(t, sim.fechasPublicadas(sim.grupos[0], time.UTC), []string{"2025-03-03 09:00"})
+	omitidas := 0
+	for _, registro := range sim.historial.registros {
+		if registro.Estado == "omitida" {
+			omitidas++
+		}
+	}
+	if omitidas != 1 {
+		t.Errorf("%d publicaciones omitidas, se esperaba 1", omitidas)
+	}
+}
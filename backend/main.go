@@ -2,14 +2,20 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
+	_ "time/tzdata" // Base de zonas horarias embebida; la imagen alpine no la incluye
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"github.com/vhPedroGitHub/ventas-ceili/internal/fakegraph"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -26,14 +32,31 @@ func main() {
 	// Conectar a MongoDB
 	connectMongoDB()
 
+	// El scheduler puede desactivarse para correr réplicas que solo sirven la API
+	schedulerEnabled := flag.Bool("scheduler", envBool("SCHEDULER_ENABLED", true), "ejecutar el scheduler de publicaciones en este proceso")
+	flag.Parse()
+
 	// Inicializar servicios
 	authService := NewAuthService()
 	facebookService := NewFacebookService()
+	if envBool("FACEBOOK_FAKE_GRAPH", false) {
+		// Graph API simulada para desarrollar sin una aplicación de Facebook
+		fake := fakegraph.New()
+		fake.Seed()
+		defer fake.Close()
+		uso := NewMonitorUsoGraph()
+		app := FacebookApp{ID: fake.AppID, Secret: fake.AppSecret}
+		facebookService = NewFacebookServiceWith(NewGraphClient(fake.URL, graphVersionPorDefecto, uso), uso, app)
+		log.Printf("Usando Graph API simulada en %s (token de acceso %q)", fake.URL, fakegraph.DevToken)
+	}
 	schedulerService := NewSchedulerService(authService, facebookService)
 
 	// Iniciar el scheduler
-	schedulerService.Start()
-	defer schedulerService.Stop()
+	if *schedulerEnabled {
+		schedulerService.Start()
+	} else {
+		log.Println("Scheduler desactivado en esta instancia")
+	}
 
 	// Configurar Gin
 	r := gin.Default()
@@ -59,6 +82,8 @@ func main() {
 	{
 		// Perfil de usuario
 		api.GET("/profile", ProfileHandler(authService))
+		api.PUT("/profile", UpdateProfileHandler(authService))
+		api.POST("/profile/feriados", ImportarFeriadosPerfilHandler(authService))
 		api.POST("/refresh-token", RefreshTokenHandler(authService))
 
 		// Facebook
@@ -74,7 +99,7 @@ func main() {
 		// Productos
 		api.GET("/productos", getProductos)
 		api.POST("/productos", createProducto)
-		api.PUT("/productos/:id", updateProducto)
+		api.PUT("/productos/:id", UpdateProductoHandler(schedulerService))
 		api.DELETE("/productos/:id", deleteProducto)
 
 		// Publicaciones
@@ -82,16 +107,41 @@ func main() {
 		api.POST("/publicaciones", createPublicacion)
 		api.PUT("/publicaciones/:id", updatePublicacion)
 		api.DELETE("/publicaciones/:id", deletePublicacion)
+		api.GET("/publicaciones/:id/variantes", getRendimientoVariantes)
 
 		// Grupos de Facebook
 		api.GET("/grupos", getGrupos)
 		api.POST("/grupos", createGrupo)
+		api.PUT("/grupos/:id", updateGrupo)
 
 		// Programación de publicaciones
 		api.GET("/programaciones", getProgramaciones)
 		api.POST("/programaciones", createProgramacion)
 		api.PUT("/programaciones/:id", updateProgramacion)
 		api.DELETE("/programaciones/:id", deleteProgramacion)
+		api.GET("/programaciones/:id/ejecuciones", getEjecucionesProgramacion)
+		api.GET("/programaciones/:id/preview", PreviewProgramacionHandler(schedulerService))
+		api.POST("/programaciones/preview", PreviewNuevaProgramacionHandler(schedulerService))
+		api.POST("/programaciones/:id/ejecutar", EjecutarProgramacionHandler(schedulerService))
+		api.POST("/programaciones/:id/pausar", PausarProgramacionHandler(schedulerService))
+		api.POST("/programaciones/:id/reanudar", ReanudarProgramacionHandler(schedulerService))
+		api.POST("/programaciones/:id/omitir-siguiente", OmitirSiguienteHandler(schedulerService))
+		api.POST("/programaciones/:id/feriados", importarFeriadosProgramacion)
+
+		// Cola de publicaciones
+		api.GET("/trabajos", getTrabajos)
+
+		// Notificaciones del usuario
+		api.GET("/notificaciones", getNotificaciones)
+		api.PUT("/notificaciones/:id/leida", marcarNotificacionLeida)
+
+		// Estado del scheduler (administradores)
+		scheduler := api.Group("/scheduler")
+		scheduler.Use(AdminMiddleware())
+		{
+			scheduler.GET("/status", SchedulerStatusHandler(schedulerService))
+			scheduler.POST("/tick", SchedulerTickHandler(schedulerService))
+		}
 	}
 
 	// Health check
@@ -104,8 +154,37 @@ func main() {
 		port = "8080"
 	}
 
-	log.Printf("Servidor iniciado en puerto %s", port)
-	r.Run(":" + port)
+	srv := &http.Server{Addr: ":" + port, Handler: r}
+
+	// SIGTERM (docker stop) o Ctrl+C inician el apagado ordenado
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		log.Printf("Servidor iniciado en puerto %s", port)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Error iniciando servidor: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	log.Println("Apagando servidor...")
+
+	// El servidor y el scheduler comparten el plazo del apagado
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(envInt("SHUTDOWN_TIMEOUT_SEGUNDOS", 30))*time.Second)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error cerrando servidor HTTP: %v", err)
+	}
+
+	schedulerService.Stop(shutdownCtx)
+
+	if err := client.Disconnect(context.Background()); err != nil {
+		log.Printf("Error desconectando de MongoDB: %v", err)
+	}
+	log.Println("Servidor detenido")
 }
 
 func connectMongoDB() {
@@ -131,3 +210,39 @@ func connectMongoDB() {
 	database = client.Database("ventas_ceili")
 	log.Println("Conectado exitosamente a MongoDB")
 }
+
+// envBool lee una variable de entorno booleana con valor por defecto
+func envBool(nombre string, porDefecto bool) bool {
+	valor, err := strconv.ParseBool(os.Getenv(nombre))
+	if err != nil {
+		return porDefecto
+	}
+	return valor
+}
+
+// envIntNoNegativo lee una variable de entorno entera donde 0 es un valor
+// válido (por ejemplo, para desactivar un límite)
+func envIntNoNegativo(nombre string, porDefecto int) int {
+	valor, err := strconv.Atoi(os.Getenv(nombre))
+	if err != nil || valor < 0 {
+		return porDefecto
+	}
+	return valor
+}
+
+// envString lee una variable de entorno de texto con valor por defecto
+func envString(nombre, porDefecto string) string {
+	if valor := os.Getenv(nombre); valor != "" {
+		return valor
+	}
+	return porDefecto
+}
+
+// envInt lee una variable de entorno entera positiva con valor por defecto
+func envInt(nombre string, porDefecto int) int {
+	valor, err := strconv.Atoi(os.Getenv(nombre))
+	if err != nil || valor <= 0 {
+		return porDefecto
+	}
+	return valor
+}
@@ -8,17 +8,19 @@ import (
 
 // Usuario representa un usuario del sistema
 type Usuario struct {
-	ID                  primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Email               string             `json:"email" bson:"email"`
-	Password            string             `json:"-" bson:"password"` // No se serializa en JSON
-	Nombre              string             `json:"nombre" bson:"nombre"`
-	FacebookUserID      string             `json:"facebook_user_id" bson:"facebook_user_id"`
-	FacebookAccessToken string             `json:"-" bson:"facebook_access_token"` // No se serializa
-	TokenExpiracion     time.Time          `json:"token_expiracion" bson:"token_expiracion"`
-	Activo              bool               `json:"activo" bson:"activo"`
-	Role                string             `json:"role" bson:"role"` // "admin", "user"
-	CreatedAt           time.Time          `json:"created_at" bson:"created_at"`
-	UpdatedAt           time.Time          `json:"updated_at" bson:"updated_at"`
+	ID                  primitive.ObjectID       `json:"id" bson:"_id,omitempty"`
+	Email               string                   `json:"email" bson:"email"`
+	Password            string                   `json:"-" bson:"password"` // No se serializa en JSON
+	Nombre              string                   `json:"nombre" bson:"nombre"`
+	FacebookUserID      string                   `json:"facebook_user_id" bson:"facebook_user_id"`
+	FacebookAccessToken string                   `json:"-" bson:"facebook_access_token"` // No se serializa
+	TokenExpiracion     time.Time                `json:"token_expiracion" bson:"token_expiracion"`
+	Activo              bool                     `json:"activo" bson:"activo"`
+	Role                string                   `json:"role" bson:"role"`                   // "admin", "user"
+	ZonaHoraria         string                   `json:"zona_horaria" bson:"zona_horaria"`   // Zona IANA, por ejemplo "America/Havana"
+	Restricciones       RestriccionesPublicacion `json:"restricciones" bson:"restricciones"` // Se aplican a todas sus programaciones
+	CreatedAt           time.Time                `json:"created_at" bson:"created_at"`
+	UpdatedAt           time.Time                `json:"updated_at" bson:"updated_at"`
 }
 
 // LoginRequest para el login
@@ -65,9 +67,33 @@ type FacebookPostRequest struct {
 	Link          string     `json:"link,omitempty"`
 }
 
+// FotoPublicacion es una foto de una publicación con varias fotos
+type FotoPublicacion struct {
+	URL     string `json:"url"`
+	Caption string `json:"caption"`
+}
+
 // FacebookPostResponse respuesta de Facebook al crear una publicación
 type FacebookPostResponse struct {
-	ID string `json:"id"`
+	ID     string `json:"id"`
+	PostID string `json:"post_id,omitempty"` // Publicación del feed creada al subir una foto
+}
+
+// FacebookPostEngagement interacción de una publicación según Graph API
+type FacebookPostEngagement struct {
+	Reactions struct {
+		Summary struct {
+			TotalCount int `json:"total_count"`
+		} `json:"summary"`
+	} `json:"reactions"`
+	Comments struct {
+		Summary struct {
+			TotalCount int `json:"total_count"`
+		} `json:"summary"`
+	} `json:"comments"`
+	Shares struct {
+		Count int `json:"count"`
+	} `json:"shares"`
 }
 
 // FacebookUserInfo información del usuario de Facebook
@@ -81,15 +107,53 @@ type FacebookUserInfo struct {
 type FacebookTokenInfo struct {
 	AppID     string   `json:"app_id"`
 	IsValid   bool     `json:"is_valid"`
-	ExpiresIn int      `json:"expires_at"`
+	ExpiresAt int64    `json:"expires_at"` // Timestamp unix; 0 si no vence
 	Scopes    []string `json:"scopes"`
 }
 
+// FacebookLongLivedToken es el token de larga duración obtenido al
+// intercambiar un token de acceso
+type FacebookLongLivedToken struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"` // Segundos; 0 si Facebook no lo informa
+}
+
 // Requests de autenticación
 type RegisterRequest struct {
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=6"`
-	Nombre   string `json:"nombre" binding:"required"`
+	Email       string `json:"email" binding:"required,email"`
+	Password    string `json:"password" binding:"required,min=6"`
+	Nombre      string `json:"nombre" binding:"required"`
+	ZonaHoraria string `json:"zona_horaria"`
+}
+
+// UpdateProfileRequest para actualizar el perfil del usuario
+type UpdateProfileRequest struct {
+	Nombre        string                    `json:"nombre"`
+	ZonaHoraria   *string                   `json:"zona_horaria"`  // nil mantiene la actual
+	Restricciones *RestriccionesPublicacion `json:"restricciones"` // nil mantiene las actuales
+}
+
+// HorarioSilencio es una franja diaria en la que no se publica; puede cruzar
+// la medianoche (por ejemplo, de 22:00 a 07:00)
+type HorarioSilencio struct {
+	Desde ConfiguracionHorario `json:"desde" bson:"desde"`
+	Hasta ConfiguracionHorario `json:"hasta" bson:"hasta"`
+}
+
+// FechaBloqueada es un rango de días sin publicaciones, como un feriado
+type FechaBloqueada struct {
+	Desde       string `json:"desde" bson:"desde"` // AAAA-MM-DD
+	Hasta       string `json:"hasta" bson:"hasta"` // AAAA-MM-DD inclusive; vacío equivale a Desde
+	Descripcion string `json:"descripcion" bson:"descripcion"`
+	Anual       bool   `json:"anual" bson:"anual"` // Se repite todos los años en las mismas fechas
+}
+
+// RestriccionesPublicacion agrupa los horarios de silencio y las fechas
+// bloqueadas de un usuario o de una programación
+type RestriccionesPublicacion struct {
+	HorasSilencio    []HorarioSilencio `json:"horas_silencio" bson:"horas_silencio"`
+	FechasBloqueadas []FechaBloqueada  `json:"fechas_bloqueadas" bson:"fechas_bloqueadas"`
 }
 
 // Producto representa un producto individual
@@ -113,25 +177,58 @@ type PublicacionProducto struct {
 
 // Publicacion representa una publicación que contiene múltiples productos
 type Publicacion struct {
-	ID          primitive.ObjectID    `json:"id" bson:"_id,omitempty"`
-	Titulo      string                `json:"titulo" bson:"titulo"`
-	Descripcion string                `json:"descripcion" bson:"descripcion"`
-	Productos   []PublicacionProducto `json:"productos" bson:"productos"`
-	ImagenURL   string                `json:"imagen_url" bson:"imagen_url"`
-	Estado      string                `json:"estado" bson:"estado"` // "borrador", "activa", "pausada"
-	CreatedAt   time.Time             `json:"created_at" bson:"created_at"`
-	UpdatedAt   time.Time             `json:"updated_at" bson:"updated_at"`
+	ID                 primitive.ObjectID            `json:"id" bson:"_id,omitempty"`
+	Titulo             string                        `json:"titulo" bson:"titulo"`
+	Descripcion        string                        `json:"descripcion" bson:"descripcion"`
+	Productos          []PublicacionProducto         `json:"productos" bson:"productos"`
+	ImagenURL          string                        `json:"imagen_url" bson:"imagen_url"`
+	Estado             string                        `json:"estado" bson:"estado"`                                               // "borrador", "activa", "pausada"
+	Variantes          []VariantePublicacion         `json:"variantes" bson:"variantes"`                                         // Versiones alternativas de título y descripción que el scheduler alterna
+	PromoverGanadora   bool                          `json:"promover_ganadora" bson:"promover_ganadora"`                         // Fijar en cada grupo la variante con mejor interacción
+	VariantesGanadoras map[string]primitive.ObjectID `json:"variantes_ganadoras,omitempty" bson:"variantes_ganadoras,omitempty"` // Por ID de grupo
+	CreatedAt          time.Time                     `json:"created_at" bson:"created_at"`
+	UpdatedAt          time.Time                     `json:"updated_at" bson:"updated_at"`
+}
+
+// VariantePublicacion es una versión alternativa del texto de una publicación
+type VariantePublicacion struct {
+	ID          primitive.ObjectID `json:"id" bson:"id"`
+	Nombre      string             `json:"nombre" bson:"nombre"` // Por ejemplo "A" o "precio destacado"
+	Titulo      string             `json:"titulo" bson:"titulo"`
+	Descripcion string             `json:"descripcion" bson:"descripcion"`
 }
 
 // GrupoFacebook representa un grupo de Facebook donde se pueden hacer publicaciones
 type GrupoFacebook struct {
-	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Nombre      string             `json:"nombre" bson:"nombre"`
-	FacebookID  string             `json:"facebook_id" bson:"facebook_id"`
-	URL         string             `json:"url" bson:"url"`
-	Descripcion string             `json:"descripcion" bson:"descripcion"`
-	Activo      bool               `json:"activo" bson:"activo"`
-	CreatedAt   time.Time          `json:"created_at" bson:"created_at"`
+	ID                primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Nombre            string             `json:"nombre" bson:"nombre"`
+	FacebookID        string             `json:"facebook_id" bson:"facebook_id"`
+	URL               string             `json:"url" bson:"url"`
+	Descripcion       string             `json:"descripcion" bson:"descripcion"`
+	Activo            bool               `json:"activo" bson:"activo"`
+	IntervaloMinimo   int                `json:"intervalo_minimo" bson:"intervalo_minimo"` // Minutos entre publicaciones; 0 usa la configuración del scheduler
+	MaximoDiario      int                `json:"maximo_diario" bson:"maximo_diario"`       // Publicaciones por día; 0 usa la configuración del scheduler
+	UltimaPublicacion *time.Time         `json:"ultima_publicacion,omitempty" bson:"ultima_publicacion,omitempty"`
+	UltimoTrabajoID   primitive.ObjectID `json:"-" bson:"ultimo_trabajo_id,omitempty"`
+	Reservas          []ReservaGrupo     `json:"-" bson:"reservas,omitempty"` // Publicaciones recientes, para el máximo diario
+	CreatedAt         time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// ReservaGrupo es una publicación del scheduler en un grupo, realizada o en curso
+type ReservaGrupo struct {
+	TrabajoID primitive.ObjectID `bson:"trabajo_id"`
+	Fecha     time.Time          `bson:"fecha"`
+}
+
+// reservasDesde cuenta las reservas del grupo desde la fecha indicada
+func (g *GrupoFacebook) reservasDesde(desde time.Time) int {
+	total := 0
+	for _, r := range g.Reservas {
+		if !r.Fecha.Before(desde) {
+			total++
+		}
+	}
+	return total
 }
 
 // TipoFrecuencia define los tipos de frecuencia disponibles
@@ -139,9 +236,11 @@ type TipoFrecuencia string
 
 const (
 	FrecuenciaDiaria        TipoFrecuencia = "diaria"
+	FrecuenciaCada2Dias     TipoFrecuencia = "cada_2_dias"
 	FrecuenciaSemanal       TipoFrecuencia = "semanal"
+	FrecuenciaCada2Semanas  TipoFrecuencia = "cada_2_semanas"
 	FrecuenciaMensual       TipoFrecuencia = "mensual"
-	FrecuenciaPersonalizada TipoFrecuencia = "personalizada"
+	FrecuenciaPersonalizada TipoFrecuencia = "personalizada" // Usa la expresión cron o RRULE de Recurrencia
 )
 
 // ConfiguracionHorario define los horarios de publicación
@@ -150,31 +249,222 @@ type ConfiguracionHorario struct {
 	Minuto int `json:"minuto" bson:"minuto"` // 0-59
 }
 
+// PublicacionRotacion es una de las publicaciones que rota una programación
+type PublicacionRotacion struct {
+	PublicacionID primitive.ObjectID `json:"publicacion_id" bson:"publicacion_id"`
+	Peso          int                `json:"peso" bson:"peso"` // Solo para la rotación ponderada; 0 equivale a 1
+}
+
 // ProgramacionPublicacion representa la programación de publicaciones
 type ProgramacionPublicacion struct {
-	ID                    primitive.ObjectID     `json:"id" bson:"_id,omitempty"`
-	UserID                primitive.ObjectID     `json:"user_id" bson:"user_id"`
-	PublicacionID         primitive.ObjectID     `json:"publicacion_id" bson:"publicacion_id"`
-	GruposObjetivo        []primitive.ObjectID   `json:"grupos_objetivo" bson:"grupos_objetivo"`
-	Frecuencia            string                 `json:"frecuencia" bson:"frecuencia"` // "diaria", "cada_2_dias", "semanal", "cada_2_semanas"
-	Horarios              []ConfiguracionHorario `json:"horarios" bson:"horarios"`
-	CantidadPublicaciones int                    `json:"cantidad_publicaciones" bson:"cantidad_publicaciones"`
-	FechaInicio           time.Time              `json:"fecha_inicio" bson:"fecha_inicio"`
-	FechaFin              *time.Time             `json:"fecha_fin" bson:"fecha_fin"`
-	Estado                string                 `json:"estado" bson:"estado"` // "activa", "pausada", "completada"
-	CreatedAt             time.Time              `json:"created_at" bson:"created_at"`
-	UpdatedAt             time.Time              `json:"updated_at" bson:"updated_at"`
+	ID                    primitive.ObjectID       `json:"id" bson:"_id,omitempty"`
+	UserID                primitive.ObjectID       `json:"user_id" bson:"user_id"`
+	PublicacionID         primitive.ObjectID       `json:"publicacion_id" bson:"publicacion_id"`
+	Publicaciones         []PublicacionRotacion    `json:"publicaciones" bson:"publicaciones"` // Si tiene varias, se rotan en cada horario
+	Rotacion              string                   `json:"rotacion" bson:"rotacion"`           // "secuencial" (por defecto), "aleatoria", "ponderada"
+	GruposObjetivo        []primitive.ObjectID     `json:"grupos_objetivo" bson:"grupos_objetivo"`
+	Frecuencia            string                   `json:"frecuencia" bson:"frecuencia"`   // "diaria", "cada_2_dias", "semanal", "cada_2_semanas", "mensual", "personalizada"
+	Recurrencia           string                   `json:"recurrencia" bson:"recurrencia"` // Expresión cron ("0 9 * * 1,3,5") o RRULE ("FREQ=MONTHLY;BYDAY=1SA")
+	Horarios              []ConfiguracionHorario   `json:"horarios" bson:"horarios"`
+	CantidadPublicaciones int                      `json:"cantidad_publicaciones" bson:"cantidad_publicaciones"`
+	FechaInicio           time.Time                `json:"fecha_inicio" bson:"fecha_inicio"`
+	FechaFin              *time.Time               `json:"fecha_fin" bson:"fecha_fin"`
+	ZonaHoraria           string                   `json:"zona_horaria" bson:"zona_horaria"`                     // Si está vacía se usa la del usuario
+	PoliticaRecuperacion  string                   `json:"politica_recuperacion" bson:"politica_recuperacion"`   // "omitir", "ultima" (por defecto), "todas"
+	EspaciadoRecuperacion int                      `json:"espaciado_recuperacion" bson:"espaciado_recuperacion"` // Minutos entre horarios recuperados con "todas"
+	Restricciones         RestriccionesPublicacion `json:"restricciones" bson:"restricciones"`                   // Se suman a las del usuario
+	Estado                string                   `json:"estado" bson:"estado"`                                 // "activa", "pausada", "completada"
+	ReanudadaEn           *time.Time               `json:"reanudada_en,omitempty" bson:"reanudada_en,omitempty"` // Los horarios anteriores a la última reanudación no se recuperan
+	Disparador            *DisparadorEventos       `json:"disparador,omitempty" bson:"disparador,omitempty"`     // Si está presente, publica ante cambios de productos en lugar de seguir horarios
+	CreatedAt             time.Time                `json:"created_at" bson:"created_at"`
+	UpdatedAt             time.Time                `json:"updated_at" bson:"updated_at"`
+}
+
+// DisparadorEventos hace que una programación publique cuando cambia un
+// producto incluido en sus publicaciones
+type DisparadorEventos struct {
+	Eventos              []string             `json:"eventos" bson:"eventos"`                               // "reposicion", "baja_precio"
+	ProductoIDs          []primitive.ObjectID `json:"producto_ids,omitempty" bson:"producto_ids,omitempty"` // Si está vacío, cualquier producto de sus publicaciones
+	BajaMinimaPorcentaje float64              `json:"baja_minima_porcentaje" bson:"baja_minima_porcentaje"` // Bajas de precio menores no publican
+}
+
+// EventoProducto es un cambio de un producto que puede disparar
+// programaciones. Se guarda en la colección eventos_productos al actualizar
+// el producto y el scheduler lo procesa.
+type EventoProducto struct {
+	ID             primitive.ObjectID   `json:"id" bson:"_id,omitempty"`
+	ProductoID     primitive.ObjectID   `json:"producto_id" bson:"producto_id"`
+	Tipo           string               `json:"tipo" bson:"tipo"` // "reposicion", "baja_precio"
+	StockAnterior  int                  `json:"stock_anterior" bson:"stock_anterior"`
+	StockNuevo     int                  `json:"stock_nuevo" bson:"stock_nuevo"`
+	PrecioAnterior float64              `json:"precio_anterior" bson:"precio_anterior"`
+	PrecioNuevo    float64              `json:"precio_nuevo" bson:"precio_nuevo"`
+	Estado         string               `json:"estado" bson:"estado"`                                     // "pendiente", "procesado"
+	Programaciones []primitive.ObjectID `json:"programaciones,omitempty" bson:"programaciones,omitempty"` // Programaciones que disparó
+	CreatedAt      time.Time            `json:"created_at" bson:"created_at"`
+	ProcesadoEn    *time.Time           `json:"procesado_en,omitempty" bson:"procesado_en,omitempty"`
+}
+
+// Notificacion es un aviso para un usuario, por ejemplo que su token de
+// Facebook no pudo renovarse. La clave evita repetir el mismo aviso.
+type Notificacion struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserID    primitive.ObjectID `json:"user_id" bson:"user_id"`
+	Tipo      string             `json:"tipo" bson:"tipo"` // "token_facebook"
+	Mensaje   string             `json:"mensaje" bson:"mensaje"`
+	Clave     string             `json:"-" bson:"clave"`
+	Leida     bool               `json:"leida" bson:"leida"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// ProgramacionResumen agrega a la programación el avance de sus publicaciones
+type ProgramacionResumen struct {
+	ProgramacionPublicacion
+	PublicacionesRealizadas int  `json:"publicaciones_realizadas"`
+	PublicacionesRestantes  *int `json:"publicaciones_restantes"` // nil si no hay límite
 }
 
 // HistorialPublicacion registra cada publicación realizada
 type HistorialPublicacion struct {
-	ID               primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	ProgramacionID   primitive.ObjectID `json:"programacion_id" bson:"programacion_id"`
-	PublicacionID    primitive.ObjectID `json:"publicacion_id" bson:"publicacion_id"`
+	ID               primitive.ObjectID   `json:"id" bson:"_id,omitempty"`
+	ProgramacionID   primitive.ObjectID   `json:"programacion_id" bson:"programacion_id"`
+	PublicacionID    primitive.ObjectID   `json:"publicacion_id" bson:"publicacion_id"` // Publicación elegida por la rotación
+	GrupoID          primitive.ObjectID   `json:"grupo_id" bson:"grupo_id"`
+	UserID           primitive.ObjectID   `json:"user_id" bson:"user_id,omitempty"`
+	FechaProgramada  time.Time            `json:"fecha_programada" bson:"fecha_programada"` // Horario planificado que originó la publicación
+	FechaPublicacion time.Time            `json:"fecha_publicacion" bson:"fecha_publicacion"`
+	Estado           string               `json:"estado" bson:"estado"` // "exitosa", "fallida", "pendiente", "omitida", "interrumpida"
+	FacebookPostID   string               `json:"facebook_post_id" bson:"facebook_post_id"`
+	MensajeError     string               `json:"mensaje_error" bson:"mensaje_error"`
+	Intentos         int                  `json:"intentos" bson:"intentos"`
+	Manual           bool                 `json:"manual" bson:"manual,omitempty"` // Publicada a pedido, fuera de la recurrencia
+	VarianteID       primitive.ObjectID   `json:"variante_id,omitempty" bson:"variante_id,omitempty"`
+	Metricas         *MetricasPublicacion `json:"metricas,omitempty" bson:"metricas,omitempty"`
+	CreatedAt        time.Time            `json:"created_at" bson:"created_at"`
+}
+
+// MetricasPublicacion es la interacción obtenida por una publicación en Facebook
+type MetricasPublicacion struct {
+	Reacciones    int       `json:"reacciones" bson:"reacciones"`
+	Comentarios   int       `json:"comentarios" bson:"comentarios"`
+	Compartidos   int       `json:"compartidos" bson:"compartidos"`
+	ActualizadoEn time.Time `json:"actualizado_en" bson:"actualizado_en"`
+}
+
+// RendimientoVariante resume la interacción de una variante en un grupo
+type RendimientoVariante struct {
+	GrupoID       primitive.ObjectID `json:"grupo_id" bson:"grupo_id"`
+	VarianteID    primitive.ObjectID `json:"variante_id" bson:"variante_id"`
+	Nombre        string             `json:"nombre" bson:"-"`
+	Publicaciones int                `json:"publicaciones" bson:"publicaciones"`
+	Reacciones    int                `json:"reacciones" bson:"reacciones"`
+	Comentarios   int                `json:"comentarios" bson:"comentarios"`
+	Compartidos   int                `json:"compartidos" bson:"compartidos"`
+	Interaccion   float64            `json:"interaccion" bson:"-"` // Interacciones promedio por publicación
+	Ganadora      bool               `json:"ganadora" bson:"-"`    // Mejor variante del grupo
+}
+
+// TrabajoPublicacion es una publicación encolada para un grupo: se crea uno por
+// programación, grupo y horario, y lo ejecuta un worker del scheduler
+type TrabajoPublicacion struct {
+	ID              primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	ProgramacionID  primitive.ObjectID `json:"programacion_id" bson:"programacion_id"`
+	PublicacionID   primitive.ObjectID `json:"publicacion_id" bson:"publicacion_id"`
+	GrupoID         primitive.ObjectID `json:"grupo_id" bson:"grupo_id"`
+	UserID          primitive.ObjectID `json:"user_id" bson:"user_id"`
+	FechaProgramada time.Time          `json:"fecha_programada" bson:"fecha_programada"`
+	DisponibleDesde time.Time          `json:"disponible_desde" bson:"disponible_desde"` // No se toma antes de esta fecha
+	Estado          string             `json:"estado" bson:"estado"`                     // "pendiente", "en_proceso", "completado", "fallido", "omitido"
+	Intentos        int                `json:"intentos" bson:"intentos"`
+	Instancia       string             `json:"instancia" bson:"instancia"` // Worker que tomó el trabajo
+	LeaseHasta      time.Time          `json:"lease_hasta" bson:"lease_hasta"`
+	MensajeError    string             `json:"mensaje_error" bson:"mensaje_error"`
+	Manual          bool               `json:"manual" bson:"manual,omitempty"`
+	VarianteID      primitive.ObjectID `json:"variante_id,omitempty" bson:"variante_id,omitempty"` // Variante del texto elegida al encolar
+	CreatedAt       time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt       time.Time          `json:"updated_at" bson:"updated_at"`
+}
+
+// ResultadoGrupo es el resultado de una ejecución en un grupo
+type ResultadoGrupo struct {
 	GrupoID          primitive.ObjectID `json:"grupo_id" bson:"grupo_id"`
-	FechaPublicacion time.Time          `json:"fecha_publicacion" bson:"fecha_publicacion"`
-	Estado           string             `json:"estado" bson:"estado"` // "exitosa", "fallida", "pendiente"
-	FacebookPostID   string             `json:"facebook_post_id" bson:"facebook_post_id"`
-	MensajeError     string             `json:"mensaje_error" bson:"mensaje_error"`
-	CreatedAt        time.Time          `json:"created_at" bson:"created_at"`
+	Estado           string             `json:"estado" bson:"estado"` // "pendiente", "exitosa", "fallida", "omitida"
+	FacebookPostID   string             `json:"facebook_post_id,omitempty" bson:"facebook_post_id,omitempty"`
+	MensajeError     string             `json:"mensaje_error,omitempty" bson:"mensaje_error,omitempty"`
+	Intentos         int                `json:"intentos" bson:"intentos"`
+	FechaPublicacion *time.Time         `json:"fecha_publicacion,omitempty" bson:"fecha_publicacion,omitempty"`
+}
+
+// Ejecucion registra un horario planificado de una programación: cuándo debía
+// ejecutarse, cuándo empezó realmente y el resultado en cada grupo
+type Ejecucion struct {
+	ID              primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	ProgramacionID  primitive.ObjectID `json:"programacion_id" bson:"programacion_id"`
+	PublicacionID   primitive.ObjectID `json:"publicacion_id" bson:"publicacion_id"`
+	FechaProgramada time.Time          `json:"fecha_programada" bson:"fecha_programada"`
+	InicioReal      time.Time          `json:"inicio_real" bson:"inicio_real"`
+	FinReal         *time.Time         `json:"fin_real" bson:"fin_real"`
+	Estado          string             `json:"estado" bson:"estado"` // "en_curso", "exitosa", "parcial", "fallida", "omitida"
+	Motivo          string             `json:"motivo,omitempty" bson:"motivo,omitempty"`
+	Grupos          []ResultadoGrupo   `json:"grupos" bson:"grupos"`
+	Manual          bool               `json:"manual" bson:"manual,omitempty"` // No cuenta para la recurrencia ni para la cantidad de publicaciones
+	CreatedAt       time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt       time.Time          `json:"updated_at" bson:"updated_at"`
+}
+
+// GrupoVistaPrevia identifica un grupo en la vista previa de una programación
+type GrupoVistaPrevia struct {
+	ID         primitive.ObjectID `json:"id"`
+	Nombre     string             `json:"nombre"`
+	FacebookID string             `json:"facebook_id"`
+}
+
+// OcurrenciaVistaPrevia es un horario futuro de una programación con sus grupos
+type OcurrenciaVistaPrevia struct {
+	Fecha         time.Time           `json:"fecha"`
+	PublicacionID *primitive.ObjectID `json:"publicacion_id,omitempty"` // nil si el horario se omite
+	DiferidaHasta *time.Time          `json:"diferida_hasta,omitempty"` // Cae en horario de silencio o fecha bloqueada
+	Omitida       bool                `json:"omitida,omitempty"`
+	Motivo        string              `json:"motivo,omitempty"`
+	Grupos        []GrupoVistaPrevia  `json:"grupos"`
+}
+
+// PublicacionVistaPrevia es una publicación de la programación con el mensaje
+// tal como se publicaría
+type PublicacionVistaPrevia struct {
+	ID        primitive.ObjectID `json:"id"`
+	Titulo    string             `json:"titulo"`
+	Mensaje   string             `json:"mensaje"`
+	ImagenURL string             `json:"imagen_url"`
+}
+
+// VistaPreviaProgramacion muestra cuándo, dónde y qué publicaría una programación
+type VistaPreviaProgramacion struct {
+	ZonaHoraria   string                   `json:"zona_horaria"`
+	Rotacion      string                   `json:"rotacion"`
+	Publicaciones []PublicacionVistaPrevia `json:"publicaciones"`
+	Grupos        []GrupoVistaPrevia       `json:"grupos"`
+	Ocurrencias   []OcurrenciaVistaPrevia  `json:"ocurrencias"`
+	Truncada      bool                     `json:"truncada"` // Se alcanzó el límite de ocurrencias
+}
+
+// ProximaProgramacion es el siguiente horario de una programación activa
+type ProximaProgramacion struct {
+	ProgramacionID primitive.ObjectID `json:"programacion_id"`
+	UserID         primitive.ObjectID `json:"user_id"`
+	Horario        time.Time          `json:"horario"`
+}
+
+// EstadoScheduler resume el funcionamiento del scheduler en esta instancia
+type EstadoScheduler struct {
+	Activo                 bool                  `json:"activo"` // El loop del scheduler está corriendo en esta instancia
+	Lider                  bool                  `json:"lider"`  // Esta instancia encola las programaciones
+	Instancia              string                `json:"instancia"`
+	Workers                int                   `json:"workers"`
+	TrabajosEnCurso        int                   `json:"trabajos_en_curso"` // Publicaciones de los workers de esta instancia
+	UltimoTick             *time.Time            `json:"ultimo_tick"`
+	DuracionUltimoTickMs   int64                 `json:"duracion_ultimo_tick_ms"`
+	Cola                   map[string]int        `json:"cola"`                    // Trabajos pendientes y en proceso (todas las instancias)
+	ProximasProgramaciones []ProximaProgramacion `json:"proximas_programaciones"` // Horarios de las próximas 24 horas
+	Ultimas24Horas         map[string]int        `json:"ultimas_24_horas"`        // Publicaciones del historial por estado
 }
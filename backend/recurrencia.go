@@ -0,0 +1,661 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// horizonteRecurrencia limita la búsqueda de la siguiente ocurrencia; cubre
+// reglas anuales y el 29 de febrero
+const horizonteRecurrencia = 8 * 366
+
+// Recurrencia calcula las ocurrencias de una programación
+type Recurrencia interface {
+	// Next devuelve la primera ocurrencia estrictamente posterior a after,
+	// o el tiempo cero si la recurrencia ya no tiene más ocurrencias
+	Next(after time.Time) time.Time
+}
+
+// ParseRecurrencia construye la recurrencia de una programación a partir de su
+// frecuencia. Las frecuencias predefinidas usan los horarios configurados; la
+// frecuencia "personalizada" acepta una expresión cron de 5 campos o una RRULE
+// de iCalendar en el campo Recurrencia.
+func ParseRecurrencia(prog ProgramacionPublicacion, loc *time.Location) (Recurrencia, error) {
+	if err := validarHorarios(prog.Horarios); err != nil {
+		return nil, err
+	}
+
+	inicio := prog.FechaInicio.In(loc)
+
+	switch TipoFrecuencia(prog.Frecuencia) {
+	case FrecuenciaDiaria, "": // Las programaciones guardadas sin frecuencia son diarias
+		return nuevaRegla("DAILY", 1, prog, loc), nil
+	case FrecuenciaCada2Dias:
+		return nuevaRegla("DAILY", 2, prog, loc), nil
+	case FrecuenciaSemanal:
+		return nuevaRegla("WEEKLY", 1, prog, loc), nil
+	case FrecuenciaCada2Semanas:
+		return nuevaRegla("WEEKLY", 2, prog, loc), nil
+	case FrecuenciaMensual:
+		return nuevaRegla("MONTHLY", 1, prog, loc), nil
+	case FrecuenciaPersonalizada:
+		expresion := strings.TrimSpace(prog.Recurrencia)
+		if expresion == "" {
+			return nil, errors.New("la frecuencia personalizada requiere una expresión cron o RRULE en 'recurrencia'")
+		}
+		if strings.Contains(strings.ToUpper(expresion), "FREQ=") {
+			regla, err := parseRRule(expresion, prog, loc)
+			if err != nil {
+				return nil, fmt.Errorf("RRULE inválida: %v", err)
+			}
+			return regla, nil
+		}
+		cron, err := parseCron(expresion, inicio, loc)
+		if err != nil {
+			return nil, fmt.Errorf("expresión cron inválida: %v", err)
+		}
+		return cron, nil
+	default:
+		return nil, fmt.Errorf("frecuencia desconocida %q: use diaria, cada_2_dias, semanal, cada_2_semanas, mensual o personalizada", prog.Frecuencia)
+	}
+}
+
+// validarHorarios verifica que los horarios configurados sean válidos
+func validarHorarios(horarios []ConfiguracionHorario) error {
+	for _, h := range horarios {
+		if h.Hora < 0 || h.Hora > 23 || h.Minuto < 0 || h.Minuto > 59 {
+			return fmt.Errorf("horario inválido %02d:%02d", h.Hora, h.Minuto)
+		}
+	}
+	return nil
+}
+
+// horariosDelDia devuelve los horarios de la programación ordenados; si no
+// hay horarios configurados se usa la hora de la fecha de inicio
+func horariosDelDia(prog ProgramacionPublicacion, loc *time.Location) []ConfiguracionHorario {
+	if len(prog.Horarios) == 0 {
+		inicio := prog.FechaInicio.In(loc)
+		return []ConfiguracionHorario{{Hora: inicio.Hour(), Minuto: inicio.Minute()}}
+	}
+
+	horarios := make([]ConfiguracionHorario, len(prog.Horarios))
+	copy(horarios, prog.Horarios)
+	ordenarHorarios(horarios)
+
+	return horarios
+}
+
+func ordenarHorarios(horarios []ConfiguracionHorario) {
+	sort.Slice(horarios, func(i, j int) bool {
+		if horarios[i].Hora != horarios[j].Hora {
+			return horarios[i].Hora < horarios[j].Hora
+		}
+		return horarios[i].Minuto < horarios[j].Minuto
+	})
+}
+
+// diasEntre devuelve los días de calendario entre las fechas de a y b
+func diasEntre(a, b time.Time) int {
+	da := time.Date(a.Year(), a.Month(), a.Day(), 12, 0, 0, 0, time.UTC)
+	db := time.Date(b.Year(), b.Month(), b.Day(), 12, 0, 0, 0, time.UTC)
+	return int(db.Sub(da).Hours() / 24)
+}
+
+// inicioDelDia devuelve la medianoche de la fecha de t en loc
+func inicioDelDia(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return fechaHoraLocal(t.Year(), t.Month(), t.Day(), 0, 0, loc)
+}
+
+// fechaHoraLocal arma la hora de pared indicada en loc. Si la hora no existe
+// porque cae en el salto del cambio al horario de verano (por ejemplo las
+// 02:30 en America/New_York), devuelve el primer instante válido después del
+// salto en lugar de la hora normalizada hacia atrás que arma time.Date.
+func fechaHoraLocal(anio int, mes time.Month, dia, hora, minuto int, loc *time.Location) time.Time {
+	t := time.Date(anio, mes, dia, hora, minuto, 0, 0, loc)
+	if t.Hour() == hora && t.Minute() == minuto {
+		return t
+	}
+
+	// El salto es el límite del período de la zona en el que cayó t
+	inicioZona, finZona := t.ZoneBounds()
+	pedida := time.Date(anio, mes, dia, hora, minuto, 0, 0, time.UTC)
+	obtenida := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, time.UTC)
+	if obtenida.Before(pedida) {
+		return finZona
+	}
+	return inicioZona
+}
+
+// diasEnMes devuelve la cantidad de días del mes de t
+func diasEnMes(t time.Time) int {
+	return time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// diaSemanaRegla representa un valor de BYDAY, opcionalmente con ordinal
+// (por ejemplo 1SA es el primer sábado y -1FR el último viernes)
+type diaSemanaRegla struct {
+	dia     time.Weekday
+	ordinal int
+}
+
+// reglaRecurrencia implementa un subconjunto de RRULE (RFC 5545) evaluado
+// día por día en la zona horaria de la programación
+type reglaRecurrencia struct {
+	frecuencia string // DAILY, WEEKLY, MONTHLY, YEARLY
+	intervalo  int
+	porDia     []diaSemanaRegla
+	porDiaMes  []int
+	porMes     []time.Month
+	horarios   []ConfiguracionHorario
+	cuenta     int
+	hasta      *time.Time
+	inicio     time.Time
+	loc        *time.Location
+}
+
+func nuevaRegla(frecuencia string, intervalo int, prog ProgramacionPublicacion, loc *time.Location) *reglaRecurrencia {
+	return &reglaRecurrencia{
+		frecuencia: frecuencia,
+		intervalo:  intervalo,
+		horarios:   horariosDelDia(prog, loc),
+		inicio:     prog.FechaInicio.In(loc),
+		loc:        loc,
+	}
+}
+
+var diasRRule = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// parseRRule interpreta una RRULE; DTSTART es la fecha de inicio de la programación
+func parseRRule(expresion string, prog ProgramacionPublicacion, loc *time.Location) (*reglaRecurrencia, error) {
+	expresion = strings.TrimSpace(expresion)
+	if len(expresion) >= 6 && strings.EqualFold(expresion[:6], "RRULE:") {
+		expresion = expresion[6:]
+	}
+
+	regla := nuevaRegla("", 1, prog, loc)
+	var horas, minutos []int
+
+	for _, parte := range strings.Split(expresion, ";") {
+		if parte == "" {
+			continue
+		}
+		clave, valor, ok := strings.Cut(parte, "=")
+		if !ok {
+			return nil, fmt.Errorf("parámetro mal formado %q", parte)
+		}
+		clave = strings.ToUpper(strings.TrimSpace(clave))
+		valor = strings.ToUpper(strings.TrimSpace(valor))
+
+		var err error
+		switch clave {
+		case "FREQ":
+			switch valor {
+			case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+				regla.frecuencia = valor
+			default:
+				return nil, fmt.Errorf("FREQ no soportada %q", valor)
+			}
+		case "INTERVAL":
+			regla.intervalo, err = strconv.Atoi(valor)
+			if err != nil || regla.intervalo < 1 {
+				return nil, fmt.Errorf("INTERVAL inválido %q", valor)
+			}
+		case "COUNT":
+			regla.cuenta, err = strconv.Atoi(valor)
+			if err != nil || regla.cuenta < 1 {
+				return nil, fmt.Errorf("COUNT inválido %q", valor)
+			}
+		case "UNTIL":
+			hasta, err := parseFechaRRule(valor, loc)
+			if err != nil {
+				return nil, err
+			}
+			regla.hasta = &hasta
+		case "BYDAY":
+			for _, v := range strings.Split(valor, ",") {
+				if len(v) < 2 {
+					return nil, fmt.Errorf("BYDAY inválido %q", v)
+				}
+				dia, ok := diasRRule[v[len(v)-2:]]
+				if !ok {
+					return nil, fmt.Errorf("BYDAY inválido %q", v)
+				}
+				ordinal := 0
+				if prefijo := v[:len(v)-2]; prefijo != "" {
+					ordinal, err = strconv.Atoi(prefijo)
+					if err != nil || ordinal == 0 || ordinal < -53 || ordinal > 53 {
+						return nil, fmt.Errorf("BYDAY inválido %q", v)
+					}
+				}
+				regla.porDia = append(regla.porDia, diaSemanaRegla{dia: dia, ordinal: ordinal})
+			}
+		case "BYMONTHDAY":
+			regla.porDiaMes, err = parseListaEnteros(valor, -31, 31, true)
+			if err != nil {
+				return nil, fmt.Errorf("BYMONTHDAY inválido: %v", err)
+			}
+		case "BYMONTH":
+			meses, err := parseListaEnteros(valor, 1, 12, false)
+			if err != nil {
+				return nil, fmt.Errorf("BYMONTH inválido: %v", err)
+			}
+			for _, m := range meses {
+				regla.porMes = append(regla.porMes, time.Month(m))
+			}
+		case "BYHOUR":
+			horas, err = parseListaEnteros(valor, 0, 23, false)
+			if err != nil {
+				return nil, fmt.Errorf("BYHOUR inválido: %v", err)
+			}
+		case "BYMINUTE":
+			minutos, err = parseListaEnteros(valor, 0, 59, false)
+			if err != nil {
+				return nil, fmt.Errorf("BYMINUTE inválido: %v", err)
+			}
+		case "WKST":
+			if valor != "MO" {
+				return nil, errors.New("solo se soporta WKST=MO")
+			}
+		default:
+			return nil, fmt.Errorf("parámetro no soportado %q", clave)
+		}
+	}
+
+	if regla.frecuencia == "" {
+		return nil, errors.New("FREQ es requerido")
+	}
+	if regla.cuenta > 0 && regla.hasta != nil {
+		return nil, errors.New("COUNT y UNTIL no pueden usarse juntos")
+	}
+	for _, d := range regla.porDia {
+		if d.ordinal != 0 && regla.frecuencia != "MONTHLY" && regla.frecuencia != "YEARLY" {
+			return nil, errors.New("BYDAY con ordinal solo se permite con FREQ=MONTHLY o YEARLY")
+		}
+	}
+
+	// BYHOUR/BYMINUTE reemplazan a los horarios de la programación
+	if len(horas) > 0 || len(minutos) > 0 {
+		if len(horas) == 0 {
+			horas = []int{regla.inicio.Hour()}
+		}
+		if len(minutos) == 0 {
+			minutos = []int{regla.inicio.Minute()}
+		}
+		regla.horarios = nil
+		for _, h := range horas {
+			for _, m := range minutos {
+				regla.horarios = append(regla.horarios, ConfiguracionHorario{Hora: h, Minuto: m})
+			}
+		}
+		ordenarHorarios(regla.horarios)
+	}
+
+	return regla, nil
+}
+
+// parseFechaRRule interpreta UNTIL en formato fecha (20261231) o fecha-hora
+// (20261231T235959 o 20261231T235959Z)
+func parseFechaRRule(valor string, loc *time.Location) (time.Time, error) {
+	for _, formato := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		zona := loc
+		if strings.HasSuffix(formato, "Z") {
+			zona = time.UTC
+		}
+		t, err := time.ParseInLocation(formato, valor, zona)
+		if err != nil {
+			continue
+		}
+		if formato == "20060102" {
+			// Una fecha UNTIL incluye el día completo
+			t = t.AddDate(0, 0, 1).Add(-time.Second)
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("UNTIL inválido %q", valor)
+}
+
+// parseListaEnteros interpreta una lista separada por comas dentro de [min, max]
+func parseListaEnteros(valor string, min, max int, sinCero bool) ([]int, error) {
+	var valores []int
+	for _, v := range strings.Split(valor, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil || n < min || n > max || (sinCero && n == 0) {
+			return nil, fmt.Errorf("valor fuera de rango %q", v)
+		}
+		valores = append(valores, n)
+	}
+	return valores, nil
+}
+
+// Next implementa Recurrencia
+func (r *reglaRecurrencia) Next(after time.Time) time.Time {
+	dia := inicioDelDia(r.inicio, r.loc)
+
+	// Con COUNT hay que contar las ocurrencias desde el inicio
+	if r.cuenta == 0 && after.After(dia) {
+		dia = inicioDelDia(after, r.loc)
+	}
+
+	ocurrencias := 0
+	for i := 0; i < horizonteRecurrencia+r.cuenta; i++ {
+		if r.coincide(dia) {
+			for _, h := range r.horarios {
+				candidato := fechaHoraLocal(dia.Year(), dia.Month(), dia.Day(), h.Hora, h.Minuto, r.loc)
+				if candidato.Before(r.inicio) {
+					continue
+				}
+				if r.hasta != nil && candidato.After(*r.hasta) {
+					return time.Time{}
+				}
+				ocurrencias++
+				if r.cuenta > 0 && ocurrencias > r.cuenta {
+					return time.Time{}
+				}
+				if candidato.After(after) {
+					return candidato
+				}
+			}
+		}
+		dia = dia.AddDate(0, 0, 1)
+	}
+
+	return time.Time{}
+}
+
+// coincide indica si la fecha de dia pertenece a la regla
+func (r *reglaRecurrencia) coincide(dia time.Time) bool {
+	inicio := r.inicio
+
+	// Periodo según FREQ e INTERVAL
+	switch r.frecuencia {
+	case "DAILY":
+		if diasEntre(inicio, dia)%r.intervalo != 0 {
+			return false
+		}
+	case "WEEKLY":
+		lunesInicio := inicio.AddDate(0, 0, -((int(inicio.Weekday()) + 6) % 7))
+		if (diasEntre(lunesInicio, dia)/7)%r.intervalo != 0 {
+			return false
+		}
+	case "MONTHLY":
+		meses := (dia.Year()-inicio.Year())*12 + int(dia.Month()-inicio.Month())
+		if meses%r.intervalo != 0 {
+			return false
+		}
+	case "YEARLY":
+		if (dia.Year()-inicio.Year())%r.intervalo != 0 {
+			return false
+		}
+	}
+
+	if len(r.porMes) > 0 && !contieneMes(r.porMes, dia.Month()) {
+		return false
+	}
+
+	if len(r.porDiaMes) > 0 && !r.coincideDiaMes(dia) {
+		return false
+	}
+
+	if len(r.porDia) > 0 && !r.coincideDiaSemana(dia) {
+		return false
+	}
+
+	// Sin BYDAY ni BYMONTHDAY se repite el día de la fecha de inicio
+	if len(r.porDia) == 0 && len(r.porDiaMes) == 0 {
+		switch r.frecuencia {
+		case "WEEKLY":
+			return dia.Weekday() == inicio.Weekday()
+		case "MONTHLY":
+			return dia.Day() == inicio.Day()
+		case "YEARLY":
+			if len(r.porMes) == 0 && dia.Month() != inicio.Month() {
+				return false
+			}
+			return dia.Day() == inicio.Day()
+		}
+	}
+
+	return true
+}
+
+func (r *reglaRecurrencia) coincideDiaMes(dia time.Time) bool {
+	total := diasEnMes(dia)
+	for _, d := range r.porDiaMes {
+		if d > 0 && dia.Day() == d {
+			return true
+		}
+		if d < 0 && dia.Day() == total+d+1 {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *reglaRecurrencia) coincideDiaSemana(dia time.Time) bool {
+	for _, d := range r.porDia {
+		if d.dia != dia.Weekday() {
+			continue
+		}
+		if d.ordinal == 0 {
+			return true
+		}
+
+		// El ordinal se cuenta dentro del mes, salvo en reglas anuales sin BYMONTH
+		var posicion, total int
+		if r.frecuencia == "YEARLY" && len(r.porMes) == 0 {
+			posicion = (dia.YearDay()-1)/7 + 1
+			diasAnio := time.Date(dia.Year(), 12, 31, 0, 0, 0, 0, time.UTC).YearDay()
+			total = (diasAnio-dia.YearDay())/7 + posicion
+		} else {
+			posicion = (dia.Day()-1)/7 + 1
+			total = (diasEnMes(dia)-dia.Day())/7 + posicion
+		}
+
+		if d.ordinal > 0 && posicion == d.ordinal {
+			return true
+		}
+		if d.ordinal < 0 && total-posicion+1 == -d.ordinal {
+			return true
+		}
+	}
+	return false
+}
+
+func contieneMes(meses []time.Month, mes time.Month) bool {
+	for _, m := range meses {
+		if m == mes {
+			return true
+		}
+	}
+	return false
+}
+
+// expresionCron implementa una expresión cron estándar de 5 campos
+// (minuto hora día-del-mes mes día-de-la-semana)
+type expresionCron struct {
+	minutos        [60]bool
+	horas          [24]bool
+	diasMes        [32]bool
+	meses          [13]bool
+	diasSemana     [7]bool
+	diaMesLibre    bool
+	diaSemanaLibre bool
+	inicio         time.Time
+	loc            *time.Location
+}
+
+var macrosCron = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var nombresMesCron = map[string]int{
+	"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+	"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+}
+
+var nombresDiaCron = map[string]int{
+	"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6,
+}
+
+// parseCron interpreta una expresión cron; acepta listas, rangos, pasos,
+// nombres de meses y días en inglés y las macros @daily, @weekly, etc.
+func parseCron(expresion string, inicio time.Time, loc *time.Location) (*expresionCron, error) {
+	expresion = strings.TrimSpace(expresion)
+	if len(expresion) >= 5 && strings.EqualFold(expresion[:5], "cron:") {
+		expresion = strings.TrimSpace(expresion[5:])
+	}
+	if macro, ok := macrosCron[strings.ToLower(expresion)]; ok {
+		expresion = macro
+	}
+
+	campos := strings.Fields(expresion)
+	if len(campos) != 5 {
+		return nil, fmt.Errorf("se esperaban 5 campos y se recibieron %d", len(campos))
+	}
+
+	cron := &expresionCron{inicio: inicio, loc: loc}
+
+	if err := parseCampoCron(campos[0], 0, 59, nil, cron.minutos[:]); err != nil {
+		return nil, fmt.Errorf("minuto: %v", err)
+	}
+	if err := parseCampoCron(campos[1], 0, 23, nil, cron.horas[:]); err != nil {
+		return nil, fmt.Errorf("hora: %v", err)
+	}
+	if err := parseCampoCron(campos[2], 1, 31, nil, cron.diasMes[:]); err != nil {
+		return nil, fmt.Errorf("día del mes: %v", err)
+	}
+	if err := parseCampoCron(campos[3], 1, 12, nombresMesCron, cron.meses[:]); err != nil {
+		return nil, fmt.Errorf("mes: %v", err)
+	}
+
+	// El domingo puede escribirse como 0 o 7
+	var diasSemana [8]bool
+	if err := parseCampoCron(campos[4], 0, 7, nombresDiaCron, diasSemana[:]); err != nil {
+		return nil, fmt.Errorf("día de la semana: %v", err)
+	}
+	copy(cron.diasSemana[:], diasSemana[:7])
+	cron.diasSemana[0] = cron.diasSemana[0] || diasSemana[7]
+
+	cron.diaMesLibre = strings.HasPrefix(campos[2], "*")
+	cron.diaSemanaLibre = strings.HasPrefix(campos[4], "*")
+
+	return cron, nil
+}
+
+// parseCampoCron marca en valores las posiciones incluidas por el campo
+func parseCampoCron(campo string, min, max int, nombres map[string]int, valores []bool) error {
+	for _, parte := range strings.Split(campo, ",") {
+		rango, paso := parte, 1
+		if r, p, ok := strings.Cut(parte, "/"); ok {
+			n, err := strconv.Atoi(p)
+			if err != nil || n < 1 {
+				return fmt.Errorf("paso inválido %q", parte)
+			}
+			rango, paso = r, n
+		}
+
+		desde, hasta := min, max
+		if rango != "*" {
+			d, h, esRango := strings.Cut(rango, "-")
+			var err error
+			if desde, err = valorCron(d, nombres); err != nil {
+				return err
+			}
+			hasta = desde
+			if esRango {
+				if hasta, err = valorCron(h, nombres); err != nil {
+					return err
+				}
+			} else if paso > 1 {
+				// "5/15" equivale a "5-max/15"
+				hasta = max
+			}
+		}
+
+		if desde < min || hasta > max || desde > hasta {
+			return fmt.Errorf("valor fuera de rango %q", parte)
+		}
+		for v := desde; v <= hasta; v += paso {
+			valores[v] = true
+		}
+	}
+	return nil
+}
+
+func valorCron(valor string, nombres map[string]int) (int, error) {
+	if n, ok := nombres[strings.ToUpper(valor)]; ok {
+		return n, nil
+	}
+	n, err := strconv.Atoi(valor)
+	if err != nil {
+		return 0, fmt.Errorf("valor inválido %q", valor)
+	}
+	return n, nil
+}
+
+// Next implementa Recurrencia
+func (c *expresionCron) Next(after time.Time) time.Time {
+	dia := inicioDelDia(c.inicio, c.loc)
+	if after.After(dia) {
+		dia = inicioDelDia(after, c.loc)
+	}
+
+	for i := 0; i < horizonteRecurrencia; i++ {
+		if c.coincide(dia) {
+			for h := 0; h < 24; h++ {
+				if !c.horas[h] {
+					continue
+				}
+				for m := 0; m < 60; m++ {
+					if !c.minutos[m] {
+						continue
+					}
+					candidato := fechaHoraLocal(dia.Year(), dia.Month(), dia.Day(), h, m, c.loc)
+					if candidato.Before(c.inicio) || !candidato.After(after) {
+						continue
+					}
+					return candidato
+				}
+			}
+		}
+		dia = dia.AddDate(0, 0, 1)
+	}
+
+	return time.Time{}
+}
+
+// coincide aplica la semántica de cron: si día del mes y día de la semana
+// están restringidos basta con que coincida uno de los dos
+func (c *expresionCron) coincide(dia time.Time) bool {
+	if !c.meses[dia.Month()] {
+		return false
+	}
+
+	diaMes := c.diasMes[dia.Day()]
+	diaSemana := c.diasSemana[dia.Weekday()]
+
+	switch {
+	case c.diaMesLibre && c.diaSemanaLibre:
+		return true
+	case c.diaMesLibre:
+		return diaSemana
+	case c.diaSemanaLibre:
+		return diaMes
+	default:
+		return diaMes || diaSemana
+	}
+}
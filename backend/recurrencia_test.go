@@ -0,0 +1,209 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// ocurrencias devuelve hasta n ocurrencias de la recurrencia desde su inicio
+func ocurrencias(t *testing.T, expresion string, inicio time.Time, n int) []string {
+	t.Helper()
+
+	prog := ProgramacionPublicacion{Frecuencia: string(FrecuenciaPersonalizada), Recurrencia: expresion, FechaInicio: inicio}
+	rec, err := ParseRecurrencia(prog, inicio.Location())
+	if err != nil {
+		t.Fatalf("ParseRecurrencia(%q): %v", expresion, err)
+	}
+
+	var fechas []string
+	for fecha := inicio.Add(-time.Minute); len(fechas) < n; {
+		if fecha = rec.Next(fecha); fecha.IsZero() {
+			break
+		}
+		fechas = append(fechas, fecha.Format("2006-01-02 15:04"))
+	}
+	return fechas
+}
+
+func TestRRule(t *testing.T) {
+	// Miércoles; sin horarios se publica a la hora de inicio
+	inicio := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	casos := []struct {
+		nombre    string
+		regla     string
+		n         int // Más que las esperadas cuando la regla termina
+		esperadas []string
+	}{
+		{
+			nombre:    "último viernes del mes",
+			regla:     "FREQ=MONTHLY;BYDAY=-1FR",
+			n:         3,
+			esperadas: []string{"2025-01-31 09:00", "2025-02-28 09:00", "2025-03-28 09:00"},
+		},
+		{
+			nombre:    "segundo lunes del mes",
+			regla:     "FREQ=MONTHLY;BYDAY=2MO",
+			n:         3,
+			esperadas: []string{"2025-01-13 09:00", "2025-02-10 09:00", "2025-03-10 09:00"},
+		},
+		{
+			nombre:    "COUNT termina la regla",
+			regla:     "FREQ=DAILY;COUNT=3",
+			n:         5,
+			esperadas: []string{"2025-01-01 09:00", "2025-01-02 09:00", "2025-01-03 09:00"},
+		},
+		{
+			nombre:    "UNTIL con fecha incluye ese día",
+			regla:     "FREQ=WEEKLY;BYDAY=MO;UNTIL=20250120",
+			n:         5,
+			esperadas: []string{"2025-01-06 09:00", "2025-01-13 09:00", "2025-01-20 09:00"},
+		},
+		{
+			nombre:    "UNTIL en UTC antes del horario",
+			regla:     "FREQ=DAILY;UNTIL=20250102T085959Z",
+			n:         3,
+			esperadas: []string{"2025-01-01 09:00"},
+		},
+		{
+			nombre:    "INTERVAL diario",
+			regla:     "FREQ=DAILY;INTERVAL=3",
+			n:         3,
+			esperadas: []string{"2025-01-01 09:00", "2025-01-04 09:00", "2025-01-07 09:00"},
+		},
+		{
+			nombre:    "INTERVAL semanal cuenta semanas desde el lunes del inicio",
+			regla:     "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,TH",
+			n:         4,
+			esperadas: []string{"2025-01-02 09:00", "2025-01-14 09:00", "2025-01-16 09:00", "2025-01-28 09:00"},
+		},
+		{
+			nombre:    "último día de febrero con BYHOUR",
+			regla:     "RRULE:FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=-1;BYHOUR=18",
+			n:         4,
+			esperadas: []string{"2025-02-28 18:00", "2026-02-28 18:00", "2027-02-28 18:00", "2028-02-29 18:00"},
+		},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			compararFechas(t, ocurrencias(t, caso.regla, inicio, caso.n), caso.esperadas)
+		})
+	}
+}
+
+func TestCron(t *testing.T) {
+	// Miércoles a medianoche
+	inicio := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	casos := []struct {
+		nombre    string
+		expresion string
+		n         int
+		esperadas []string
+	}{
+		{
+			nombre:    "día del mes o día de la semana",
+			expresion: "0 9 13 * 5",
+			n:         4,
+			esperadas: []string{"2025-01-03 09:00", "2025-01-10 09:00", "2025-01-13 09:00", "2025-01-17 09:00"},
+		},
+		{
+			nombre:    "solo día del mes",
+			expresion: "0 9 13 * *",
+			n:         2,
+			esperadas: []string{"2025-01-13 09:00", "2025-02-13 09:00"},
+		},
+		{
+			nombre:    "pasos y rangos",
+			expresion: "*/20 8-9 * * 1-5",
+			n:         7,
+			esperadas: []string{
+				"2025-01-01 08:00", "2025-01-01 08:20", "2025-01-01 08:40",
+				"2025-01-01 09:00", "2025-01-01 09:20", "2025-01-01 09:40",
+				"2025-01-02 08:00",
+			},
+		},
+		{
+			nombre:    "paso desde un valor",
+			expresion: "15/30 10 1 * *",
+			n:         3,
+			esperadas: []string{"2025-01-01 10:15", "2025-01-01 10:45", "2025-02-01 10:15"},
+		},
+		{
+			nombre:    "nombres de meses y días",
+			expresion: "30 12 * JAN,MAR SUN",
+			n:         5,
+			esperadas: []string{"2025-01-05 12:30", "2025-01-12 12:30", "2025-01-19 12:30", "2025-01-26 12:30", "2025-03-02 12:30"},
+		},
+		{
+			nombre:    "domingo como 7",
+			expresion: "0 0 * * 7",
+			n:         2,
+			esperadas: []string{"2025-01-05 00:00", "2025-01-12 00:00"},
+		},
+		{
+			nombre:    "macro",
+			expresion: "@weekly",
+			n:         2,
+			esperadas: []string{"2025-01-05 00:00", "2025-01-12 00:00"},
+		},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			compararFechas(t, ocurrencias(t, caso.expresion, inicio, caso.n), caso.esperadas)
+		})
+	}
+}
+
+func TestRecurrenciaInvalida(t *testing.T) {
+	inicio := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	expresiones := []string{
+		"",
+		// RRULE
+		"FREQ=HOURLY",
+		"BYDAY=MO;FREQ=",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=-1",
+		"FREQ=DAILY;COUNT=2;UNTIL=20250110",
+		"FREQ=DAILY;UNTIL=mañana",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=MONTHLY;BYDAY=0MO",
+		"FREQ=MONTHLY;BYDAY=54MO",
+		"FREQ=MONTHLY;BYDAY=XX",
+		"FREQ=MONTHLY;BYDAY=",
+		"FREQ=MONTHLY;BYMONTHDAY=0",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=YEARLY;BYMONTH=13",
+		"FREQ=DAILY;BYHOUR=24",
+		"FREQ=DAILY;BYMINUTE=a",
+		"FREQ=DAILY;WKST=SU",
+		"FREQ=DAILY;BYSETPOS=1",
+		"FREQ=DAILY;INTERVAL",
+		// Cron
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"5-1 * * * *",
+		"- * * * *",
+		"1- * * * *",
+		"* * * FOO *",
+		"a b c d e",
+		"@every",
+	}
+
+	for _, expresion := range expresiones {
+		prog := ProgramacionPublicacion{Frecuencia: string(FrecuenciaPersonalizada), Recurrencia: expresion, FechaInicio: inicio}
+		if _, err := ParseRecurrencia(prog, time.UTC); err == nil {
+			t.Errorf("ParseRecurrencia(%q) no devolvió error", expresion)
+		}
+	}
+}
@@ -0,0 +1,632 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Clock devuelve la hora actual. El scheduler y sus componentes la obtienen
+// de aquí para que las pruebas puedan simular el paso del tiempo.
+type Clock interface {
+	Now() time.Time
+}
+
+// relojSistema es el Clock de producción
+type relojSistema struct{}
+
+func (relojSistema) Now() time.Time {
+	return time.Now()
+}
+
+// ProgramacionRepository accede a las programaciones
+type ProgramacionRepository interface {
+	// Get devuelve mongo.ErrNoDocuments si la programación no existe
+	Get(id primitive.ObjectID) (*ProgramacionPublicacion, error)
+	// FindActive devuelve las programaciones activas que empezaron antes de now
+	FindActive(now time.Time) ([]ProgramacionPublicacion, error)
+	// Complete marca la programación como completada si sigue activa
+	Complete(id primitive.ObjectID, now time.Time) (bool, error)
+	// ChangeEstado pasa la programación del estado desde al estado hasta;
+	// devuelve nil si no estaba en el estado desde
+	ChangeEstado(id primitive.ObjectID, desde, hasta string, reanudadaEn *time.Time, now time.Time) (*ProgramacionPublicacion, error)
+}
+
+// PublicacionRepository accede a las publicaciones
+type PublicacionRepository interface {
+	// Get devuelve mongo.ErrNoDocuments si la publicación no existe
+	Get(id primitive.ObjectID) (*Publicacion, error)
+	// SetVariantesGanadoras agrega las variantes ganadoras por grupo
+	SetVariantesGanadoras(id primitive.ObjectID, ganadoras map[string]primitive.ObjectID) error
+	// FindIDsByProducto devuelve las publicaciones que incluyen el producto
+	FindIDsByProducto(productoID primitive.ObjectID) ([]primitive.ObjectID, error)
+}
+
+// ProductoRepository accede a los productos
+type ProductoRepository interface {
+	FindByIDs(ids []primitive.ObjectID) ([]Producto, error)
+}
+
+// GrupoRepository accede a los grupos de Facebook
+type GrupoRepository interface {
+	FindByIDs(ids []primitive.ObjectID) ([]GrupoFacebook, error)
+	// Reserve marca al grupo como publicado por el trabajo si su última
+	// publicación es anterior a now-intervalo y tiene menos del máximo de
+	// reservas en el día, o si ya está reservado por el mismo trabajo. Si no
+	// puede reservarlo devuelve false y el grupo.
+	Reserve(grupoID, trabajoID primitive.ObjectID, now time.Time, limite limiteGrupo) (bool, *GrupoFacebook, error)
+	// Release quita la reserva del trabajo
+	Release(grupoID, trabajoID primitive.ObjectID) error
+}
+
+// HistorialRepository accede al historial de publicaciones
+type HistorialRepository interface {
+	Insert(registros ...HistorialPublicacion) error
+	// Replace reemplaza el registro con el mismo ID
+	Replace(registro HistorialPublicacion) error
+	// LastScheduled devuelve la última publicación planificada (no manual)
+	// de la programación, o nil si no hay ninguna
+	LastScheduled(programacionID primitive.ObjectID) (*HistorialPublicacion, error)
+	CountSuccessfulByUser(userID primitive.ObjectID, desde time.Time) (int, error)
+	// CountVariantes cuenta las publicaciones con variante de la publicación en el grupo
+	CountVariantes(publicacionID, grupoID primitive.ObjectID) (int, error)
+	// PendingEngagement devuelve las publicaciones exitosas con variante desde
+	// la fecha indicada cuyas métricas no se actualizaron después de actualizadoAntes
+	PendingEngagement(desde, actualizadoAntes time.Time, limite int) ([]HistorialPublicacion, error)
+	SetMetricas(id primitive.ObjectID, metricas MetricasPublicacion) error
+	// VariantTotals suma publicaciones e interacción de la publicación por grupo y variante
+	VariantTotals(publicacionID primitive.ObjectID) ([]RendimientoVariante, error)
+	// CountByEstadoSince cuenta las publicaciones desde la fecha indicada por estado
+	CountByEstadoSince(desde time.Time) (map[string]int, error)
+}
+
+// EventoRepository es la bandeja de salida de eventos de productos: la API
+// los guarda al actualizar un producto y el scheduler los procesa
+type EventoRepository interface {
+	Insert(eventos ...EventoProducto) error
+	// Pending devuelve los eventos sin procesar, del más antiguo al más reciente
+	Pending(limite int) ([]EventoProducto, error)
+	// MarkProcessed registra el evento como procesado con las programaciones que disparó
+	MarkProcessed(id primitive.ObjectID, programaciones []primitive.ObjectID, now time.Time) error
+}
+
+// Ledger es el registro de ejecuciones por horario (ver RunLedger)
+type Ledger interface {
+	Start(prog ProgramacionPublicacion, horario time.Time, grupos []GrupoFacebook, manual bool) (bool, error)
+	Skip(prog ProgramacionPublicacion, horario time.Time, motivo string) (bool, error)
+	RecordGroupResult(trabajo TrabajoPublicacion, postID string, postErr error) error
+	Last(programacionID primitive.ObjectID) (*Ejecucion, error)
+	Get(programacionID primitive.ObjectID, horario time.Time) (*Ejecucion, error)
+	RecentPublicaciones(programacionID primitive.ObjectID, limite int) ([]primitive.ObjectID, error)
+	CountPublished(programacionID primitive.ObjectID) (int, error)
+	CountInProgress(programacionID primitive.ObjectID) (int, error)
+	CountSuccessful(ids []primitive.ObjectID) (map[primitive.ObjectID]int, error)
+}
+
+// Queue es la cola de trabajos de publicación (ver JobQueue)
+type Queue interface {
+	Enqueue(trabajos []TrabajoPublicacion) (int, error)
+	Claim(instancia string) (*TrabajoPublicacion, error)
+	Ack(trabajo TrabajoPublicacion, estado, mensajeError string) error
+	Defer(trabajo TrabajoPublicacion, disponibleDesde time.Time, motivo string) error
+	Retry(trabajo TrabajoPublicacion, disponibleDesde time.Time, mensajeError string) error
+	// Extend renueva el lease de un trabajo que la instancia sigue publicando
+	Extend(trabajo TrabajoPublicacion) error
+	// CountByEstado cuenta los trabajos en los estados indicados
+	CountByEstado(estados ...string) (map[string]int, error)
+}
+
+// LeaderElector elige la instancia líder del scheduler (ver LeaderLock)
+type LeaderElector interface {
+	Acquire() (bool, error)
+	Release() error
+	Instancia() string
+}
+
+// UsuarioRepository obtiene los usuarios propietarios de las programaciones
+type UsuarioRepository interface {
+	GetUserByID(userID string) (*Usuario, error)
+	// ExpireFacebookToken marca vencido el token de Facebook del usuario
+	// cuando la Graph API lo rechaza, para no seguir usándolo
+	ExpireFacebookToken(userID string, now time.Time) error
+	// FindExpiringFacebookTokens devuelve los usuarios con Facebook conectado
+	// cuyo token vence antes de hasta, incluidos los ya vencidos
+	FindExpiringFacebookTokens(hasta time.Time) ([]Usuario, error)
+	UpdateFacebookTokens(userID, facebookUserID, accessToken string, expiration time.Time) error
+}
+
+// NotificacionRepository guarda los avisos para los usuarios
+type NotificacionRepository interface {
+	// Notify guarda la notificación si el usuario no tiene otra con la misma
+	// clave; indica si se creó
+	Notify(notificacion Notificacion) (bool, error)
+}
+
+// FacebookClient es la parte de la Graph API que usa el scheduler
+type FacebookClient interface {
+	PostToGroup(accessToken, groupID string, postReq FacebookPostRequest) (*FacebookPostResponse, error)
+	PostPhotoFromURL(accessToken, groupID string, postReq FacebookPostRequest) (*FacebookPostResponse, error)
+	PostAlbum(accessToken, groupID, message string, fotos []FotoPublicacion) (*FacebookPostResponse, error)
+	GetPostEngagement(accessToken, postID string) (*FacebookPostEngagement, error)
+	RefreshLongLivedToken(accessToken string) (*FacebookLongLivedToken, error)
+	UsageWait(accessToken string, now time.Time) (time.Duration, string)
+}
+
+// mongoProgramaciones implementa ProgramacionRepository sobre MongoDB
+type mongoProgramaciones struct {
+	collection *mongo.Collection
+}
+
+func newMongoProgramaciones() *mongoProgramaciones {
+	return &mongoProgramaciones{collection: database.Collection("programaciones")}
+}
+
+func (r *mongoProgramaciones) Get(id primitive.ObjectID) (*ProgramacionPublicacion, error) {
+	var programacion ProgramacionPublicacion
+	if err := r.collection.FindOne(context.Background(), bson.M{"_id": id}).Decode(&programacion); err != nil {
+		return nil, err
+	}
+	return &programacion, nil
+}
+
+func (r *mongoProgramaciones) FindActive(now time.Time) ([]ProgramacionPublicacion, error) {
+	// Las programaciones con fecha de fin vencida se incluyen para poder completarlas
+	filter := bson.M{
+		"estado":       "activa",
+		"fecha_inicio": bson.M{"$lte": now},
+	}
+
+	cursor, err := r.collection.Find(context.Background(), filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	var programaciones []ProgramacionPublicacion
+	if err := cursor.All(context.Background(), &programaciones); err != nil {
+		return nil, err
+	}
+	return programaciones, nil
+}
+
+func (r *mongoProgramaciones) Complete(id primitive.ObjectID, now time.Time) (bool, error) {
+	filter := bson.M{"_id": id, "estado": "activa"}
+	update := bson.M{"$set": bson.M{"estado": "completada", "updated_at": now}}
+
+	result, err := r.collection.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+func (r *mongoProgramaciones) ChangeEstado(id primitive.ObjectID, desde, hasta string, reanudadaEn *time.Time, now time.Time) (*ProgramacionPublicacion, error) {
+	cambios := bson.M{"estado": hasta, "updated_at": now}
+	if reanudadaEn != nil {
+		cambios["reanudada_en"] = *reanudadaEn
+	}
+	filter := bson.M{"_id": id, "estado": desde}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var prog ProgramacionPublicacion
+	err := r.collection.FindOneAndUpdate(context.Background(), filter, bson.M{"$set": cambios}, opts).Decode(&prog)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &prog, nil
+}
+
+// mongoPublicaciones implementa PublicacionRepository sobre MongoDB
+type mongoPublicaciones struct {
+	collection *mongo.Collection
+}
+
+func newMongoPublicaciones() *mongoPublicaciones {
+	return &mongoPublicaciones{collection: database.Collection("publicaciones")}
+}
+
+func (r *mongoPublicaciones) Get(id primitive.ObjectID) (*Publicacion, error) {
+	var publicacion Publicacion
+	if err := r.collection.FindOne(context.Background(), bson.M{"_id": id}).Decode(&publicacion); err != nil {
+		return nil, err
+	}
+	return &publicacion, nil
+}
+
+func (r *mongoPublicaciones) SetVariantesGanadoras(id primitive.ObjectID, ganadoras map[string]primitive.ObjectID) error {
+	cambios := bson.M{}
+	for grupo, variante := range ganadoras {
+		cambios["variantes_ganadoras."+grupo] = variante
+	}
+
+	_, err := r.collection.UpdateOne(context.Background(), bson.M{"_id": id}, bson.M{"$set": cambios})
+	return err
+}
+
+func (r *mongoPublicaciones) FindIDsByProducto(productoID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	opts := options.Find().SetProjection(bson.M{"_id": 1})
+	cursor, err := r.collection.Find(context.Background(), bson.M{"productos.producto_id": productoID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	var publicaciones []Publicacion
+	if err := cursor.All(context.Background(), &publicaciones); err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, len(publicaciones))
+	for i, publicacion := range publicaciones {
+		ids[i] = publicacion.ID
+	}
+	return ids, nil
+}
+
+// mongoProductos implementa ProductoRepository sobre MongoDB
+type mongoProductos struct {
+	collection *mongo.Collection
+}
+
+func newMongoProductos() *mongoProductos {
+	return &mongoProductos{collection: database.Collection("productos")}
+}
+
+func (r *mongoProductos) FindByIDs(ids []primitive.ObjectID) ([]Producto, error) {
+	cursor, err := r.collection.Find(context.Background(), bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	var productos []Producto
+	if err := cursor.All(context.Background(), &productos); err != nil {
+		return nil, err
+	}
+	return productos, nil
+}
+
+// mongoGrupos implementa GrupoRepository sobre MongoDB
+type mongoGrupos struct {
+	collection *mongo.Collection
+}
+
+func newMongoGrupos() *mongoGrupos {
+	return &mongoGrupos{collection: database.Collection("grupos")}
+}
+
+func (r *mongoGrupos) FindByIDs(ids []primitive.ObjectID) ([]GrupoFacebook, error) {
+	cursor, err := r.collection.Find(context.Background(), bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	var grupos []GrupoFacebook
+	if err := cursor.All(context.Background(), &grupos); err != nil {
+		return nil, err
+	}
+	return grupos, nil
+}
+
+// conservarReservas es cuánto se guardan las reservas de un grupo; cubre el
+// día de cualquier zona horaria
+const conservarReservas = 48 * time.Hour
+
+// Reserve es atómico, de modo que dos workers no publican a la vez en el
+// mismo grupo ni superan juntos su máximo diario
+func (r *mongoGrupos) Reserve(grupoID, trabajoID primitive.ObjectID, now time.Time, limite limiteGrupo) (bool, *GrupoFacebook, error) {
+	reservadoPorTrabajo := bson.M{"ultimo_trabajo_id": trabajoID}
+	condiciones := []bson.M{{"$or": []bson.M{
+		{"ultima_publicacion": nil},
+		{"ultima_publicacion": bson.M{"$lte": now.Add(-limite.intervalo)}},
+		reservadoPorTrabajo,
+	}}}
+	if limite.maximo > 0 {
+		reservasDelDia := bson.M{"$size": bson.M{"$filter": bson.M{
+			"input": bson.M{"$ifNull": bson.A{"$reservas", bson.A{}}},
+			"cond":  bson.M{"$gte": bson.A{"$$this.fecha", limite.desde}},
+		}}}
+		condiciones = append(condiciones, bson.M{"$or": []bson.M{
+			{"reservas.trabajo_id": trabajoID},
+			{"$expr": bson.M{"$lt": bson.A{reservasDelDia, limite.maximo}}},
+		}})
+	}
+	filter := bson.M{"_id": grupoID, "$and": condiciones}
+
+	// Se descartan la reserva anterior del trabajo y las vencidas
+	reservas := bson.M{"$concatArrays": bson.A{
+		bson.M{"$filter": bson.M{
+			"input": bson.M{"$ifNull": bson.A{"$reservas", bson.A{}}},
+			"cond": bson.M{"$and": bson.A{
+				bson.M{"$ne": bson.A{"$$this.trabajo_id", trabajoID}},
+				bson.M{"$gte": bson.A{"$$this.fecha", now.Add(-conservarReservas)}},
+			}},
+		}},
+		bson.A{ReservaGrupo{TrabajoID: trabajoID, Fecha: now}},
+	}}
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"ultima_publicacion": now,
+		"ultimo_trabajo_id":  trabajoID,
+		"reservas":           reservas,
+	}}}}
+
+	err := r.collection.FindOneAndUpdate(context.Background(), filter, update).Err()
+	if err == nil {
+		return true, nil, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return false, nil, err
+	}
+
+	var actual GrupoFacebook
+	if err := r.collection.FindOne(context.Background(), bson.M{"_id": grupoID}).Decode(&actual); err != nil {
+		return false, nil, err
+	}
+	return false, &actual, nil
+}
+
+// Release quita la reserva del trabajo y, si fue la última publicación del
+// grupo, también su fecha: la publicación anterior ya había cumplido el
+// intervalo cuando el trabajo reservó el grupo
+func (r *mongoGrupos) Release(grupoID, trabajoID primitive.ObjectID) error {
+	esDelTrabajo := bson.M{"$eq": bson.A{"$ultimo_trabajo_id", trabajoID}}
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"reservas": bson.M{"$filter": bson.M{
+			"input": bson.M{"$ifNull": bson.A{"$reservas", bson.A{}}},
+			"cond":  bson.M{"$ne": bson.A{"$$this.trabajo_id", trabajoID}},
+		}},
+		"ultima_publicacion": bson.M{"$cond": bson.A{esDelTrabajo, "$$REMOVE", "$ultima_publicacion"}},
+		"ultimo_trabajo_id":  bson.M{"$cond": bson.A{esDelTrabajo, "$$REMOVE", "$ultimo_trabajo_id"}},
+	}}}}
+
+	_, err := r.collection.UpdateOne(context.Background(), bson.M{"_id": grupoID}, update)
+	return err
+}
+
+// mongoHistorial implementa HistorialRepository sobre MongoDB
+type mongoHistorial struct {
+	collection *mongo.Collection
+}
+
+func newMongoHistorial() *mongoHistorial {
+	return &mongoHistorial{collection: database.Collection("historial_publicaciones")}
+}
+
+func (r *mongoHistorial) Insert(registros ...HistorialPublicacion) error {
+	docs := make([]interface{}, len(registros))
+	for i, registro := range registros {
+		docs[i] = registro
+	}
+	if len(docs) == 0 {
+		return nil
+	}
+
+	_, err := r.collection.InsertMany(context.Background(), docs)
+	return err
+}
+
+func (r *mongoHistorial) Replace(registro HistorialPublicacion) error {
+	_, err := r.collection.ReplaceOne(context.Background(), bson.M{"_id": registro.ID}, registro)
+	return err
+}
+
+func (r *mongoHistorial) LastScheduled(programacionID primitive.ObjectID) (*HistorialPublicacion, error) {
+	filter := bson.M{"programacion_id": programacionID, "manual": bson.M{"$ne": true}}
+	opts := options.FindOne().SetSort(bson.D{
+		{Key: "fecha_programada", Value: -1},
+		{Key: "fecha_publicacion", Value: -1},
+	})
+
+	var historial HistorialPublicacion
+	err := r.collection.FindOne(context.Background(), filter, opts).Decode(&historial)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil // No hay publicaciones previas
+		}
+		return nil, err
+	}
+	return &historial, nil
+}
+
+func (r *mongoHistorial) CountSuccessfulByUser(userID primitive.ObjectID, desde time.Time) (int, error) {
+	return r.count(bson.M{"user_id": userID, "estado": "exitosa", "fecha_publicacion": bson.M{"$gte": desde}})
+}
+
+func (r *mongoHistorial) CountVariantes(publicacionID, grupoID primitive.ObjectID) (int, error) {
+	return r.count(bson.M{"publicacion_id": publicacionID, "grupo_id": grupoID, "variante_id": bson.M{"$exists": true}})
+}
+
+func (r *mongoHistorial) count(filter bson.M) (int, error) {
+	total, err := r.collection.CountDocuments(context.Background(), filter)
+	return int(total), err
+}
+
+func (r *mongoHistorial) PendingEngagement(desde, actualizadoAntes time.Time, limite int) ([]HistorialPublicacion, error) {
+	filter := bson.M{
+		"estado":            "exitosa",
+		"facebook_post_id":  bson.M{"$ne": ""},
+		"variante_id":       bson.M{"$exists": true},
+		"fecha_publicacion": bson.M{"$gte": desde},
+		"$or": []bson.M{
+			{"metricas": nil},
+			{"metricas.actualizado_en": bson.M{"$lt": actualizadoAntes}},
+		},
+	}
+	opts := options.Find().SetLimit(int64(limite))
+
+	cursor, err := r.collection.Find(context.Background(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	var registros []HistorialPublicacion
+	if err := cursor.All(context.Background(), &registros); err != nil {
+		return nil, err
+	}
+	return registros, nil
+}
+
+func (r *mongoHistorial) SetMetricas(id primitive.ObjectID, metricas MetricasPublicacion) error {
+	_, err := r.collection.UpdateOne(context.Background(), bson.M{"_id": id}, bson.M{"$set": bson.M{"metricas": metricas}})
+	return err
+}
+
+func (r *mongoHistorial) VariantTotals(publicacionID primitive.ObjectID) ([]RendimientoVariante, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"publicacion_id": publicacionID,
+			"estado":         "exitosa",
+			"variante_id":    bson.M{"$exists": true},
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":           bson.M{"grupo_id": "$grupo_id", "variante_id": "$variante_id"},
+			"publicaciones": bson.M{"$sum": 1},
+			"reacciones":    bson.M{"$sum": "$metricas.reacciones"},
+			"comentarios":   bson.M{"$sum": "$metricas.comentarios"},
+			"compartidos":   bson.M{"$sum": "$metricas.compartidos"},
+		}}},
+		{{Key: "$replaceRoot", Value: bson.M{"newRoot": bson.M{"$mergeObjects": bson.A{"$_id", "$$ROOT"}}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "grupo_id", Value: 1}, {Key: "variante_id", Value: 1}}}},
+	}
+
+	cursor, err := r.collection.Aggregate(context.Background(), pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	rendimientos := []RendimientoVariante{}
+	if err := cursor.All(context.Background(), &rendimientos); err != nil {
+		return nil, err
+	}
+	return rendimientos, nil
+}
+
+func (r *mongoHistorial) CountByEstadoSince(desde time.Time) (map[string]int, error) {
+	return contarPorEstado(r.collection, bson.M{"fecha_publicacion": bson.M{"$gte": desde}})
+}
+
+// contarPorEstado agrupa por estado los documentos de la colección que cumplen el filtro
+func contarPorEstado(collection *mongo.Collection, filter bson.M) (map[string]int, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$group", Value: bson.M{"_id": "$estado", "total": bson.M{"$sum": 1}}}},
+	}
+
+	cursor, err := collection.Aggregate(context.Background(), pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	var resultados []struct {
+		Estado string `bson:"_id"`
+		Total  int    `bson:"total"`
+	}
+	if err := cursor.All(context.Background(), &resultados); err != nil {
+		return nil, err
+	}
+
+	conteos := make(map[string]int, len(resultados))
+	for _, r := range resultados {
+		conteos[r.Estado] = r.Total
+	}
+	return conteos, nil
+}
+
+// mongoEventos implementa EventoRepository sobre MongoDB
+type mongoEventos struct {
+	collection *mongo.Collection
+}
+
+func newMongoEventos() *mongoEventos {
+	r := &mongoEventos{collection: database.Collection("eventos_productos")}
+
+	index := mongo.IndexModel{Keys: bson.D{{Key: "estado", Value: 1}, {Key: "created_at", Value: 1}}}
+	if _, err := r.collection.Indexes().CreateOne(context.Background(), index); err != nil {
+		log.Printf("Error creando índices de eventos de productos: %v", err)
+	}
+	return r
+}
+
+func (r *mongoEventos) Insert(eventos ...EventoProducto) error {
+	if len(eventos) == 0 {
+		return nil
+	}
+
+	docs := make([]interface{}, len(eventos))
+	for i := range eventos {
+		docs[i] = eventos[i]
+	}
+	_, err := r.collection.InsertMany(context.Background(), docs)
+	return err
+}
+
+func (r *mongoEventos) Pending(limite int) ([]EventoProducto, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}).SetLimit(int64(limite))
+	cursor, err := r.collection.Find(context.Background(), bson.M{"estado": EventoPendiente}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	var eventos []EventoProducto
+	if err := cursor.All(context.Background(), &eventos); err != nil {
+		return nil, err
+	}
+	return eventos, nil
+}
+
+func (r *mongoEventos) MarkProcessed(id primitive.ObjectID, programaciones []primitive.ObjectID, now time.Time) error {
+	update := bson.M{"$set": bson.M{
+		"estado":         EventoProcesado,
+		"programaciones": programaciones,
+		"procesado_en":   now,
+	}}
+	_, err := r.collection.UpdateOne(context.Background(), bson.M{"_id": id}, update)
+	return err
+}
+
+// mongoNotificaciones implementa NotificacionRepository sobre MongoDB
+type mongoNotificaciones struct {
+	collection *mongo.Collection
+}
+
+func newMongoNotificaciones() *mongoNotificaciones {
+	r := &mongoNotificaciones{collection: database.Collection("notificaciones")}
+
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "clave", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	}
+	if _, err := r.collection.Indexes().CreateMany(context.Background(), indexes); err != nil {
+		log.Printf("Error creando índices de notificaciones: %v", err)
+	}
+	return r
+}
+
+func (r *mongoNotificaciones) Notify(notificacion Notificacion) (bool, error) {
+	filtro := bson.M{"user_id": notificacion.UserID, "clave": notificacion.Clave}
+	update := bson.M{"$setOnInsert": bson.M{
+		"tipo":       notificacion.Tipo,
+		"mensaje":    notificacion.Mensaje,
+		"leida":      false,
+		"created_at": notificacion.CreatedAt,
+	}}
+
+	result, err := r.collection.UpdateOne(context.Background(), filtro, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return false, err
+	}
+	return result.UpsertedCount > 0, nil
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// maxIteracionesBloqueo limita la búsqueda del siguiente instante permitido
+// cuando las restricciones se encadenan (fechas bloqueadas seguidas de
+// horarios de silencio)
+const maxIteracionesBloqueo = 1000
+
+// validarRestricciones verifica los horarios de silencio y las fechas bloqueadas
+func validarRestricciones(r RestriccionesPublicacion) error {
+	for _, silencio := range r.HorasSilencio {
+		if err := validarHorarios([]ConfiguracionHorario{silencio.Desde, silencio.Hasta}); err != nil {
+			return fmt.Errorf("horario de silencio inválido: %v", err)
+		}
+	}
+
+	for _, fecha := range r.FechasBloqueadas {
+		desde, hasta, err := rangoFechaBloqueada(fecha, time.UTC)
+		if err != nil {
+			return err
+		}
+		if hasta.Before(desde) {
+			return fmt.Errorf("la fecha bloqueada %s termina antes de empezar", fecha.Desde)
+		}
+	}
+
+	return nil
+}
+
+// rangoFechaBloqueada devuelve el primer y el último día del rango en loc
+func rangoFechaBloqueada(fecha FechaBloqueada, loc *time.Location) (time.Time, time.Time, error) {
+	desde, err := time.ParseInLocation("2006-01-02", fecha.Desde, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("fecha bloqueada inválida %q, use AAAA-MM-DD", fecha.Desde)
+	}
+	if fecha.Hasta == "" {
+		return desde, desde, nil
+	}
+
+	hasta, err := time.ParseInLocation("2006-01-02", fecha.Hasta, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("fecha bloqueada inválida %q, use AAAA-MM-DD", fecha.Hasta)
+	}
+	return desde, hasta, nil
+}
+
+// calendarioBloqueos combina las restricciones del usuario y de la
+// programación, evaluadas en la zona horaria de la programación
+type calendarioBloqueos struct {
+	loc      *time.Location
+	silencio []HorarioSilencio
+	fechas   []FechaBloqueada
+}
+
+func nuevoCalendarioBloqueos(loc *time.Location, restricciones ...RestriccionesPublicacion) *calendarioBloqueos {
+	c := &calendarioBloqueos{loc: loc}
+	for _, r := range restricciones {
+		c.silencio = append(c.silencio, r.HorasSilencio...)
+		c.fechas = append(c.fechas, r.FechasBloqueadas...)
+	}
+	return c
+}
+
+// vacio indica si no hay restricciones configuradas
+func (c *calendarioBloqueos) vacio() bool {
+	return len(c.silencio) == 0 && len(c.fechas) == 0
+}
+
+// siguientePermitido devuelve el primer instante desde t en el que se puede
+// publicar y el motivo del primer bloqueo encontrado (vacío si t está
+// permitido). Devuelve la fecha cero si no encuentra un instante permitido.
+func (c *calendarioBloqueos) siguientePermitido(t time.Time) (time.Time, string) {
+	if c.vacio() {
+		return t, ""
+	}
+
+	motivo := ""
+	for i := 0; i < maxIteracionesBloqueo; i++ {
+		fin, m := c.bloqueo(t)
+		if m == "" {
+			return t, motivo
+		}
+		if motivo == "" {
+			motivo = m
+		}
+		t = fin
+	}
+
+	return time.Time{}, motivo
+}
+
+// bloqueo devuelve el fin del bloqueo que contiene a t y su motivo, o un
+// motivo vacío si t no está bloqueado
+func (c *calendarioBloqueos) bloqueo(t time.Time) (time.Time, string) {
+	local := t.In(c.loc)
+	dia := inicioDelDia(local, c.loc)
+
+	for _, fecha := range c.fechas {
+		desde, hasta, err := rangoFechaBloqueada(fecha, c.loc)
+		if err != nil {
+			continue
+		}
+		if fecha.Anual {
+			// Llevar el rango al año de t; los rangos que cruzan el año se evalúan también desde el anterior
+			for _, anio := range []int{local.Year(), local.Year() - 1} {
+				d := desde.AddDate(anio-desde.Year(), 0, 0)
+				h := hasta.AddDate(anio-desde.Year(), 0, 0)
+				if !dia.Before(d) && !dia.After(h) {
+					return h.AddDate(0, 0, 1), motivoFecha(fecha)
+				}
+			}
+			continue
+		}
+		if !dia.Before(desde) && !dia.After(hasta) {
+			return hasta.AddDate(0, 0, 1), motivoFecha(fecha)
+		}
+	}
+
+	minutos := local.Hour()*60 + local.Minute()
+	for _, s := range c.silencio {
+		desde := s.Desde.Hora*60 + s.Desde.Minuto
+		hasta := s.Hasta.Hora*60 + s.Hasta.Minuto
+		motivo := fmt.Sprintf("horario de silencio %02d:%02d-%02d:%02d", s.Desde.Hora, s.Desde.Minuto, s.Hasta.Hora, s.Hasta.Minuto)
+
+		switch {
+		case desde < hasta && minutos >= desde && minutos < hasta:
+			return fechaHoraLocal(local.Year(), local.Month(), local.Day(), s.Hasta.Hora, s.Hasta.Minuto, c.loc), motivo
+		case desde > hasta && minutos >= desde:
+			return fechaHoraLocal(local.Year(), local.Month(), local.Day()+1, s.Hasta.Hora, s.Hasta.Minuto, c.loc), motivo
+		case desde > hasta && minutos < hasta:
+			return fechaHoraLocal(local.Year(), local.Month(), local.Day(), s.Hasta.Hora, s.Hasta.Minuto, c.loc), motivo
+		}
+	}
+
+	return time.Time{}, ""
+}
+
+// motivoFecha describe una fecha bloqueada para el historial
+func motivoFecha(fecha FechaBloqueada) string {
+	if fecha.Descripcion != "" {
+		return "fecha bloqueada: " + fecha.Descripcion
+	}
+	return "fecha bloqueada " + fecha.Desde
+}
+
+// resolver aplica las restricciones a un horario que se publicaría en
+// disponible. Si está bloqueado lo difiere al primer instante permitido; si
+// ese instante alcanza al siguiente horario de la programación (o no existe),
+// el horario se omite y la publicación queda para el siguiente.
+func (c *calendarioBloqueos) resolver(disponible, siguiente time.Time) (time.Time, string, bool) {
+	permitido, motivo := c.siguientePermitido(disponible)
+	if motivo == "" {
+		return disponible, "", false
+	}
+
+	if permitido.IsZero() || (!siguiente.IsZero() && !permitido.Before(siguiente)) {
+		return time.Time{}, motivo, true
+	}
+
+	return permitido, motivo, false
+}
+
+// parsearICS extrae los eventos de un calendario iCalendar (.ics) como fechas
+// bloqueadas. Los eventos con RRULE:FREQ=YEARLY se importan como anuales; las
+// demás reglas de repetición se ignoran y se toma solo la primera ocurrencia.
+func parsearICS(r io.Reader) ([]FechaBloqueada, error) {
+	lineas, err := desplegarLineasICS(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var fechas []FechaBloqueada
+	var evento *FechaBloqueada
+	var fin time.Time
+	var finEsFecha bool
+
+	for _, linea := range lineas {
+		nombre, params, valor := separarPropiedadICS(linea)
+
+		switch nombre {
+		case "BEGIN":
+			if valor == "VEVENT" {
+				evento = &FechaBloqueada{}
+				fin = time.Time{}
+			}
+		case "END":
+			if valor != "VEVENT" || evento == nil {
+				continue
+			}
+			if evento.Desde == "" {
+				return nil, errors.New("evento sin DTSTART en el calendario")
+			}
+			if !fin.IsZero() {
+				// DTEND de un evento de día completo es exclusivo
+				if finEsFecha {
+					fin = fin.AddDate(0, 0, -1)
+				}
+				if hasta := fin.Format("2006-01-02"); hasta > evento.Desde {
+					evento.Hasta = hasta
+				}
+			}
+			fechas = append(fechas, *evento)
+			evento = nil
+		case "DTSTART":
+			if evento == nil {
+				continue
+			}
+			inicio, _, err := parsearFechaICS(params, valor)
+			if err != nil {
+				return nil, err
+			}
+			evento.Desde = inicio.Format("2006-01-02")
+		case "DTEND":
+			if evento == nil {
+				continue
+			}
+			if fin, finEsFecha, err = parsearFechaICS(params, valor); err != nil {
+				return nil, err
+			}
+		case "SUMMARY":
+			if evento != nil {
+				evento.Descripcion = desescaparTextoICS(valor)
+			}
+		case "RRULE":
+			if evento != nil && strings.Contains(strings.ToUpper(valor), "FREQ=YEARLY") {
+				evento.Anual = true
+			}
+		}
+	}
+
+	if len(fechas) == 0 {
+		return nil, errors.New("el calendario no contiene eventos")
+	}
+
+	return fechas, nil
+}
+
+// desplegarLineasICS une las líneas plegadas (las que empiezan con espacio o tab)
+func desplegarLineasICS(r io.Reader) ([]string, error) {
+	var lineas []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		linea := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(linea, " ") || strings.HasPrefix(linea, "\t")) && len(lineas) > 0 {
+			lineas[len(lineas)-1] += linea[1:]
+			continue
+		}
+		if linea != "" {
+			lineas = append(lineas, linea)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return lineas, nil
+}
+
+// separarPropiedadICS divide "DTSTART;VALUE=DATE:20250101" en nombre, parámetros y valor
+func separarPropiedadICS(linea string) (string, string, string) {
+	clave, valor, _ := strings.Cut(linea, ":")
+	nombre, params, _ := strings.Cut(clave, ";")
+	return strings.ToUpper(nombre), strings.ToUpper(params), strings.TrimSpace(valor)
+}
+
+// parsearFechaICS interpreta un valor DATE (20250101) o DATE-TIME
+// (20250101T090000[Z]). Devuelve true si el valor es una fecha sin hora.
+func parsearFechaICS(params, valor string) (time.Time, bool, error) {
+	if strings.Contains(params, "VALUE=DATE") && !strings.Contains(params, "VALUE=DATE-TIME") || len(valor) == 8 {
+		t, err := time.Parse("20060102", valor)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("fecha inválida en el calendario: %q", valor)
+		}
+		return t, true, nil
+	}
+
+	t, err := time.Parse("20060102T150405", strings.TrimSuffix(valor, "Z"))
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("fecha inválida en el calendario: %q", valor)
+	}
+	return t, false, nil
+}
+
+// desescaparTextoICS quita los escapes de los valores de texto de iCalendar
+func desescaparTextoICS(valor string) string {
+	return strings.NewReplacer(`\n`, " ", `\N`, " ", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(valor)
+}
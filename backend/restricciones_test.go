@@ -0,0 +1,100 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// calendarioICS arma un calendario con los eventos indicados; las líneas van
+// separadas por CRLF como en los archivos .ics
+func calendarioICS(eventos ...string) string {
+	lineas := []string{"BEGIN:VCALENDAR", "VERSION:2.0", "PRODID:-//Prueba//ES"}
+	for _, evento := range eventos {
+		lineas = append(lineas, "BEGIN:VEVENT", evento, "END:VEVENT")
+	}
+	lineas = append(lineas, "END:VCALENDAR")
+	return strings.Join(lineas, "\r\n") + "\r\n"
+}
+
+func TestParsearICS(t *testing.T) {
+	casos := []struct {
+		nombre    string
+		ics       string
+		esperadas []FechaBloqueada
+		error     bool
+	}{
+		{
+			nombre: "día completo con DTEND exclusivo",
+			ics:    calendarioICS("DTSTART;VALUE=DATE:20250101\r\nDTEND;VALUE=DATE:20250102\r\nSUMMARY:Año nuevo"),
+			esperadas: []FechaBloqueada{
+				{Desde: "2025-01-01", Descripcion: "Año nuevo"},
+			},
+		},
+		{
+			nombre: "varios días completos",
+			ics:    calendarioICS("DTSTART;VALUE=DATE:20251224\r\nDTEND;VALUE=DATE:20251227\r\nSUMMARY:Navidad"),
+			esperadas: []FechaBloqueada{
+				{Desde: "2025-12-24", Hasta: "2025-12-26", Descripcion: "Navidad"},
+			},
+		},
+		{
+			nombre: "líneas plegadas",
+			// Al desplegar se quita solo el primer espacio o tab de la continuación
+			ics: calendarioICS("DTSTART;VALUE=DATE:2025\r\n 0501\r\nSUMMARY:Día de los trab\r\n ajadores\\, feriado\r\n\t nacional\r\nRRULE:FREQ=YE\r\n ARLY"),
+			esperadas: []FechaBloqueada{
+				{Desde: "2025-05-01", Descripcion: "Día de los trabajadores, feriado nacional", Anual: true},
+			},
+		},
+		{
+			nombre: "TZID conserva la fecha de la zona del evento",
+			ics:    calendarioICS("DTSTART;TZID=America/Havana:20250310T220000\r\nDTEND;TZID=America/Havana:20250312T080000\r\nSUMMARY:Viaje"),
+			esperadas: []FechaBloqueada{
+				{Desde: "2025-03-10", Hasta: "2025-03-12", Descripcion: "Viaje"},
+			},
+		},
+		{
+			nombre: "DTEND con hora el mismo día",
+			ics:    calendarioICS("DTSTART:20250310T090000Z\r\nDTEND:20250310T170000Z\r\nSUMMARY:Reunión"),
+			esperadas: []FechaBloqueada{
+				{Desde: "2025-03-10", Descripcion: "Reunión"},
+			},
+		},
+		{
+			nombre: "solo la primera ocurrencia de las reglas no anuales",
+			ics: calendarioICS(
+				"DTSTART;VALUE=DATE:20250106\r\nRRULE:FREQ=WEEKLY;COUNT=4\r\nSUMMARY:Semanal",
+				"DTSTART;VALUE=DATE:20250707\r\nSUMMARY:Vacaciones",
+			),
+			esperadas: []FechaBloqueada{
+				{Desde: "2025-01-06", Descripcion: "Semanal"},
+				{Desde: "2025-07-07", Descripcion: "Vacaciones"},
+			},
+		},
+		{nombre: "evento sin DTSTART", ics: calendarioICS("SUMMARY:Sin fecha"), error: true},
+		{nombre: "fecha mal formada", ics: calendarioICS("DTSTART;VALUE=DATE:2025-01-01"), error: true},
+		{nombre: "hora mal formada", ics: calendarioICS("DTSTART:20250101T9"), error: true},
+		{nombre: "DTEND mal formado", ics: calendarioICS("DTSTART;VALUE=DATE:20250101\r\nDTEND;VALUE=DATE:enero"), error: true},
+		{nombre: "calendario sin eventos", ics: calendarioICS(), error: true},
+		{nombre: "no es un calendario", ics: "esto no es un calendario\n", error: true},
+		{nombre: "vacío", ics: "", error: true},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			fechas, err := parsearICS(strings.NewReader(caso.ics))
+			if caso.error {
+				if err == nil {
+					t.Fatalf("no devolvió error; fechas %+v", fechas)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parsearICS: %v", err)
+			}
+			if !reflect.DeepEqual(fechas, caso.esperadas) {
+				t.Errorf("fechas %+v, se esperaban %+v", fechas, caso.esperadas)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// errorPermanente marca un error que no se resuelve reintentando
+type errorPermanente struct {
+	err error
+}
+
+func (e *errorPermanente) Error() string {
+	return e.err.Error()
+}
+
+func (e *errorPermanente) Unwrap() error {
+	return e.err
+}
+
+// permanente envuelve err para que la publicación falle sin reintentos
+func permanente(err error) error {
+	return &errorPermanente{err: err}
+}
+
+// esErrorTransitorio indica si un error al publicar puede resolverse
+// reintentando: timeouts y errores de red, y los errores de Graph API
+// temporales (ver GraphError.IsTemporary). Los errores de permisos, de token
+// o de grupos inexistentes fallan sin reintentos.
+func esErrorTransitorio(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var permErr *errorPermanente
+	if errors.As(err, &permErr) {
+		return false
+	}
+
+	if graphErr := comoGraphError(err); graphErr != nil {
+		return graphErr.IsTemporary()
+	}
+
+	// Errores de red, timeouts y de base de datos
+	return true
+}
+
+// backoffConJitter calcula la espera antes del reintento número intento
+// (desde 1): crece exponencialmente desde base hasta max y se elige al azar
+// entre la mitad y el total para que los reintentos no se sincronicen
+func backoffConJitter(intento int, base, max time.Duration) time.Duration {
+	espera := base
+	for i := 1; i < intento && espera < max; i++ {
+		espera *= 2
+	}
+	if espera > max {
+		espera = max
+	}
+
+	mitad := espera / 2
+	return mitad + time.Duration(rand.Int63n(int64(mitad)+1))
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Modos de rotación entre las publicaciones de una programación
+const (
+	RotacionSecuencial = "secuencial" // Una tras otra en el orden de la lista (por defecto)
+	RotacionAleatoria  = "aleatoria"  // Al azar, sin repetir hasta usarlas todas
+	RotacionPonderada  = "ponderada"  // Al azar según el peso de cada una
+)
+
+// azarRotacion elige un entero en [0, n); las pruebas lo reemplazan por una
+// fuente con semilla fija
+var azarRotacion = rand.Intn
+
+// rotacionProgramacion devuelve el modo de rotación, aplicando el valor por defecto
+func rotacionProgramacion(prog ProgramacionPublicacion) string {
+	if prog.Rotacion == "" {
+		return RotacionSecuencial
+	}
+	return prog.Rotacion
+}
+
+// publicacionesDe devuelve las publicaciones que rota la programación; las
+// programaciones con una sola publicación usan PublicacionID
+func publicacionesDe(prog ProgramacionPublicacion) []PublicacionRotacion {
+	if len(prog.Publicaciones) > 0 {
+		return prog.Publicaciones
+	}
+	return []PublicacionRotacion{{PublicacionID: prog.PublicacionID, Peso: 1}}
+}
+
+// completarPublicacionPrincipal mantiene PublicacionID como la primera
+// publicación de la rotación para los clientes que solo leen ese campo
+func completarPublicacionPrincipal(prog *ProgramacionPublicacion) {
+	if prog.PublicacionID.IsZero() && len(prog.Publicaciones) > 0 {
+		prog.PublicacionID = prog.Publicaciones[0].PublicacionID
+	}
+}
+
+// validarRotacion verifica las publicaciones y el modo de rotación
+func validarRotacion(prog ProgramacionPublicacion) error {
+	switch prog.Rotacion {
+	case "", RotacionSecuencial, RotacionAleatoria, RotacionPonderada:
+	default:
+		return fmt.Errorf("rotación desconocida %q: use secuencial, aleatoria o ponderada", prog.Rotacion)
+	}
+
+	publicaciones := publicacionesDe(prog)
+	for _, p := range publicaciones {
+		if p.PublicacionID.IsZero() {
+			return errors.New("la programación debe indicar al menos una publicación")
+		}
+		if p.Peso < 0 {
+			return errors.New("el peso de una publicación no puede ser negativo")
+		}
+	}
+
+	return nil
+}
+
+// elegirPublicacion elige la publicación del próximo horario. recientes son
+// las publicaciones de las últimas ejecuciones, de la más reciente a la más
+// antigua, y realizadas la cantidad total de ejecuciones publicadas.
+func elegirPublicacion(prog ProgramacionPublicacion, recientes []primitive.ObjectID, realizadas int) primitive.ObjectID {
+	publicaciones := publicacionesDe(prog)
+	if len(publicaciones) == 1 {
+		return publicaciones[0].PublicacionID
+	}
+
+	switch rotacionProgramacion(prog) {
+	case RotacionAleatoria:
+		return elegirSinRepetir(publicaciones, recientes, realizadas)
+	case RotacionPonderada:
+		return elegirPonderada(publicaciones)
+	default:
+		return elegirSiguiente(publicaciones, recientes, realizadas)
+	}
+}
+
+// elegirSiguiente devuelve la publicación que sigue a la última usada
+func elegirSiguiente(publicaciones []PublicacionRotacion, recientes []primitive.ObjectID, realizadas int) primitive.ObjectID {
+	if len(recientes) > 0 {
+		for i, p := range publicaciones {
+			if p.PublicacionID == recientes[0] {
+				return publicaciones[(i+1)%len(publicaciones)].PublicacionID
+			}
+		}
+	}
+
+	// La última publicación ya no está en la lista: continuar por la posición
+	return publicaciones[realizadas%len(publicaciones)].PublicacionID
+}
+
+// elegirSinRepetir elige al azar entre las publicaciones que todavía no se
+// usaron en la vuelta actual; cada vuelta usa todas una vez
+func elegirSinRepetir(publicaciones []PublicacionRotacion, recientes []primitive.ObjectID, realizadas int) primitive.ObjectID {
+	posicion := realizadas % len(publicaciones)
+	if posicion > len(recientes) {
+		posicion = len(recientes)
+	}
+
+	usadas := make(map[primitive.ObjectID]bool, posicion)
+	for _, id := range recientes[:posicion] {
+		usadas[id] = true
+	}
+
+	var candidatas []primitive.ObjectID
+	for _, p := range publicaciones {
+		if !usadas[p.PublicacionID] {
+			candidatas = append(candidatas, p.PublicacionID)
+		}
+	}
+	if len(candidatas) == 0 {
+		return publicaciones[azarRotacion(len(publicaciones))].PublicacionID
+	}
+
+	return candidatas[azarRotacion(len(candidatas))]
+}
+
+// elegirPonderada elige al azar con probabilidad proporcional al peso; un
+// peso de 0 equivale a 1
+func elegirPonderada(publicaciones []PublicacionRotacion) primitive.ObjectID {
+	total := 0
+	for _, p := range publicaciones {
+		total += pesoRotacion(p)
+	}
+
+	n := azarRotacion(total)
+	for _, p := range publicaciones {
+		n -= pesoRotacion(p)
+		if n < 0 {
+			return p.PublicacionID
+		}
+	}
+
+	return publicaciones[len(publicaciones)-1].PublicacionID
+}
+
+func pesoRotacion(p PublicacionRotacion) int {
+	if p.Peso <= 0 {
+		return 1
+	}
+	return p.Peso
+}
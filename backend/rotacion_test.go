@@ -0,0 +1,135 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// sembrarRotacion usa una fuente con semilla fija para la rotación durante la prueba
+func sembrarRotacion(t *testing.T, semilla int64) {
+	anterior := azarRotacion
+	azarRotacion = rand.New(rand.NewSource(semilla)).Intn
+	t.Cleanup(func() { azarRotacion = anterior })
+}
+
+// rotar elige la publicación de n horarios seguidos como lo hace el scheduler
+func rotar(prog ProgramacionPublicacion, n int) []primitive.ObjectID {
+	var elegidas, recientes []primitive.ObjectID
+	for i := 0; i < n; i++ {
+		elegida := elegirPublicacion(prog, recientes, i)
+		elegidas = append(elegidas, elegida)
+		recientes = append([]primitive.ObjectID{elegida}, recientes...)
+	}
+	return elegidas
+}
+
+// rotacionDe arma una programación que rota las publicaciones con los pesos indicados
+func rotacionDe(rotacion string, pesos ...int) ProgramacionPublicacion {
+	prog := ProgramacionPublicacion{Rotacion: rotacion}
+	for _, peso := range pesos {
+		prog.Publicaciones = append(prog.Publicaciones, PublicacionRotacion{PublicacionID: primitive.NewObjectID(), Peso: peso})
+	}
+	return prog
+}
+
+func TestRotacionSecuencial(t *testing.T) {
+	prog := rotacionDe(RotacionSecuencial, 0, 0, 0)
+	a, b, c := prog.Publicaciones[0].PublicacionID, prog.Publicaciones[1].PublicacionID, prog.Publicaciones[2].PublicacionID
+
+	esperadas := []primitive.ObjectID{a, b, c, a, b, c, a}
+	for i, elegida := range rotar(prog, len(esperadas)) {
+		if elegida != esperadas[i] {
+			t.Errorf("horario %d: publicación %s, se esperaba %s", i+1, elegida.Hex(), esperadas[i].Hex())
+		}
+	}
+
+	// Si la última publicada se quitó de la lista se sigue por la posición
+	if elegida := elegirPublicacion(prog, []primitive.ObjectID{primitive.NewObjectID()}, 4); elegida != b {
+		t.Errorf("tras una publicación quitada se eligió %s, se esperaba %s", elegida.Hex(), b.Hex())
+	}
+}
+
+func TestRotacionAleatoriaNoRepiteEnLaVuelta(t *testing.T) {
+	sembrarRotacion(t, 1)
+	prog := rotacionDe(RotacionAleatoria, 0, 0, 0, 0)
+	vuelta := len(prog.Publicaciones)
+
+	elegidas := rotar(prog, 5*vuelta)
+	for inicio := 0; inicio < len(elegidas); inicio += vuelta {
+		usadas := make(map[primitive.ObjectID]bool)
+		for _, id := range elegidas[inicio : inicio+vuelta] {
+			if usadas[id] {
+				t.Fatalf("la vuelta %d repite la publicación %s", inicio/vuelta+1, id.Hex())
+			}
+			usadas[id] = true
+		}
+	}
+
+	// Con la misma semilla la rotación se repite
+	sembrarRotacion(t, 1)
+	for i, id := range rotar(prog, len(elegidas)) {
+		if id != elegidas[i] {
+			t.Fatalf("horario %d: con la misma semilla se eligió otra publicación", i+1)
+		}
+	}
+}
+
+func TestRotacionPonderada(t *testing.T) {
+	sembrarRotacion(t, 1)
+	// Un peso de 0 equivale a 1
+	prog := rotacionDe(RotacionPonderada, 6, 3, 0)
+
+	const n = 10000
+	conteos := make(map[primitive.ObjectID]int)
+	for _, id := range rotar(prog, n) {
+		conteos[id]++
+	}
+
+	for i, esperada := range []float64{0.6, 0.3, 0.1} {
+		proporcion := float64(conteos[prog.Publicaciones[i].PublicacionID]) / n
+		if proporcion < esperada-0.02 || proporcion > esperada+0.02 {
+			t.Errorf("publicación %d elegida en el %.3f de los horarios, se esperaba %.1f", i+1, proporcion, esperada)
+		}
+	}
+}
+
+func TestRotacionRegistraLaPublicacionElegida(t *testing.T) {
+	for _, rotacion := range []string{RotacionSecuencial, RotacionAleatoria, RotacionPonderada} {
+		t.Run(rotacion, func(t *testing.T) {
+			inicio := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+			sim := nuevaSimulacion(t, inicio, 1)
+			prog := rotacionDe(rotacion, 1, 2, 3)
+			for _, p := range prog.Publicaciones {
+				sim.publicaciones.datos[p.PublicacionID] = Publicacion{ID: p.PublicacionID, Titulo: "Oferta " + p.PublicacionID.Hex()}
+			}
+			prog.Frecuencia = "diaria"
+			prog.Horarios = []ConfiguracionHorario{{Hora: 9}}
+			prog.FechaInicio = inicio
+			completarPublicacionPrincipal(&prog)
+
+			const dias = 9
+			sembrarRotacion(t, 7)
+			esperadas := rotar(prog, dias)
+
+			sembrarRotacion(t, 7)
+			sim.programar(prog)
+			sim.avanzar(inicio.AddDate(0, 0, dias))
+
+			if len(sim.historial.registros) != dias {
+				t.Fatalf("%d registros en el historial, se esperaban %d", len(sim.historial.registros), dias)
+			}
+			for i, registro := range sim.historial.registros {
+				if registro.PublicacionID != esperadas[i] {
+					t.Errorf("día %d: historial con la publicación %s, se esperaba %s", i+1, registro.PublicacionID.Hex(), esperadas[i].Hex())
+				}
+				if mensaje := sim.facebook.publicaciones[i].mensaje; !strings.Contains(mensaje, esperadas[i].Hex()) {
+					t.Errorf("día %d: se publicó %q, se esperaba la publicación %s", i+1, mensaje, esperadas[i].Hex())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Errores de las acciones sobre programaciones
+var (
+	ErrProgramacionNoEncontrada = errors.New("programación no encontrada")
+	ErrEstadoProgramacion       = errors.New("la acción no está permitida en el estado de la programación")
+	ErrSinGruposObjetivo        = errors.New("la programación no tiene grupos objetivo")
+	ErrSinProximosHorarios      = errors.New("la programación no tiene próximos horarios")
+)
+
+// RunNow publica la programación de inmediato, fuera de su recurrencia. La
+// ejecución queda registrada como manual: no cambia el siguiente horario
+// planificado ni consume la cantidad de publicaciones.
+func (s *SchedulerService) RunNow(id primitive.ObjectID) (time.Time, error) {
+	prog, err := s.findProgramacion(id)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if len(prog.GruposObjetivo) == 0 {
+		return time.Time{}, ErrSinGruposObjetivo
+	}
+
+	now := s.clock.Now()
+	if err := s.enqueueRun(*prog, now, now, true); err != nil {
+		return time.Time{}, err
+	}
+
+	return now.In(s.locationFor(*prog)), nil
+}
+
+// Pause detiene la publicación de una programación activa
+func (s *SchedulerService) Pause(id primitive.ObjectID) (*ProgramacionPublicacion, error) {
+	return s.changeEstado(id, "activa", "pausada", nil)
+}
+
+// Resume reactiva una programación pausada. Los horarios que cayeron durante
+// la pausa no se recuperan: la programación sigue en el primero posterior.
+func (s *SchedulerService) Resume(id primitive.ObjectID) (*ProgramacionPublicacion, error) {
+	now := s.clock.Now()
+	return s.changeEstado(id, "pausada", "activa", &now)
+}
+
+// SkipNext omite el siguiente horario planificado de la programación y
+// devuelve el horario omitido
+func (s *SchedulerService) SkipNext(id primitive.ObjectID) (time.Time, error) {
+	prog, err := s.findProgramacion(id)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if prog.Estado != "activa" && prog.Estado != "pausada" {
+		return time.Time{}, fmt.Errorf("%w: está %s", ErrEstadoProgramacion, prog.Estado)
+	}
+	if prog.Disparador != nil {
+		return time.Time{}, ErrProgramacionPorEventos
+	}
+
+	horario, err := s.nextPublicationTime(*prog)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if horario.IsZero() || (prog.FechaFin != nil && horario.After(*prog.FechaFin)) {
+		return time.Time{}, ErrSinProximosHorarios
+	}
+
+	registrado, err := s.skipSlot(*prog, horario, "omitido manualmente")
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !registrado {
+		return time.Time{}, fmt.Errorf("%w: el horario ya se está ejecutando", ErrEstadoProgramacion)
+	}
+
+	return horario.In(s.locationFor(*prog)), nil
+}
+
+// changeEstado pasa la programación al estado hasta solo si está en el
+// estado desde, de modo que dos acciones simultáneas no se pisen
+func (s *SchedulerService) changeEstado(id primitive.ObjectID, desde, hasta string, reanudadaEn *time.Time) (*ProgramacionPublicacion, error) {
+	prog, err := s.programaciones.ChangeEstado(id, desde, hasta, reanudadaEn, s.clock.Now())
+	if err != nil || prog != nil {
+		return prog, err
+	}
+
+	actual, err := s.findProgramacion(id)
+	if err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("%w: está %s", ErrEstadoProgramacion, actual.Estado)
+}
+
+// findProgramacion obtiene una programación traduciendo la ausencia a ErrProgramacionNoEncontrada
+func (s *SchedulerService) findProgramacion(id primitive.ObjectID) (*ProgramacionPublicacion, error) {
+	prog, err := s.getProgramacion(id)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrProgramacionNoEncontrada
+	}
+	return prog, err
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSchedulerSkipNext(t *testing.T) {
+	inicio := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+	sim := nuevaSimulacion(t, inicio, 1)
+	id := sim.programar(ProgramacionPublicacion{
+		Frecuencia:  "diaria",
+		Horarios:    []ConfiguracionHorario{{Hora: 9}},
+		FechaInicio: inicio,
+	})
+
+	// Omitir dos veces seguidas salta dos horarios
+	for _, esperado := range []string{"2025-03-03 09:00", "2025-03-04 09:00"} {
+		omitido, err := sim.scheduler.SkipNext(id)
+		if err != nil {
+			t.Fatalf("SkipNext: %v", err)
+		}
+		if fecha := omitido.Format("2006-01-02 15:04"); fecha != esperado {
+			t.Errorf("se omitió el horario %s, se esperaba %s", fecha, esperado)
+		}
+	}
+
+	sim.avanzar(inicio.AddDate(0, 0, 4))
+
+	compararFechas(t, sim.fechasPublicadas(sim.grupos[0], time.UTC), []string{"2025-03-05 09:00", "2025-03-06 09:00"})
+	compararFechas(t, sim.omitidasEn("omitido manualmente"), []string{"2025-03-03 09:00", "2025-03-04 09:00"})
+}
+
+func TestSchedulerRunNow(t *testing.T) {
+	inicio := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+	sim := nuevaSimulacion(t, inicio, 1)
+	id := sim.programar(ProgramacionPublicacion{
+		Frecuencia:            "diaria",
+		Horarios:              []ConfiguracionHorario{{Hora: 9}},
+		CantidadPublicaciones: 2,
+		FechaInicio:           inicio,
+	})
+
+	sim.avanzar(inicio.Add(6 * time.Hour))
+	if _, err := sim.scheduler.RunNow(id); err != nil {
+		t.Fatalf("RunNow: %v", err)
+	}
+	sim.avanzar(inicio.AddDate(0, 0, 4))
+
+	// La manual no reemplaza el horario de las 09:00 ni cuenta en la cantidad
+	compararFechas(t, sim.fechasPublicadas(sim.grupos[0], time.UTC), []string{
+		"2025-03-03 06:00", "2025-03-03 09:00", "2025-03-04 09:00",
+	})
+	if conteos, _ := sim.ledger.CountSuccessful([]primitive.ObjectID{id}); conteos[id] != 2 {
+		t.Errorf("ejecuciones exitosas %d, se esperaban 2", conteos[id])
+	}
+	if estado := sim.programaciones.datos[id].Estado; estado != "completada" {
+		t.Errorf("estado final %q, se esperaba completada", estado)
+	}
+}
+
+func TestSchedulerResume(t *testing.T) {
+	inicio := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+	sim := nuevaSimulacion(t, inicio, 1)
+	// Ni con la política "todas" se recuperan los horarios de la pausa
+	id := sim.programar(ProgramacionPublicacion{
+		Frecuencia:           "diaria",
+		Horarios:             []ConfiguracionHorario{{Hora: 9}, {Hora: 18}},
+		FechaInicio:          inicio,
+		PoliticaRecuperacion: RecuperacionTodas,
+	})
+
+	if _, err := sim.scheduler.Resume(id); !errors.Is(err, ErrEstadoProgramacion) {
+		t.Errorf("Resume de una programación activa devolvió %v", err)
+	}
+
+	sim.avanzar(inicio.Add(12 * time.Hour))
+	if _, err := sim.scheduler.Pause(id); err != nil {
+		t.Fatalf("Pause: %v", err)
+	}
+	sim.avanzar(time.Date(2025, 3, 5, 12, 0, 0, 0, time.UTC))
+	if _, err := sim.scheduler.Resume(id); err != nil {
+		t.Fatalf("Resume: %v", err)
+	}
+	sim.avanzar(inicio.AddDate(0, 0, 4))
+
+	compararFechas(t, sim.fechasPublicadas(sim.grupos[0], time.UTC), []string{
+		"2025-03-03 09:00", "2025-03-05 18:00", "2025-03-06 09:00", "2025-03-06 18:00",
+	})
+	if omitidas := sim.omitidasEn(""); len(omitidas) != 0 {
+		t.Errorf("los horarios de la pausa quedaron como omitidos: %v", omitidas)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Políticas de recuperación de horarios perdidos
+const (
+	RecuperacionOmitir = "omitir" // Se omiten los horarios perdidos
+	RecuperacionUltima = "ultima" // Solo se ejecuta el último horario perdido
+	RecuperacionTodas  = "todas"  // Se ejecutan todos, espaciados entre sí
+)
+
+const (
+	// toleranciaAtraso es el atraso a partir del cual un horario se considera
+	// perdido y no simplemente detectado en el siguiente tick
+	toleranciaAtraso = 5 * time.Minute
+
+	// maxHorariosRecuperacion limita los horarios vencidos revisados por ciclo
+	maxHorariosRecuperacion = 500
+
+	// espaciadoRecuperacionPorDefecto son los minutos entre horarios
+	// recuperados con la política "todas"
+	espaciadoRecuperacionPorDefecto = 10
+)
+
+// politicaRecuperacion devuelve la política de la programación o la política por defecto
+func politicaRecuperacion(prog ProgramacionPublicacion) string {
+	if prog.PoliticaRecuperacion == "" {
+		return RecuperacionUltima
+	}
+	return prog.PoliticaRecuperacion
+}
+
+// validarPoliticaRecuperacion verifica la política de recuperación de una programación
+func validarPoliticaRecuperacion(prog ProgramacionPublicacion) error {
+	switch politicaRecuperacion(prog) {
+	case RecuperacionOmitir, RecuperacionUltima, RecuperacionTodas:
+	default:
+		return fmt.Errorf("política de recuperación desconocida %q: use omitir, ultima o todas", prog.PoliticaRecuperacion)
+	}
+
+	if prog.EspaciadoRecuperacion < 0 {
+		return fmt.Errorf("el espaciado de recuperación no puede ser negativo")
+	}
+
+	return nil
+}
+
+// catchUp procesa los horarios vencidos de una programación a partir de
+// horario. En funcionamiento normal hay uno solo y se encola de inmediato;
+// tras una caída o un reinicio del backend puede haber varios perdidos y se
+// aplica la política de recuperación de la programación. Los horarios que
+// caen en un horario de silencio o una fecha bloqueada se difieren al primer
+// instante permitido. Los horarios omitidos quedan registrados en las
+// ejecuciones y en el historial.
+// restantes es la cantidad de publicaciones que le quedan a la programación,
+// o -1 si no tiene límite.
+func (s *SchedulerService) catchUp(prog ProgramacionPublicacion, horario, now time.Time, restantes int) {
+	vencidos, err := s.dueSlots(prog, horario, now)
+	if err != nil {
+		log.Printf("Error calculando horarios vencidos de %s: %v", prog.ID.Hex(), err)
+		return
+	}
+
+	recurrencia, err := s.recurrenciaFor(prog)
+	if err != nil {
+		log.Printf("Error calculando recurrencia de %s: %v", prog.ID.Hex(), err)
+		return
+	}
+	calendario := s.calendarFor(prog)
+
+	politica := politicaRecuperacion(prog)
+	espaciado := prog.EspaciadoRecuperacion
+	if espaciado == 0 {
+		espaciado = espaciadoRecuperacionPorDefecto
+	}
+
+	ultimo := len(vencidos) - 1
+	encolados, omitidos := 0, 0
+	for i, h := range vencidos {
+		perdido := now.Sub(h) > toleranciaAtraso
+
+		var motivo string
+		switch {
+		case restantes >= 0 && encolados >= restantes:
+			motivo = "cantidad de publicaciones alcanzada durante la recuperación"
+		case politica == RecuperacionOmitir && perdido:
+			motivo = "horario perdido mientras el scheduler estaba detenido (política omitir)"
+		case politica == RecuperacionUltima && perdido && i < ultimo:
+			motivo = "horario perdido mientras el scheduler estaba detenido (política ultima)"
+		}
+
+		if motivo != "" {
+			if _, err := s.skipSlot(prog, h, motivo); err != nil {
+				log.Printf("Error registrando horario omitido de %s: %v", prog.ID.Hex(), err)
+			}
+			omitidos++
+			continue
+		}
+
+		disponibleDesde := now
+		if politica == RecuperacionTodas {
+			disponibleDesde = now.Add(time.Duration(encolados*espaciado) * time.Minute)
+		}
+
+		// Solo el último horario vencido compite con el siguiente de la recurrencia
+		var siguiente time.Time
+		if i == ultimo {
+			siguiente = recurrencia.Next(h)
+		}
+		permitido, bloqueo, omitir := calendario.resolver(disponibleDesde, siguiente)
+		if omitir {
+			if _, err := s.skipSlot(prog, h, bloqueo+" (se publica en el siguiente horario)"); err != nil {
+				log.Printf("Error registrando horario omitido de %s: %v", prog.ID.Hex(), err)
+			}
+			omitidos++
+			continue
+		}
+		if bloqueo != "" {
+			log.Printf("Programación %s: horario %s diferido hasta %s por %s", prog.ID.Hex(), h.Format(time.RFC3339), permitido.Format(time.RFC3339), bloqueo)
+		}
+
+		s.enqueuePublication(prog, h, permitido)
+		encolados++
+	}
+
+	if omitidos > 0 {
+		log.Printf("Programación %s: %d horarios perdidos omitidos, %d encolados (política %s)", prog.ID.Hex(), omitidos, encolados, politica)
+	}
+}
+
+// dueSlots devuelve los horarios vencidos desde horario hasta now, sin pasar
+// la fecha de fin de la programación
+func (s *SchedulerService) dueSlots(prog ProgramacionPublicacion, horario, now time.Time) ([]time.Time, error) {
+	recurrencia, err := s.recurrenciaFor(prog)
+	if err != nil {
+		return nil, err
+	}
+
+	vencidos := []time.Time{horario}
+	for len(vencidos) < maxHorariosRecuperacion {
+		siguiente := recurrencia.Next(vencidos[len(vencidos)-1])
+		if siguiente.IsZero() || siguiente.After(now) {
+			break
+		}
+		if prog.FechaFin != nil && siguiente.After(*prog.FechaFin) {
+			break
+		}
+		vencidos = append(vencidos, siguiente)
+	}
+
+	return vencidos, nil
+}
+
+// skipSlot registra un horario que no se ejecutará, en las ejecuciones y con
+// una entrada "omitida" por grupo en el historial. Devuelve false si el
+// horario ya estaba registrado.
+func (s *SchedulerService) skipSlot(prog ProgramacionPublicacion, horario time.Time, motivo string) (bool, error) {
+	registrado, err := s.ledger.Skip(prog, horario, motivo)
+	if err != nil || !registrado {
+		return registrado, err
+	}
+
+	now := s.clock.Now()
+	registros := make([]HistorialPublicacion, len(prog.GruposObjetivo))
+	for i, grupoID := range prog.GruposObjetivo {
+		registros[i] = HistorialPublicacion{
+			ID:               primitive.NewObjectID(),
+			ProgramacionID:   prog.ID,
+			PublicacionID:    prog.PublicacionID,
+			GrupoID:          grupoID,
+			UserID:           prog.UserID,
+			FechaProgramada:  horario,
+			FechaPublicacion: now,
+			Estado:           "omitida",
+			MensajeError:     motivo,
+			CreatedAt:        now,
+		}
+	}
+	if err := s.historial.Insert(registros...); err != nil {
+		log.Printf("Error guardando historial de horario omitido: %v", err)
+	}
+	return true, nil
+}
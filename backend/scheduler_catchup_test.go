@@ -0,0 +1,182 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// omitidasEn devuelve las fechas programadas de los registros "omitida" del
+// historial cuyo motivo contiene el texto indicado
+func (sim *simulacion) omitidasEn(motivo string) []string {
+	var fechas []string
+	for _, registro := range sim.historial.registros {
+		if registro.Estado == "omitida" && strings.Contains(registro.MensajeError, motivo) {
+			fechas = append(fechas, registro.FechaProgramada.UTC().Format("2006-01-02 15:04"))
+		}
+	}
+	return fechas
+}
+
+// detener simula una caída del scheduler: el reloj avanza sin ticks
+func (sim *simulacion) detener(hasta time.Time) {
+	sim.reloj.Advance(hasta.Sub(sim.reloj.Now()))
+}
+
+func TestSchedulerRecuperacion(t *testing.T) {
+	// Tres horarios por día; el scheduler está caído todo el martes 4
+	inicio := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+	caida := inicio.AddDate(0, 0, 1)
+	vuelta := time.Date(2025, 3, 5, 8, 0, 0, 0, time.UTC)
+	lunes := []string{"2025-03-03 09:00", "2025-03-03 12:00", "2025-03-03 15:00"}
+	miercoles := []string{"2025-03-05 09:00", "2025-03-05 12:00", "2025-03-05 15:00"}
+	concatenar := func(listas ...[]string) []string {
+		var fechas []string
+		for _, l := range listas {
+			fechas = append(fechas, l...)
+		}
+		return fechas
+	}
+
+	casos := []struct {
+		nombre    string
+		politica  string
+		espaciado int
+		esperadas []string
+		omitidas  []string
+	}{
+		{
+			nombre:    "omitir",
+			politica:  RecuperacionOmitir,
+			esperadas: concatenar(lunes, miercoles),
+			omitidas:  []string{"2025-03-04 09:00", "2025-03-04 12:00", "2025-03-04 15:00"},
+		},
+		{
+			nombre:    "ultima por defecto",
+			esperadas: concatenar(lunes, []string{"2025-03-05 08:00"}, miercoles),
+			omitidas:  []string{"2025-03-04 09:00", "2025-03-04 12:00"},
+		},
+		{
+			nombre:    "todas con el espaciado por defecto",
+			politica:  RecuperacionTodas,
+			esperadas: concatenar(lunes, []string{"2025-03-05 08:00", "2025-03-05 08:10", "2025-03-05 08:20"}, miercoles),
+		},
+		{
+			// Las recuperadas se intercalan con el horario normal de las 09:00
+			nombre:    "todas espaciadas 45 minutos",
+			politica:  RecuperacionTodas,
+			espaciado: 45,
+			esperadas: concatenar(lunes, []string{"2025-03-05 08:00", "2025-03-05 08:45", "2025-03-05 09:00", "2025-03-05 09:30", "2025-03-05 12:00", "2025-03-05 15:00"}),
+		},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			sim := nuevaSimulacion(t, inicio, 1)
+			sim.programar(ProgramacionPublicacion{
+				Frecuencia:            "diaria",
+				Horarios:              []ConfiguracionHorario{{Hora: 9}, {Hora: 12}, {Hora: 15}},
+				FechaInicio:           inicio,
+				PoliticaRecuperacion:  caso.politica,
+				EspaciadoRecuperacion: caso.espaciado,
+			})
+
+			sim.avanzar(caida)
+			sim.detener(vuelta)
+			sim.avanzar(inicio.AddDate(0, 0, 3))
+
+			compararFechas(t, sim.fechasPublicadas(sim.grupos[0], time.UTC), caso.esperadas)
+			compararFechas(t, sim.omitidasEn("horario perdido"), caso.omitidas)
+		})
+	}
+}
+
+func TestSchedulerRecuperacionLimitaHorariosPorCiclo(t *testing.T) {
+	// Una publicación por hora y 30 días caído: 720 horarios perdidos
+	inicio := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+	sim := nuevaSimulacion(t, inicio, 1)
+	sim.programar(ProgramacionPublicacion{
+		Frecuencia:           "personalizada",
+		Recurrencia:          "0 * * * *",
+		FechaInicio:          inicio,
+		PoliticaRecuperacion: RecuperacionOmitir,
+	})
+
+	sim.avanzar(inicio.Add(90 * time.Minute))
+	vuelta := inicio.AddDate(0, 0, 30).Add(90 * time.Minute)
+	sim.detener(vuelta)
+
+	// Cada ciclo revisa como máximo maxHorariosRecuperacion horarios
+	sim.avanzar(vuelta.Add(time.Minute))
+	if omitidas := len(sim.omitidasEn("horario perdido")); omitidas != maxHorariosRecuperacion {
+		t.Fatalf("el primer ciclo omitió %d horarios, se esperaban %d", omitidas, maxHorariosRecuperacion)
+	}
+
+	sim.avanzar(vuelta.Add(2 * time.Hour))
+	if omitidas := len(sim.omitidasEn("horario perdido")); omitidas != 720 {
+		t.Errorf("se omitieron %d horarios, se esperaban 720", omitidas)
+	}
+	compararFechas(t, sim.fechasPublicadas(sim.grupos[0], time.UTC), []string{
+		"2025-03-03 00:00", "2025-03-03 01:00", "2025-04-02 02:00", "2025-04-02 03:00",
+	})
+}
+
+func TestDueSlots(t *testing.T) {
+	inicio := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+	sim := nuevaSimulacion(t, inicio, 1)
+	fin := inicio.Add(2 * time.Hour)
+
+	casos := []struct {
+		nombre string
+		prog   ProgramacionPublicacion
+		now    time.Time
+		total  int
+		ultimo string
+	}{
+		{
+			nombre: "solo el horario actual",
+			prog:   ProgramacionPublicacion{Frecuencia: "personalizada", Recurrencia: "0 * * * *"},
+			now:    inicio.Add(30 * time.Minute),
+			total:  1,
+			ultimo: "2025-03-03 00:00",
+		},
+		{
+			nombre: "varios vencidos",
+			prog:   ProgramacionPublicacion{Frecuencia: "personalizada", Recurrencia: "0 * * * *"},
+			now:    inicio.Add(5 * time.Hour),
+			total:  6,
+			ultimo: "2025-03-03 05:00",
+		},
+		{
+			nombre: "hasta la fecha de fin",
+			prog:   ProgramacionPublicacion{Frecuencia: "personalizada", Recurrencia: "0 * * * *", FechaFin: &fin},
+			now:    inicio.Add(5 * time.Hour),
+			total:  3,
+			ultimo: "2025-03-03 02:00",
+		},
+		{
+			nombre: "limitados por ciclo",
+			prog:   ProgramacionPublicacion{Frecuencia: "personalizada", Recurrencia: "* * * * *"},
+			now:    inicio.AddDate(0, 0, 1),
+			total:  maxHorariosRecuperacion,
+			ultimo: "2025-03-03 08:19",
+		},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			prog := caso.prog
+			prog.FechaInicio = inicio
+			vencidos, err := sim.scheduler.dueSlots(prog, inicio, caso.now)
+			if err != nil {
+				t.Fatalf("dueSlots: %v", err)
+			}
+			if len(vencidos) != caso.total {
+				t.Fatalf("%d horarios vencidos, se esperaban %d", len(vencidos), caso.total)
+			}
+			if ultimo := vencidos[len(vencidos)-1].Format("2006-01-02 15:04"); ultimo != caso.ultimo {
+				t.Errorf("último horario %s, se esperaba %s", ultimo, caso.ultimo)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	// proximasHorizonte es el período en el que se buscan los próximos horarios
+	// para el estado del scheduler
+	proximasHorizonte = 24 * time.Hour
+
+	// proximasLimite limita los próximos horarios informados
+	proximasLimite = 20
+)
+
+// Tick revisa las programaciones activas, encola los horarios vencidos y
+// procesa los eventos de productos pendientes. Lo ejecuta el loop del
+// scheduler cada minuto en la instancia líder y puede pedirse manualmente
+// para depurar; devuelve la duración del tick.
+func (s *SchedulerService) Tick() time.Duration {
+	s.tickMu.Lock()
+	defer s.tickMu.Unlock()
+
+	inicio := s.clock.Now()
+	s.processPendingPublications()
+	s.processEvents()
+	duracion := s.clock.Now().Sub(inicio)
+
+	s.ultimoTick.Store(inicio.UnixNano())
+	s.duracionTick.Store(int64(duracion))
+	return duracion
+}
+
+// Status resume el estado del scheduler en esta instancia, la profundidad de
+// la cola, los próximos horarios y los resultados de las últimas 24 horas
+func (s *SchedulerService) Status() (*EstadoScheduler, error) {
+	now := s.clock.Now()
+
+	s.enCursoMu.Lock()
+	enCurso := len(s.enCurso)
+	s.enCursoMu.Unlock()
+
+	estado := &EstadoScheduler{
+		Activo:               s.running.Load(),
+		Lider:                s.leader.Load(),
+		Instancia:            s.lock.Instancia(),
+		Workers:              s.workers,
+		TrabajosEnCurso:      enCurso,
+		DuracionUltimoTickMs: time.Duration(s.duracionTick.Load()).Milliseconds(),
+	}
+	if ultimo := s.ultimoTick.Load(); ultimo != 0 {
+		t := time.Unix(0, ultimo)
+		estado.UltimoTick = &t
+	}
+
+	var err error
+	if estado.Cola, err = s.queue.CountByEstado(TrabajoPendiente, TrabajoEnProceso); err != nil {
+		return nil, err
+	}
+	if estado.Ultimas24Horas, err = s.historial.CountByEstadoSince(now.Add(-24 * time.Hour)); err != nil {
+		return nil, err
+	}
+	if estado.ProximasProgramaciones, err = s.upcoming(now, now.Add(proximasHorizonte)); err != nil {
+		return nil, err
+	}
+
+	return estado, nil
+}
+
+// upcoming devuelve el siguiente horario de cada programación activa que cae
+// antes de hasta, del más cercano al más lejano; los horarios ya vencidos
+// se incluyen porque el próximo tick los encolará
+func (s *SchedulerService) upcoming(now, hasta time.Time) ([]ProximaProgramacion, error) {
+	programaciones, err := s.programaciones.FindActive(hasta)
+	if err != nil {
+		return nil, err
+	}
+
+	proximas := []ProximaProgramacion{}
+	for _, prog := range programaciones {
+		if prog.Disparador != nil {
+			continue
+		}
+
+		horario, err := s.nextPublicationTime(prog)
+		if err != nil {
+			return nil, err
+		}
+		if horario.IsZero() || horario.After(hasta) || (prog.FechaFin != nil && horario.After(*prog.FechaFin)) {
+			continue
+		}
+
+		proximas = append(proximas, ProximaProgramacion{
+			ProgramacionID: prog.ID,
+			UserID:         prog.UserID,
+			Horario:        horario.In(s.locationFor(prog)),
+		})
+	}
+
+	sort.Slice(proximas, func(i, j int) bool {
+		return proximas[i].Horario.Before(proximas[j].Horario)
+	})
+	if len(proximas) > proximasLimite {
+		proximas = proximas[:proximasLimite]
+	}
+
+	return proximas, nil
+}
@@ -0,0 +1,812 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// relojSimulado es un Clock que solo avanza cuando la prueba lo indica
+type relojSimulado struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (r *relojSimulado) Now() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.now
+}
+
+func (r *relojSimulado) Advance(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.now = r.now.Add(d)
+}
+
+// programacionesMemoria implementa ProgramacionRepository en memoria
+type programacionesMemoria struct {
+	datos map[primitive.ObjectID]ProgramacionPublicacion
+}
+
+func (r *programacionesMemoria) Get(id primitive.ObjectID) (*ProgramacionPublicacion, error) {
+	prog, ok := r.datos[id]
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+	return &prog, nil
+}
+
+func (r *programacionesMemoria) FindActive(now time.Time) ([]ProgramacionPublicacion, error) {
+	var activas []ProgramacionPublicacion
+	for _, prog := range r.datos {
+		if prog.Estado == "activa" && !prog.FechaInicio.After(now) {
+			activas = append(activas, prog)
+		}
+	}
+	return activas, nil
+}
+
+func (r *programacionesMemoria) Complete(id primitive.ObjectID, now time.Time) (bool, error) {
+	prog, ok := r.datos[id]
+	if !ok || prog.Estado != "activa" {
+		return false, nil
+	}
+	prog.Estado = "completada"
+	prog.UpdatedAt = now
+	r.datos[id] = prog
+	return true, nil
+}
+
+func (r *programacionesMemoria) ChangeEstado(id primitive.ObjectID, desde, hasta string, reanudadaEn *time.Time, now time.Time) (*ProgramacionPublicacion, error) {
+	prog, ok := r.datos[id]
+	if !ok || prog.Estado != desde {
+		return nil, nil
+	}
+	prog.Estado = hasta
+	prog.UpdatedAt = now
+	if reanudadaEn != nil {
+		prog.ReanudadaEn = reanudadaEn
+	}
+	r.datos[id] = prog
+	return &prog, nil
+}
+
+// publicacionesMemoria implementa PublicacionRepository en memoria
+type publicacionesMemoria struct {
+	datos map[primitive.ObjectID]Publicacion
+}
+
+func (r *publicacionesMemoria) Get(id primitive.ObjectID) (*Publicacion, error) {
+	publicacion, ok := r.datos[id]
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+	return &publicacion, nil
+}
+
+func (r *publicacionesMemoria) SetVariantesGanadoras(id primitive.ObjectID, ganadoras map[string]primitive.ObjectID) error {
+	publicacion, ok := r.datos[id]
+	if !ok {
+		return nil
+	}
+	if publicacion.VariantesGanadoras == nil {
+		publicacion.VariantesGanadoras = make(map[string]primitive.ObjectID)
+	}
+	for grupo, variante := range ganadoras {
+		publicacion.VariantesGanadoras[grupo] = variante
+	}
+	r.datos[id] = publicacion
+	return nil
+}
+
+func (r *publicacionesMemoria) FindIDsByProducto(productoID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	var ids []primitive.ObjectID
+	for id, publicacion := range r.datos {
+		for _, p := range publicacion.Productos {
+			if p.ProductoID == productoID {
+				ids = append(ids, id)
+				break
+			}
+		}
+	}
+	return ids, nil
+}
+
+// eventosMemoria implementa EventoRepository en memoria
+type eventosMemoria struct {
+	eventos []EventoProducto
+}
+
+func (r *eventosMemoria) Insert(eventos ...EventoProducto) error {
+	r.eventos = append(r.eventos, eventos...)
+	return nil
+}
+
+func (r *eventosMemoria) Pending(limite int) ([]EventoProducto, error) {
+	var pendientes []EventoProducto
+	for _, evento := range r.eventos {
+		if evento.Estado == EventoPendiente && len(pendientes) < limite {
+			pendientes = append(pendientes, evento)
+		}
+	}
+	return pendientes, nil
+}
+
+func (r *eventosMemoria) MarkProcessed(id primitive.ObjectID, programaciones []primitive.ObjectID, now time.Time) error {
+	for i := range r.eventos {
+		if r.eventos[i].ID == id {
+			r.eventos[i].Estado = EventoProcesado
+			r.eventos[i].Programaciones = programaciones
+			r.eventos[i].ProcesadoEn = &now
+		}
+	}
+	return nil
+}
+
+// productosMemoria implementa ProductoRepository en memoria
+type productosMemoria struct {
+	datos map[primitive.ObjectID]Producto
+}
+
+func (r *productosMemoria) FindByIDs(ids []primitive.ObjectID) ([]Producto, error) {
+	var productos []Producto
+	for _, id := range ids {
+		if producto, ok := r.datos[id]; ok {
+			productos = append(productos, producto)
+		}
+	}
+	return productos, nil
+}
+
+// gruposMemoria implementa GrupoRepository en memoria
+type gruposMemoria struct {
+	datos map[primitive.ObjectID]GrupoFacebook
+}
+
+func (r *gruposMemoria) FindByIDs(ids []primitive.ObjectID) ([]GrupoFacebook, error) {
+	var grupos []GrupoFacebook
+	for _, id := range ids {
+		if grupo, ok := r.datos[id]; ok {
+			grupos = append(grupos, grupo)
+		}
+	}
+	return grupos, nil
+}
+
+func (r *gruposMemoria) Reserve(grupoID, trabajoID primitive.ObjectID, now time.Time, limite limiteGrupo) (bool, *GrupoFacebook, error) {
+	grupo, ok := r.datos[grupoID]
+	if !ok {
+		return false, nil, mongo.ErrNoDocuments
+	}
+
+	reservas := []ReservaGrupo{}
+	reservado := false
+	for _, reserva := range grupo.Reservas {
+		if reserva.TrabajoID == trabajoID {
+			reservado = true
+			continue
+		}
+		reservas = append(reservas, reserva)
+	}
+	if grupo.UltimaPublicacion != nil && grupo.UltimaPublicacion.After(now.Add(-limite.intervalo)) && grupo.UltimoTrabajoID != trabajoID {
+		return false, &grupo, nil
+	}
+	if limite.maximo > 0 && !reservado && grupo.reservasDesde(limite.desde) >= limite.maximo {
+		return false, &grupo, nil
+	}
+
+	grupo.UltimaPublicacion = &now
+	grupo.UltimoTrabajoID = trabajoID
+	grupo.Reservas = append(reservas, ReservaGrupo{TrabajoID: trabajoID, Fecha: now})
+	r.datos[grupoID] = grupo
+	return true, nil, nil
+}
+
+func (r *gruposMemoria) Release(grupoID, trabajoID primitive.ObjectID) error {
+	grupo, ok := r.datos[grupoID]
+	if !ok {
+		return nil
+	}
+
+	reservas := []ReservaGrupo{}
+	for _, reserva := range grupo.Reservas {
+		if reserva.TrabajoID != trabajoID {
+			reservas = append(reservas, reserva)
+		}
+	}
+	grupo.Reservas = reservas
+	if grupo.UltimoTrabajoID == trabajoID {
+		grupo.UltimaPublicacion = nil
+		grupo.UltimoTrabajoID = primitive.NilObjectID
+	}
+	r.datos[grupoID] = grupo
+	return nil
+}
+
+// historialMemoria implementa HistorialRepository en memoria
+type historialMemoria struct {
+	registros []HistorialPublicacion
+}
+
+func (r *historialMemoria) Insert(registros ...HistorialPublicacion) error {
+	r.registros = append(r.registros, registros...)
+	return nil
+}
+
+func (r *historialMemoria) Replace(registro HistorialPublicacion) error {
+	for i := range r.registros {
+		if r.registros[i].ID == registro.ID {
+			r.registros[i] = registro
+		}
+	}
+	return nil
+}
+
+func (r *historialMemoria) LastScheduled(programacionID primitive.ObjectID) (*HistorialPublicacion, error) {
+	var ultimo *HistorialPublicacion
+	for i := range r.registros {
+		h := &r.registros[i]
+		if h.ProgramacionID != programacionID || h.Manual {
+			continue
+		}
+		if ultimo == nil || h.FechaProgramada.After(ultimo.FechaProgramada) ||
+			(h.FechaProgramada.Equal(ultimo.FechaProgramada) && h.FechaPublicacion.After(ultimo.FechaPublicacion)) {
+			ultimo = h
+		}
+	}
+	if ultimo == nil {
+		return nil, nil
+	}
+	copia := *ultimo
+	return &copia, nil
+}
+
+func (r *historialMemoria) CountSuccessfulByUser(userID primitive.ObjectID, desde time.Time) (int, error) {
+	return r.count(func(h HistorialPublicacion) bool {
+		return h.UserID == userID && h.Estado == "exitosa" && !h.FechaPublicacion.Before(desde)
+	}), nil
+}
+
+func (r *historialMemoria) CountVariantes(publicacionID, grupoID primitive.ObjectID) (int, error) {
+	return r.count(func(h HistorialPublicacion) bool {
+		return h.PublicacionID == publicacionID && h.GrupoID == grupoID && !h.VarianteID.IsZero()
+	}), nil
+}
+
+func (r *historialMemoria) count(filtro func(HistorialPublicacion) bool) int {
+	total := 0
+	for _, h := range r.registros {
+		if filtro(h) {
+			total++
+		}
+	}
+	return total
+}
+
+func (r *historialMemoria) PendingEngagement(desde, actualizadoAntes time.Time, limite int) ([]HistorialPublicacion, error) {
+	var pendientes []HistorialPublicacion
+	for _, h := range r.registros {
+		if len(pendientes) >= limite {
+			break
+		}
+		if h.Estado != "exitosa" || h.FacebookPostID == "" || h.VarianteID.IsZero() || h.FechaPublicacion.Before(desde) {
+			continue
+		}
+		if h.Metricas == nil || h.Metricas.ActualizadoEn.Before(actualizadoAntes) {
+			pendientes = append(pendientes, h)
+		}
+	}
+	return pendientes, nil
+}
+
+func (r *historialMemoria) SetMetricas(id primitive.ObjectID, metricas MetricasPublicacion) error {
+	for i := range r.registros {
+		if r.registros[i].ID == id {
+			r.registros[i].Metricas = &metricas
+		}
+	}
+	return nil
+}
+
+func (r *historialMemoria) VariantTotals(publicacionID primitive.ObjectID) ([]RendimientoVariante, error) {
+	type clave struct{ grupo, variante primitive.ObjectID }
+	totales := make(map[clave]*RendimientoVariante)
+	for _, h := range r.registros {
+		if h.PublicacionID != publicacionID || h.Estado != "exitosa" || h.VarianteID.IsZero() {
+			continue
+		}
+		k := clave{h.GrupoID, h.VarianteID}
+		if totales[k] == nil {
+			totales[k] = &RendimientoVariante{GrupoID: h.GrupoID, VarianteID: h.VarianteID}
+		}
+		totales[k].Publicaciones++
+		if h.Metricas != nil {
+			totales[k].Reacciones += h.Metricas.Reacciones
+			totales[k].Comentarios += h.Metricas.Comentarios
+			totales[k].Compartidos += h.Metricas.Compartidos
+		}
+	}
+
+	rendimientos := []RendimientoVariante{}
+	for _, r := range totales {
+		rendimientos = append(rendimientos, *r)
+	}
+	sort.Slice(rendimientos, func(i, j int) bool {
+		if rendimientos[i].GrupoID != rendimientos[j].GrupoID {
+			return rendimientos[i].GrupoID.Hex() < rendimientos[j].GrupoID.Hex()
+		}
+		return rendimientos[i].VarianteID.Hex() < rendimientos[j].VarianteID.Hex()
+	})
+	return rendimientos, nil
+}
+
+func (r *historialMemoria) CountByEstadoSince(desde time.Time) (map[string]int, error) {
+	conteos := make(map[string]int)
+	for _, h := range r.registros {
+		if !h.FechaPublicacion.Before(desde) {
+			conteos[h.Estado]++
+		}
+	}
+	return conteos, nil
+}
+
+// ledgerMemoria implementa Ledger en memoria con la misma unicidad por
+// programación y horario que el índice de RunLedger
+type ledgerMemoria struct {
+	clock       Clock
+	ejecuciones []Ejecucion
+}
+
+func (l *ledgerMemoria) Start(prog ProgramacionPublicacion, horario time.Time, grupos []GrupoFacebook, manual bool) (bool, error) {
+	now := l.clock.Now()
+	ejecucion := Ejecucion{
+		ID:              primitive.NewObjectID(),
+		ProgramacionID:  prog.ID,
+		PublicacionID:   prog.PublicacionID,
+		FechaProgramada: horario,
+		InicioReal:      now,
+		Estado:          EjecucionEnCurso,
+		Manual:          manual,
+	}
+	for _, grupo := range grupos {
+		ejecucion.Grupos = append(ejecucion.Grupos, ResultadoGrupo{GrupoID: grupo.ID, Estado: ResultadoPendiente})
+	}
+	if len(grupos) == 0 {
+		ejecucion.Estado = EjecucionFallida
+	}
+	return l.insert(ejecucion), nil
+}
+
+func (l *ledgerMemoria) Skip(prog ProgramacionPublicacion, horario time.Time, motivo string) (bool, error) {
+	return l.insert(Ejecucion{
+		ID:              primitive.NewObjectID(),
+		ProgramacionID:  prog.ID,
+		PublicacionID:   prog.PublicacionID,
+		FechaProgramada: horario,
+		InicioReal:      l.clock.Now(),
+		Estado:          EjecucionOmitida,
+		Motivo:          motivo,
+	}), nil
+}
+
+func (l *ledgerMemoria) insert(ejecucion Ejecucion) bool {
+	if l.find(ejecucion.ProgramacionID, ejecucion.FechaProgramada) != nil {
+		return false
+	}
+	l.ejecuciones = append(l.ejecuciones, ejecucion)
+	return true
+}
+
+func (l *ledgerMemoria) find(programacionID primitive.ObjectID, horario time.Time) *Ejecucion {
+	for i := range l.ejecuciones {
+		if l.ejecuciones[i].ProgramacionID == programacionID && l.ejecuciones[i].FechaProgramada.Equal(horario) {
+			return &l.ejecuciones[i]
+		}
+	}
+	return nil
+}
+
+func (l *ledgerMemoria) RecordGroupResult(trabajo TrabajoPublicacion, postID string, postErr error) error {
+	ejecucion := l.find(trabajo.ProgramacionID, trabajo.FechaProgramada)
+	if ejecucion == nil {
+		return mongo.ErrNoDocuments
+	}
+
+	estado := ResultadoExitoso
+	if postErr != nil {
+		estado = ResultadoFallido
+		var omitido *errorOmitido
+		if errors.As(postErr, &omitido) {
+			estado = ResultadoOmitido
+		}
+	}
+	for i := range ejecucion.Grupos {
+		if ejecucion.Grupos[i].GrupoID == trabajo.GrupoID {
+			ejecucion.Grupos[i].Estado = estado
+			ejecucion.Grupos[i].FacebookPostID = postID
+		}
+	}
+	if ejecucion.Estado == EjecucionEnCurso {
+		ejecucion.Estado = estadoEjecucion(ejecucion.Grupos)
+	}
+	return nil
+}
+
+func (l *ledgerMemoria) Last(programacionID primitive.ObjectID) (*Ejecucion, error) {
+	var ultima *Ejecucion
+	for i := range l.ejecuciones {
+		e := &l.ejecuciones[i]
+		if e.ProgramacionID == programacionID && !e.Manual && (ultima == nil || e.FechaProgramada.After(ultima.FechaProgramada)) {
+			ultima = e
+		}
+	}
+	if ultima == nil {
+		return nil, nil
+	}
+	copia := *ultima
+	return &copia, nil
+}
+
+func (l *ledgerMemoria) Get(programacionID primitive.ObjectID, horario time.Time) (*Ejecucion, error) {
+	ejecucion := l.find(programacionID, horario)
+	if ejecucion == nil {
+		return nil, mongo.ErrNoDocuments
+	}
+	copia := *ejecucion
+	return &copia, nil
+}
+
+func (l *ledgerMemoria) publicadas(programacionID primitive.ObjectID) []Ejecucion {
+	var ejecuciones []Ejecucion
+	for _, e := range l.ejecuciones {
+		if e.ProgramacionID == programacionID && e.Estado != EjecucionOmitida {
+			ejecuciones = append(ejecuciones, e)
+		}
+	}
+	sort.Slice(ejecuciones, func(i, j int) bool {
+		return ejecuciones[i].FechaProgramada.After(ejecuciones[j].FechaProgramada)
+	})
+	return ejecuciones
+}
+
+func (l *ledgerMemoria) RecentPublicaciones(programacionID primitive.ObjectID, limite int) ([]primitive.ObjectID, error) {
+	var ids []primitive.ObjectID
+	for _, e := range l.publicadas(programacionID) {
+		if len(ids) >= limite {
+			break
+		}
+		ids = append(ids, e.PublicacionID)
+	}
+	return ids, nil
+}
+
+func (l *ledgerMemoria) CountPublished(programacionID primitive.ObjectID) (int, error) {
+	return len(l.publicadas(programacionID)), nil
+}
+
+func (l *ledgerMemoria) CountInProgress(programacionID primitive.ObjectID) (int, error) {
+	total := 0
+	for _, e := range l.ejecuciones {
+		if e.ProgramacionID == programacionID && !e.Manual && e.Estado == EjecucionEnCurso {
+			total++
+		}
+	}
+	return total, nil
+}
+
+func (l *ledgerMemoria) CountSuccessful(ids []primitive.ObjectID) (map[primitive.ObjectID]int, error) {
+	conteos := make(map[primitive.ObjectID]int)
+	for _, id := range ids {
+		for _, e := range l.ejecuciones {
+			if e.ProgramacionID == id && !e.Manual && (e.Estado == EjecucionExitosa || e.Estado == EjecucionParcial) {
+				conteos[id]++
+			}
+		}
+	}
+	return conteos, nil
+}
+
+// colaMemoria implementa Queue en memoria
+type colaMemoria struct {
+	clock    Clock
+	lease    time.Duration
+	mu       sync.Mutex // Los workers y la renovación del lease usan la cola a la vez
+	trabajos []TrabajoPublicacion
+}
+
+func (q *colaMemoria) Enqueue(trabajos []TrabajoPublicacion) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	nuevos := 0
+	for _, trabajo := range trabajos {
+		if q.existe(trabajo) {
+			continue
+		}
+		trabajo.ID = primitive.NewObjectID()
+		trabajo.Estado = TrabajoPendiente
+		if trabajo.DisponibleDesde.IsZero() {
+			trabajo.DisponibleDesde = q.clock.Now()
+		}
+		q.trabajos = append(q.trabajos, trabajo)
+		nuevos++
+	}
+	return nuevos, nil
+}
+
+func (q *colaMemoria) existe(trabajo TrabajoPublicacion) bool {
+	for _, t := range q.trabajos {
+		if t.ProgramacionID == trabajo.ProgramacionID && t.GrupoID == trabajo.GrupoID && t.FechaProgramada.Equal(trabajo.FechaProgramada) {
+			return true
+		}
+	}
+	return false
+}
+
+func (q *colaMemoria) Claim(instancia string) (*TrabajoPublicacion, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.clock.Now()
+
+	elegido := -1
+	for i, t := range q.trabajos {
+		disponible := (t.Estado == TrabajoPendiente && !t.DisponibleDesde.After(now)) ||
+			(t.Estado == TrabajoEnProceso && t.LeaseHasta.Before(now))
+		if disponible && (elegido < 0 || t.DisponibleDesde.Before(q.trabajos[elegido].DisponibleDesde)) {
+			elegido = i
+		}
+	}
+	if elegido < 0 {
+		return nil, nil
+	}
+
+	t := &q.trabajos[elegido]
+	t.Estado = TrabajoEnProceso
+	t.Instancia = instancia
+	t.LeaseHasta = now.Add(q.lease)
+	t.Intentos++
+
+	copia := *t
+	return &copia, nil
+}
+
+func (q *colaMemoria) update(trabajo TrabajoPublicacion, cambiar func(*TrabajoPublicacion)) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i := range q.trabajos {
+		t := &q.trabajos[i]
+		if t.ID == trabajo.ID && t.Instancia == trabajo.Instancia && t.Estado == TrabajoEnProceso {
+			cambiar(t)
+		}
+	}
+	return nil
+}
+
+func (q *colaMemoria) Ack(trabajo TrabajoPublicacion, estado, mensajeError string) error {
+	return q.update(trabajo, func(t *TrabajoPublicacion) {
+		t.Estado = estado
+		t.MensajeError = mensajeError
+	})
+}
+
+func (q *colaMemoria) Defer(trabajo TrabajoPublicacion, disponibleDesde time.Time, motivo string) error {
+	return q.update(trabajo, func(t *TrabajoPublicacion) {
+		t.Estado = TrabajoPendiente
+		t.DisponibleDesde = disponibleDesde
+		t.MensajeError = motivo
+		t.Intentos--
+	})
+}
+
+func (q *colaMemoria) Retry(trabajo TrabajoPublicacion, disponibleDesde time.Time, mensajeError string) error {
+	return q.update(trabajo, func(t *TrabajoPublicacion) {
+		t.Estado = TrabajoPendiente
+		t.DisponibleDesde = disponibleDesde
+		t.MensajeError = mensajeError
+	})
+}
+
+func (q *colaMemoria) Extend(trabajo TrabajoPublicacion) error {
+	leaseHasta := q.clock.Now().Add(q.lease)
+	return q.update(trabajo, func(t *TrabajoPublicacion) {
+		t.LeaseHasta = leaseHasta
+	})
+}
+
+// leaseHasta devuelve hasta cuándo está tomado el trabajo
+func (q *colaMemoria) leaseHasta(id primitive.ObjectID) time.Time {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, t := range q.trabajos {
+		if t.ID == id {
+			return t.LeaseHasta
+		}
+	}
+	return time.Time{}
+}
+
+func (q *colaMemoria) CountByEstado(estados ...string) (map[string]int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	conteos := make(map[string]int, len(estados))
+	for _, estado := range estados {
+		conteos[estado] = 0
+		for _, t := range q.trabajos {
+			if t.Estado == estado {
+				conteos[estado]++
+			}
+		}
+	}
+	return conteos, nil
+}
+
+// lockMemoria es un LeaderElector que siempre es líder
+type lockMemoria struct{}
+
+func (lockMemoria) Acquire() (bool, error) { return true, nil }
+func (lockMemoria) Release() error         { return nil }
+func (lockMemoria) Instancia() string      { return "prueba" }
+
+// usuariosMemoria implementa UsuarioRepository en memoria
+type usuariosMemoria struct {
+	datos map[string]Usuario
+}
+
+func (r *usuariosMemoria) GetUserByID(userID string) (*Usuario, error) {
+	usuario, ok := r.datos[userID]
+	if !ok {
+		return nil, fmt.Errorf("usuario no encontrado")
+	}
+	return &usuario, nil
+}
+
+func (r *usuariosMemoria) ExpireFacebookToken(userID string, now time.Time) error {
+	usuario, ok := r.datos[userID]
+	if !ok {
+		return fmt.Errorf("usuario no encontrado")
+	}
+	usuario.TokenExpiracion = now
+	r.datos[userID] = usuario
+	return nil
+}
+
+func (r *usuariosMemoria) FindExpiringFacebookTokens(hasta time.Time) ([]Usuario, error) {
+	var usuarios []Usuario
+	for _, usuario := range r.datos {
+		if usuario.FacebookAccessToken != "" && usuario.TokenExpiracion.Before(hasta) {
+			usuarios = append(usuarios, usuario)
+		}
+	}
+	return usuarios, nil
+}
+
+func (r *usuariosMemoria) UpdateFacebookTokens(userID, facebookUserID, accessToken string, expiration time.Time) error {
+	usuario, ok := r.datos[userID]
+	if !ok {
+		return fmt.Errorf("usuario no encontrado")
+	}
+	usuario.FacebookUserID = facebookUserID
+	usuario.FacebookAccessToken = accessToken
+	usuario.TokenExpiracion = expiration
+	r.datos[userID] = usuario
+	return nil
+}
+
+// notificacionesMemoria implementa NotificacionRepository en memoria
+type notificacionesMemoria struct {
+	datos []Notificacion
+}
+
+func (r *notificacionesMemoria) Notify(notificacion Notificacion) (bool, error) {
+	for _, n := range r.datos {
+		if n.UserID == notificacion.UserID && n.Clave == notificacion.Clave {
+			return false, nil
+		}
+	}
+	notificacion.ID = primitive.NewObjectID()
+	r.datos = append(r.datos, notificacion)
+	return true, nil
+}
+
+// publicacionSimulada es una publicación recibida por el Facebook simulado
+type publicacionSimulada struct {
+	grupo   string
+	fecha   time.Time
+	mensaje string
+	fotos   []FotoPublicacion // Vacío en las publicaciones de texto
+}
+
+// facebookSimulado registra las publicaciones en lugar de llamar a la Graph
+// API; errores se devuelven, en orden, en las primeras publicaciones,
+// erroresRenovacion en las primeras renovaciones de tokens y antesDePublicar
+// permite simular una llamada lenta
+type facebookSimulado struct {
+	clock             Clock
+	uso               *MonitorUsoGraph // Opcional: uso de la Graph API que frena las publicaciones
+	errores           []error
+	erroresRenovacion []error
+	renovaciones      int
+	publicaciones     []publicacionSimulada
+	antesDePublicar   func()
+	antesDeRenovar    func()
+	antesDeMetricas   func()
+}
+
+func (f *facebookSimulado) PostToGroup(accessToken, groupID string, postReq FacebookPostRequest) (*FacebookPostResponse, error) {
+	if err := f.publicar(groupID, postReq.Message, nil); err != nil {
+		return nil, err
+	}
+	return &FacebookPostResponse{ID: fmt.Sprintf("%s_%d", groupID, len(f.publicaciones))}, nil
+}
+
+func (f *facebookSimulado) PostPhotoFromURL(accessToken, groupID string, postReq FacebookPostRequest) (*FacebookPostResponse, error) {
+	if err := f.publicar(groupID, postReq.Message, []FotoPublicacion{{URL: postReq.ImageURL, Caption: postReq.Message}}); err != nil {
+		return nil, err
+	}
+	return &FacebookPostResponse{ID: fmt.Sprintf("foto_%d", len(f.publicaciones)), PostID: fmt.Sprintf("%s_%d", groupID, len(f.publicaciones))}, nil
+}
+
+func (f *facebookSimulado) PostAlbum(accessToken, groupID, message string, fotos []FotoPublicacion) (*FacebookPostResponse, error) {
+	if err := f.publicar(groupID, message, fotos); err != nil {
+		return nil, err
+	}
+	return &FacebookPostResponse{ID: fmt.Sprintf("%s_%d", groupID, len(f.publicaciones))}, nil
+}
+
+// publicar registra la publicación o devuelve el siguiente error configurado
+func (f *facebookSimulado) publicar(groupID, mensaje string, fotos []FotoPublicacion) error {
+	if f.antesDePublicar != nil {
+		f.antesDePublicar()
+	}
+	if len(f.errores) > 0 {
+		err := f.errores[0]
+		f.errores = f.errores[1:]
+		return err
+	}
+
+	f.publicaciones = append(f.publicaciones, publicacionSimulada{grupo: groupID, fecha: f.clock.Now(), mensaje: mensaje, fotos: fotos})
+	return nil
+}
+
+func (f *facebookSimulado) GetPostEngagement(accessToken, postID string) (*FacebookPostEngagement, error) {
+	if f.antesDeMetricas != nil {
+		f.antesDeMetricas()
+	}
+	return &FacebookPostEngagement{}, nil
+}
+
+// RefreshLongLivedToken devuelve un token nuevo de 60 días
+func (f *facebookSimulado) RefreshLongLivedToken(accessToken string) (*FacebookLongLivedToken, error) {
+	if f.antesDeRenovar != nil {
+		f.antesDeRenovar()
+	}
+	if len(f.erroresRenovacion) > 0 {
+		err := f.erroresRenovacion[0]
+		f.erroresRenovacion = f.erroresRenovacion[1:]
+		return nil, err
+	}
+
+	f.renovaciones++
+	return &FacebookLongLivedToken{
+		AccessToken: fmt.Sprintf("renovado-%d", f.renovaciones),
+		TokenType:   "bearer",
+		ExpiresIn:   int64(duracionTokenLargo.Seconds()),
+	}, nil
+}
+
+func (f *facebookSimulado) UsageWait(accessToken string, now time.Time) (time.Duration, string) {
+	if f.uso == nil {
+		return 0, ""
+	}
+	return f.uso.Wait(accessToken, now)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Preview calcula, sin publicar en Facebook, los próximos horarios de una
+// programación hasta la fecha indicada con los grupos de cada uno y la
+// publicación que le tocaría según la rotación. Con rotación aleatoria o
+// ponderada la publicación de cada horario es solo un ejemplo. Usa la misma
+// recurrencia, zona horaria, fecha de fin, cantidad de publicaciones y
+// restricciones que el scheduler; los horarios diferidos u omitidos por
+// horarios de silencio o fechas bloqueadas se indican en cada ocurrencia.
+func (s *SchedulerService) Preview(prog ProgramacionPublicacion, hasta time.Time, limite int) (*VistaPreviaProgramacion, error) {
+	if prog.Disparador != nil {
+		return nil, ErrProgramacionPorEventos
+	}
+
+	recurrencia, err := s.recurrenciaFor(prog)
+	if err != nil {
+		return nil, err
+	}
+	loc := s.locationFor(prog)
+
+	grupos, err := s.getGruposObjetivo(prog.GruposObjetivo)
+	if err != nil {
+		return nil, err
+	}
+
+	restantes := -1
+	if prog.CantidadPublicaciones > 0 {
+		restantes = prog.CantidadPublicaciones
+		if !prog.ID.IsZero() {
+			realizadas, err := s.countSuccessfulRuns(prog.ID)
+			if err != nil {
+				return nil, err
+			}
+			if restantes, err = s.remainingRuns(prog, realizadas); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	vista := &VistaPreviaProgramacion{
+		ZonaHoraria:   loc.String(),
+		Rotacion:      rotacionProgramacion(prog),
+		Publicaciones: []PublicacionVistaPrevia{},
+		Grupos:        make([]GrupoVistaPrevia, len(grupos)),
+		Ocurrencias:   []OcurrenciaVistaPrevia{},
+	}
+	for i, grupo := range grupos {
+		vista.Grupos[i] = GrupoVistaPrevia{ID: grupo.ID, Nombre: grupo.Nombre, FacebookID: grupo.FacebookID}
+	}
+
+	for _, p := range publicacionesDe(prog) {
+		publicacion, err := s.getPublicacion(p.PublicacionID)
+		if err != nil {
+			return nil, fmt.Errorf("publicación %s: %v", p.PublicacionID.Hex(), err)
+		}
+		vista.Publicaciones = append(vista.Publicaciones, PublicacionVistaPrevia{
+			ID:        publicacion.ID,
+			Titulo:    publicacion.Titulo,
+			Mensaje:   s.buildMessage(*publicacion),
+			ImagenURL: publicacion.ImagenURL,
+		})
+	}
+
+	// Historial de la rotación a partir del cual se simulan los próximos horarios
+	var recientes []primitive.ObjectID
+	usadas := 0
+	if !prog.ID.IsZero() {
+		if recientes, err = s.ledger.RecentPublicaciones(prog.ID, len(vista.Publicaciones)); err != nil {
+			return nil, err
+		}
+		if usadas, err = s.ledger.CountPublished(prog.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	// Las programaciones pausadas o completadas no tienen próximas ejecuciones
+	if prog.Estado != "" && prog.Estado != "activa" {
+		return vista, nil
+	}
+
+	horario, err := s.nextPublicationTime(prog)
+	if err != nil {
+		return nil, err
+	}
+
+	// Los horarios ya vencidos los resuelve la política de recuperación; la
+	// vista previa empieza en el primero que aún no llegó
+	if now := s.clock.Now(); !horario.IsZero() && horario.Before(now) {
+		horario = recurrencia.Next(now.Add(-time.Nanosecond))
+	}
+
+	calendario := s.calendarFor(prog)
+	publicadas := 0
+	for !horario.IsZero() && !horario.After(hasta) {
+		if prog.FechaFin != nil && horario.After(*prog.FechaFin) {
+			break
+		}
+		if restantes >= 0 && publicadas >= restantes {
+			break
+		}
+		if len(vista.Ocurrencias) >= limite {
+			vista.Truncada = true
+			break
+		}
+
+		siguiente := recurrencia.Next(horario)
+		ocurrencia := OcurrenciaVistaPrevia{
+			Fecha:  horario.In(loc),
+			Grupos: vista.Grupos,
+		}
+
+		permitido, motivo, omitir := calendario.resolver(horario, siguiente)
+		switch {
+		case omitir:
+			ocurrencia.Omitida = true
+			ocurrencia.Motivo = motivo + " (se publica en el siguiente horario)"
+			ocurrencia.Grupos = []GrupoVistaPrevia{}
+		default:
+			if motivo != "" {
+				diferida := permitido.In(loc)
+				ocurrencia.DiferidaHasta = &diferida
+				ocurrencia.Motivo = motivo
+			}
+			elegida := elegirPublicacion(prog, recientes, usadas)
+			ocurrencia.PublicacionID = &elegida
+			recientes = append([]primitive.ObjectID{elegida}, recientes...)
+			usadas++
+			publicadas++
+		}
+
+		vista.Ocurrencias = append(vista.Ocurrencias, ocurrencia)
+		horario = siguiente
+	}
+
+	return vista, nil
+}
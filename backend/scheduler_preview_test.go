@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fechasVistaPrevia devuelve cuándo se publicaría cada ocurrencia no omitida
+// de la vista previa, con el diferimiento aplicado
+func fechasVistaPrevia(vista *VistaPreviaProgramacion, loc *time.Location) []string {
+	var fechas []string
+	for _, o := range vista.Ocurrencias {
+		if o.Omitida {
+			continue
+		}
+		fecha := o.Fecha
+		if o.DiferidaHasta != nil {
+			fecha = *o.DiferidaHasta
+		}
+		fechas = append(fechas, fecha.In(loc).Format("2006-01-02 15:04"))
+	}
+	return fechas
+}
+
+func TestPreviewCoincideConSimulacion(t *testing.T) {
+	inicio := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+	// Los horarios hasta el viernes; la simulación sigue hasta que se
+	// publiquen los diferidos
+	hasta := time.Date(2025, 3, 7, 23, 59, 0, 0, time.UTC)
+	silencioNocturno := HorarioSilencio{Desde: ConfiguracionHorario{Hora: 22}, Hasta: ConfiguracionHorario{Hora: 7}}
+
+	casos := []struct {
+		nombre    string
+		prog      ProgramacionPublicacion
+		omitidas  int
+		diferidas int
+	}{
+		{
+			nombre: "diaria",
+			prog:   ProgramacionPublicacion{Frecuencia: "diaria", Horarios: []ConfiguracionHorario{{Hora: 9}}},
+		},
+		{
+			nombre: "cron con cantidad de publicaciones",
+			prog:   ProgramacionPublicacion{Frecuencia: "personalizada", Recurrencia: "0 9,18 * * *", CantidadPublicaciones: 5},
+		},
+		{
+			nombre: "horas de silencio difieren hasta la mañana",
+			prog: ProgramacionPublicacion{
+				Frecuencia:    "diaria",
+				Horarios:      []ConfiguracionHorario{{Hora: 9}, {Hora: 22, Minuto: 30}},
+				Restricciones: RestriccionesPublicacion{HorasSilencio: []HorarioSilencio{silencioNocturno}},
+			},
+			diferidas: 5, // La del viernes a las 22:30 sale el sábado a las 07:00
+		},
+		{
+			// El martes a las 09:00 se omite porque el siguiente horario también
+			// está bloqueado; el de las 22:30 se difiere al miércoles
+			nombre: "fecha bloqueada omite y difiere",
+			prog: ProgramacionPublicacion{
+				Frecuencia:    "diaria",
+				Horarios:      []ConfiguracionHorario{{Hora: 9}, {Hora: 22, Minuto: 30}},
+				Restricciones: RestriccionesPublicacion{FechasBloqueadas: []FechaBloqueada{{Desde: "2025-03-04"}}},
+			},
+			omitidas:  1,
+			diferidas: 1,
+		},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			sim := nuevaSimulacion(t, inicio, 1)
+			prog := caso.prog
+			prog.FechaInicio = inicio
+			id := sim.programar(prog)
+
+			vista, err := sim.scheduler.Preview(sim.programaciones.datos[id], hasta, 100)
+			if err != nil {
+				t.Fatalf("Preview: %v", err)
+			}
+
+			omitidas, diferidas := 0, 0
+			for _, o := range vista.Ocurrencias {
+				if o.Omitida {
+					omitidas++
+				}
+				if o.DiferidaHasta != nil {
+					diferidas++
+				}
+			}
+			if omitidas != caso.omitidas || diferidas != caso.diferidas {
+				t.Errorf("%d omitidas y %d diferidas, se esperaban %d y %d", omitidas, diferidas, caso.omitidas, caso.diferidas)
+			}
+
+			sim.avanzar(hasta.Add(9 * time.Hour))
+			compararFechas(t, sim.fechasPublicadas(sim.grupos[0], time.UTC), fechasVistaPrevia(vista, time.UTC))
+		})
+	}
+}
+
+func TestPreviewNuevaProgramacionHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	inicio := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+	sim := nuevaSimulacion(t, inicio, 1)
+	cuerpo := `{"publicacion_id":"` + sim.publicacion.ID.Hex() + `","horarios":[{"hora":9}],"fecha_inicio":"2025-03-03T00:00:00Z"}`
+
+	casos := []struct {
+		nombre      string
+		consulta    string
+		cuerpo      string
+		estado      int
+		ocurrencias []string
+		truncada    bool
+	}{
+		{
+			nombre:      "hasta un día incluye ese día completo",
+			consulta:    "until=2025-03-05",
+			cuerpo:      cuerpo,
+			estado:      http.StatusOK,
+			ocurrencias: []string{"2025-03-03 09:00", "2025-03-04 09:00", "2025-03-05 09:00"},
+		},
+		{
+			nombre:      "límite de ocurrencias",
+			consulta:    "until=2025-03-05&limite=2",
+			cuerpo:      cuerpo,
+			estado:      http.StatusOK,
+			ocurrencias: []string{"2025-03-03 09:00", "2025-03-04 09:00"},
+			truncada:    true,
+		},
+		{nombre: "until inválido", consulta: "until=mañana", cuerpo: cuerpo, estado: http.StatusBadRequest},
+		{nombre: "límite inválido", consulta: "limite=0", cuerpo: cuerpo, estado: http.StatusBadRequest},
+		{nombre: "sin publicación", consulta: "", cuerpo: `{"horarios":[{"hora":9}]}`, estado: http.StatusBadRequest},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/programaciones/preview?"+caso.consulta, strings.NewReader(caso.cuerpo))
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			PreviewNuevaProgramacionHandler(sim.scheduler)(c)
+
+			if w.Code != caso.estado {
+				t.Fatalf("estado %d, se esperaba %d: %s", w.Code, caso.estado, w.Body.String())
+			}
+			if caso.estado != http.StatusOK {
+				return
+			}
+
+			var vista VistaPreviaProgramacion
+			if err := json.Unmarshal(w.Body.Bytes(), &vista); err != nil {
+				t.Fatalf("respuesta inválida: %v", err)
+			}
+			compararFechas(t, fechasVistaPrevia(&vista, time.UTC), caso.ocurrencias)
+			if vista.Truncada != caso.truncada {
+				t.Errorf("truncada %v, se esperaba %v", vista.Truncada, caso.truncada)
+			}
+		})
+	}
+}
@@ -2,68 +2,389 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
-// SchedulerService maneja la programación automática de publicaciones
+const (
+	// schedulerLockTTL es la vigencia del lock de líder; la instancia líder lo
+	// renueva cada tercio de este tiempo
+	schedulerLockTTL = 90 * time.Second
+
+	// jobLease es el tiempo que un worker retiene un trabajo antes de que
+	// otro pueda retomarlo; el worker lo renueva cada tercio de este tiempo
+	// mientras publica
+	jobLease = 5 * time.Minute
+
+	// jobPollInterval es la espera de un worker cuando la cola está vacía
+	jobPollInterval = 5 * time.Second
+
+	// maxBackoff es la espera máxima entre reintentos de un trabajo
+	maxBackoff = 30 * time.Minute
+)
+
+// errPublicacionInterrumpida se registra en el historial para las
+// publicaciones que seguían en curso al agotarse la espera del apagado
+var errPublicacionInterrumpida = errors.New("publicación interrumpida por el apagado del servidor")
+
+// SchedulerService maneja la programación automática de publicaciones. Con
+// varias réplicas del backend solo la instancia que tiene el lock de líder
+// encola las programaciones; los workers de todas las instancias procesan la
+// cola de publicaciones.
 type SchedulerService struct {
-	authService     *AuthService
-	facebookService *FacebookService
-	running         bool
-	stopChan        chan bool
+	clock           Clock
+	usuarios        UsuarioRepository
+	facebook        FacebookClient
+	lock            LeaderElector
+	queue           Queue
+	ledger          Ledger
+	programaciones  ProgramacionRepository
+	publicaciones   PublicacionRepository
+	productos       ProductoRepository
+	grupos          GrupoRepository
+	historial       HistorialRepository
+	eventos         EventoRepository
+	notificaciones  NotificacionRepository
+	limites         *LimitesPublicacion
+	workers         int
+	maxIntentos     int
+	backoffBase     time.Duration
+	minimoPromocion int
+	renovarTokens   time.Duration // Anticipación con la que se renuevan los tokens de Facebook
+	renovarLease    time.Duration // Cada cuánto se renueva el lease del trabajo en curso
+	leader          atomic.Bool
+	running         atomic.Bool
+	stopChan        chan struct{}
+	wg              sync.WaitGroup // Loop principal y workers
+	enCursoMu       sync.Mutex
+	enCurso         map[primitive.ObjectID]TrabajoPublicacion // Trabajos que los workers están publicando
+	interrumpidos   map[primitive.ObjectID]primitive.ObjectID // Registro "interrumpida" del historial de cada trabajo
+	tickMu          sync.Mutex                                // Evita que un tick manual se superponga con el del loop
+	ultimoTick      atomic.Int64                              // UnixNano del inicio del último tick
+	duracionTick    atomic.Int64                              // Duración del último tick en nanosegundos
+	eventosChan     chan struct{}                             // Avisa que hay eventos de productos nuevos
+	liderChan       chan struct{}                             // Avisa que la instancia acaba de ser elegida líder
+}
+
+// SchedulerDeps agrupa las dependencias del scheduler. NewSchedulerService
+// usa las implementaciones sobre MongoDB y la Graph API; las pruebas las
+// reemplazan por implementaciones en memoria y un reloj simulado.
+type SchedulerDeps struct {
+	Clock          Clock
+	Usuarios       UsuarioRepository
+	Facebook       FacebookClient
+	Lock           LeaderElector
+	Queue          Queue
+	Ledger         Ledger
+	Programaciones ProgramacionRepository
+	Publicaciones  PublicacionRepository
+	Productos      ProductoRepository
+	Grupos         GrupoRepository
+	Historial      HistorialRepository
+	Eventos        EventoRepository
+	Notificaciones NotificacionRepository
 }
 
 func NewSchedulerService(authService *AuthService, facebookService *FacebookService) *SchedulerService {
+	clock := relojSistema{}
+
+	return NewSchedulerServiceWith(SchedulerDeps{
+		Clock:          clock,
+		Usuarios:       authService,
+		Facebook:       facebookService,
+		Lock:           NewLeaderLock("scheduler", schedulerLockTTL, clock),
+		Queue:          NewJobQueue(jobLease, clock),
+		Ledger:         NewRunLedger(clock),
+		Programaciones: newMongoProgramaciones(),
+		Publicaciones:  newMongoPublicaciones(),
+		Productos:      newMongoProductos(),
+		Grupos:         newMongoGrupos(),
+		Historial:      newMongoHistorial(),
+		Eventos:        newMongoEventos(),
+		Notificaciones: newMongoNotificaciones(),
+	})
+}
+
+// NewSchedulerServiceWith crea el scheduler con las dependencias indicadas;
+// la configuración se lee del entorno igual que en NewSchedulerService
+func NewSchedulerServiceWith(deps SchedulerDeps) *SchedulerService {
 	return &SchedulerService{
-		authService:     authService,
-		facebookService: facebookService,
-		running:         false,
-		stopChan:        make(chan bool),
+		clock:           deps.Clock,
+		usuarios:        deps.Usuarios,
+		facebook:        deps.Facebook,
+		lock:            deps.Lock,
+		queue:           deps.Queue,
+		ledger:          deps.Ledger,
+		programaciones:  deps.Programaciones,
+		publicaciones:   deps.Publicaciones,
+		productos:       deps.Productos,
+		grupos:          deps.Grupos,
+		historial:       deps.Historial,
+		eventos:         deps.Eventos,
+		notificaciones:  deps.Notificaciones,
+		limites:         NewLimitesPublicacion(deps.Historial, deps.Grupos, deps.Clock),
+		workers:         envInt("SCHEDULER_WORKERS", 3),
+		maxIntentos:     envInt("SCHEDULER_MAX_INTENTOS", 5),
+		backoffBase:     time.Duration(envInt("SCHEDULER_BACKOFF_SEGUNDOS", 30)) * time.Second,
+		minimoPromocion: envInt("VARIANTES_MINIMO_PUBLICACIONES", 5),
+		renovarTokens:   time.Duration(envInt("FACEBOOK_RENOVAR_TOKEN_DIAS", 7)) * 24 * time.Hour,
+		renovarLease:    jobLease / 3,
+		enCurso:         make(map[primitive.ObjectID]TrabajoPublicacion),
+		interrumpidos:   make(map[primitive.ObjectID]primitive.ObjectID),
+		eventosChan:     make(chan struct{}, 1),
+		liderChan:       make(chan struct{}, 1),
 	}
 }
 
 // Start inicia el servicio de programación
 func (s *SchedulerService) Start() {
-	if s.running {
+	if s.running.Load() {
 		return
 	}
 
-	s.running = true
-	log.Println("Servicio de programación iniciado")
+	s.running.Store(true)
+	s.stopChan = make(chan struct{})
+	log.Printf("Servicio de programación iniciado (instancia %s)", s.lock.Instancia())
 
+	s.wg.Add(1)
 	go s.run()
 }
 
-// Stop detiene el servicio de programación
-func (s *SchedulerService) Stop() {
-	if !s.running {
+// Stop detiene el servicio de programación: deja de encolar programaciones y
+// de tomar trabajos, y espera a que terminen las publicaciones en curso. Si
+// ctx vence antes, las publicaciones que siguen en curso se registran como
+// "interrumpida" en el historial hasta que terminen; si el proceso se cierra
+// antes, sus trabajos se retoman al vencer el lease.
+func (s *SchedulerService) Stop(ctx context.Context) {
+	if !s.running.Load() {
 		return
 	}
 
-	s.running = false
-	s.stopChan <- true
-	log.Println("Servicio de programación detenido")
+	s.running.Store(false)
+	close(s.stopChan)
+
+	terminado := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(terminado)
+	}()
+
+	select {
+	case <-terminado:
+		log.Println("Servicio de programación detenido")
+	case <-ctx.Done():
+		interrumpidas := s.interruptInFlight()
+		log.Printf("Servicio de programación detenido con %d publicaciones interrumpidas", interrumpidas)
+	}
 }
 
 // run ejecuta el loop principal del scheduler
 func (s *SchedulerService) run() {
+	defer s.wg.Done()
+
 	ticker := time.NewTicker(1 * time.Minute) // Verificar cada minuto
 	defer ticker.Stop()
 
+	metricas := time.NewTicker(engagementInterval)
+	defer metricas.Stop()
+
+	renovacion := time.NewTicker(renovacionTokensInterval)
+	defer renovacion.Stop()
+
+	// El lock se renueva en su propia goroutine para no vencer mientras un
+	// ciclo largo del scheduler está en curso
+	done := make(chan struct{})
+	go s.heartbeat(done)
+	s.wg.Add(s.workers)
+	for i := 0; i < s.workers; i++ {
+		go s.worker(done)
+	}
+	defer func() {
+		close(done)
+		s.leader.Store(false)
+		if err := s.lock.Release(); err != nil {
+			log.Printf("Error liberando lock del scheduler: %v", err)
+		}
+	}()
+
 	for {
 		select {
 		case <-ticker.C:
-			s.processPendingPublications()
+			if s.leader.Load() {
+				s.Tick()
+			}
+		case <-s.eventosChan:
+			if s.leader.Load() {
+				s.ProcessEvents()
+			}
+		case <-metricas.C:
+			if s.leader.Load() {
+				s.collectEngagement()
+			}
+		case <-renovacion.C:
+			if s.leader.Load() {
+				s.renewFacebookTokens()
+			}
+		case <-s.liderChan:
+			// Los reinicios y cambios de líder pueden ser más frecuentes que
+			// los intervalos de las métricas y de la renovación
+			if s.leader.Load() {
+				s.collectEngagement()
+				s.renewFacebookTokens()
+			}
 		case <-s.stopChan:
 			return
 		}
 	}
 }
 
+// heartbeat intenta obtener o renovar el lock de líder periódicamente
+func (s *SchedulerService) heartbeat(done chan struct{}) {
+	ticker := time.NewTicker(schedulerLockTTL / 3)
+	defer ticker.Stop()
+
+	for {
+		s.renewLeadership()
+
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+	}
+}
+
+// worker procesa trabajos de la cola hasta que se detiene el scheduler; el
+// trabajo que está publicando se termina antes de salir
+func (s *SchedulerService) worker(done chan struct{}) {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		trabajo, err := s.queue.Claim(s.lock.Instancia())
+		if err != nil {
+			log.Printf("Error tomando trabajo de la cola: %v", err)
+		} else if trabajo != nil {
+			s.runJob(*trabajo)
+			continue
+		}
+
+		select {
+		case <-done:
+			return
+		case <-time.After(jobPollInterval):
+		}
+	}
+}
+
+// runJob procesa un trabajo tomado de la cola y renueva su lease mientras
+// se publica: un álbum con muchas fotos puede tardar más que el lease
+func (s *SchedulerService) runJob(trabajo TrabajoPublicacion) {
+	s.trackJob(trabajo)
+
+	done := make(chan struct{})
+	renovado := make(chan struct{})
+	go func() {
+		defer close(renovado)
+		s.keepLease(trabajo, done)
+	}()
+
+	s.processJob(trabajo)
+	close(done)
+	<-renovado
+}
+
+// keepLease renueva el lease del trabajo hasta que se cierra done
+func (s *SchedulerService) keepLease(trabajo TrabajoPublicacion, done chan struct{}) {
+	ticker := time.NewTicker(s.renovarLease)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.queue.Extend(trabajo); err != nil {
+				log.Printf("Error renovando el lease del trabajo %s: %v", trabajo.ID.Hex(), err)
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// trackJob registra que un worker está publicando el trabajo; settleJob lo
+// quita al terminar
+func (s *SchedulerService) trackJob(trabajo TrabajoPublicacion) {
+	s.enCursoMu.Lock()
+	defer s.enCursoMu.Unlock()
+
+	s.enCurso[trabajo.ID] = trabajo
+}
+
+// settleJob quita el trabajo de los que están en curso y devuelve el ID del
+// registro "interrumpida" que el apagado guardó para él, o NilObjectID si
+// no fue interrumpido
+func (s *SchedulerService) settleJob(trabajo TrabajoPublicacion) primitive.ObjectID {
+	s.enCursoMu.Lock()
+	defer s.enCursoMu.Unlock()
+
+	delete(s.enCurso, trabajo.ID)
+	interrumpida := s.interrumpidos[trabajo.ID]
+	delete(s.interrumpidos, trabajo.ID)
+	return interrumpida
+}
+
+// interruptInFlight registra en el historial los trabajos que siguen en curso
+// y devuelve cuántos eran. Si la publicación termina antes de que se cierre
+// el proceso, su resultado reemplaza al registro "interrumpida"; si no, el
+// trabajo se retoma al vencer el lease.
+func (s *SchedulerService) interruptInFlight() int {
+	// El lock se mantiene mientras se guardan los registros para que un
+	// worker que termina no intente reemplazarlos antes
+	s.enCursoMu.Lock()
+	defer s.enCursoMu.Unlock()
+
+	for id, trabajo := range s.enCurso {
+		log.Printf("Trabajo %s interrumpido por el apagado", trabajo.ID.Hex())
+		s.interrumpidos[id] = s.saveHistorial(trabajo, nil, errPublicacionInterrumpida, primitive.NilObjectID)
+	}
+	interrumpidas := len(s.enCurso)
+	clear(s.enCurso)
+	return interrumpidas
+}
+
+// renewLeadership actualiza si esta instancia es la líder del scheduler
+func (s *SchedulerService) renewLeadership() {
+	leader, err := s.lock.Acquire()
+	if err != nil {
+		log.Printf("Error renovando lock del scheduler: %v", err)
+		leader = false
+	}
+
+	if previo := s.leader.Swap(leader); previo != leader {
+		if leader {
+			log.Printf("Instancia %s es ahora líder del scheduler", s.lock.Instancia())
+			select {
+			case s.liderChan <- struct{}{}:
+			default:
+			}
+		} else {
+			log.Printf("Instancia %s dejó de ser líder del scheduler", s.lock.Instancia())
+		}
+	}
+}
+
 // processPendingPublications procesa las publicaciones pendientes
 func (s *SchedulerService) processPendingPublications() {
 	// Obtener programaciones activas
@@ -74,172 +395,530 @@ func (s *SchedulerService) processPendingPublications() {
 	}
 
 	for _, prog := range programaciones {
-		// Verificar si es hora de publicar
-		if s.shouldPublishNow(prog) {
-			s.executePublication(prog)
+		// Las programaciones con disparador publican ante eventos de productos
+		if prog.Disparador != nil {
+			continue
 		}
+		s.processProgramacion(prog)
 	}
 }
 
-// getActiveProgramaciones obtiene las programaciones activas
-func (s *SchedulerService) getActiveProgramaciones() ([]ProgramacionPublicacion, error) {
-	collection := database.Collection("programaciones")
+// processProgramacion ejecuta la programación si le corresponde publicar y la
+// marca como completada al agotar su cantidad de publicaciones o su fecha de fin
+func (s *SchedulerService) processProgramacion(prog ProgramacionPublicacion) {
+	realizadas, err := s.countSuccessfulRuns(prog.ID)
+	if err != nil {
+		log.Printf("Error contando publicaciones de %s: %v", prog.ID.Hex(), err)
+		return
+	}
 
-	filter := bson.M{
-		"estado":       "activa",
-		"fecha_inicio": bson.M{"$lte": time.Now()},
-		"$or": []bson.M{
-			{"fecha_fin": bson.M{"$gte": time.Now()}},
-			{"fecha_fin": nil},
-		},
+	if cuotaAlcanzada(prog, realizadas) {
+		s.completeProgramacion(prog, "cantidad de publicaciones alcanzada")
+		return
 	}
 
-	cursor, err := collection.Find(context.Background(), filter)
+	horario, err := s.nextPublicationTime(prog)
 	if err != nil {
-		return nil, err
+		log.Printf("Error calculando siguiente publicación de %s: %v", prog.ID.Hex(), err)
+		return
 	}
-	defer cursor.Close(context.Background())
 
-	var programaciones []ProgramacionPublicacion
-	if err := cursor.All(context.Background(), &programaciones); err != nil {
-		return nil, err
+	if horario.IsZero() || (prog.FechaFin != nil && horario.After(*prog.FechaFin)) {
+		s.completeProgramacion(prog, "fecha de fin alcanzada")
+		return
 	}
 
-	return programaciones, nil
+	// Verificar si es hora de publicar
+	now := s.clock.Now()
+	if now.Before(horario) {
+		return
+	}
+
+	restantes, err := s.remainingRuns(prog, realizadas)
+	if err != nil {
+		log.Printf("Error contando ejecuciones en curso de %s: %v", prog.ID.Hex(), err)
+		return
+	}
+
+	s.catchUp(prog, horario, now, restantes)
+}
+
+// remainingRuns devuelve las publicaciones que le quedan a la programación, o
+// -1 si no tiene límite. Las ejecuciones en curso reservan su lugar en la
+// cantidad aunque todavía no hayan terminado.
+func (s *SchedulerService) remainingRuns(prog ProgramacionPublicacion, realizadas int) (int, error) {
+	if prog.CantidadPublicaciones <= 0 {
+		return -1, nil
+	}
+
+	enCurso, err := s.ledger.CountInProgress(prog.ID)
+	if err != nil {
+		return 0, err
+	}
+	return max(prog.CantidadPublicaciones-realizadas-enCurso, 0), nil
+}
+
+// cuotaAlcanzada indica si la programación ya realizó todas sus publicaciones;
+// una cantidad de 0 significa sin límite
+func cuotaAlcanzada(prog ProgramacionPublicacion, realizadas int) bool {
+	return prog.CantidadPublicaciones > 0 && realizadas >= prog.CantidadPublicaciones
 }
 
-// shouldPublishNow verifica si una programación debe ejecutarse ahora
-func (s *SchedulerService) shouldPublishNow(prog ProgramacionPublicacion) bool {
-	now := time.Now()
+// completeProgramacion marca la programación como completada solo si sigue activa
+func (s *SchedulerService) completeProgramacion(prog ProgramacionPublicacion, motivo string) {
+	completada, err := s.programaciones.Complete(prog.ID, s.clock.Now())
+	if err != nil {
+		log.Printf("Error completando programación %s: %v", prog.ID.Hex(), err)
+		return
+	}
 
-	// Obtener la última publicación de esta programación
+	if completada {
+		log.Printf("Programación %s completada: %s", prog.ID.Hex(), motivo)
+	}
+}
+
+// countSuccessfulRuns cuenta las ejecuciones exitosas de una programación
+func (s *SchedulerService) countSuccessfulRuns(programacionID primitive.ObjectID) (int, error) {
+	conteos, err := s.ledger.CountSuccessful([]primitive.ObjectID{programacionID})
+	if err != nil {
+		return 0, err
+	}
+	return conteos[programacionID], nil
+}
+
+// getActiveProgramaciones obtiene las programaciones activas
+func (s *SchedulerService) getActiveProgramaciones() ([]ProgramacionPublicacion, error) {
+	return s.programaciones.FindActive(s.clock.Now())
+}
+
+// nextPublicationTime devuelve el siguiente horario planificado de la
+// programación a partir de la última ejecución registrada. Los horarios que
+// cayeron mientras la programación estuvo pausada no se recuperan.
+func (s *SchedulerService) nextPublicationTime(prog ProgramacionPublicacion) (time.Time, error) {
+	horario, err := s.nextScheduledTime(prog)
+	if err != nil || horario.IsZero() || prog.ReanudadaEn == nil || !horario.Before(*prog.ReanudadaEn) {
+		return horario, err
+	}
+
+	return s.calculateNextPublicationTime(prog, prog.ReanudadaEn.Add(-time.Nanosecond))
+}
+
+// nextScheduledTime devuelve el horario siguiente a la última ejecución registrada
+func (s *SchedulerService) nextScheduledTime(prog ProgramacionPublicacion) (time.Time, error) {
+	lastRun, err := s.ledger.Last(prog.ID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if lastRun != nil {
+		return s.calculateNextPublicationTime(prog, lastRun.FechaProgramada)
+	}
+
+	// Programaciones anteriores al registro de ejecuciones: obtener la última publicación
 	lastPublication, err := s.getLastPublication(prog.ID)
 	if err != nil {
-		log.Printf("Error obteniendo última publicación: %v", err)
-		return false
+		return time.Time{}, err
 	}
 
-	var nextPublicationTime time.Time
 	if lastPublication == nil {
-		// Primera publicación
-		nextPublicationTime = prog.FechaInicio
-	} else {
-		// Calcular siguiente publicación basada en la frecuencia
-		nextPublicationTime = s.calculateNextPublicationTime(lastPublication.FechaPublicacion, prog.Frecuencia)
+		// Primera publicación: el primer horario a partir de la fecha de inicio
+		return s.calculateNextPublicationTime(prog, prog.FechaInicio.Add(-time.Nanosecond))
+	}
+
+	// Siguiente horario posterior al último que se ejecutó
+	lastTime := lastPublication.FechaProgramada
+	if lastTime.IsZero() {
+		// Registros anteriores a fecha_programada
+		lastTime = lastPublication.FechaPublicacion
 	}
 
-	// Verificar si es hora de publicar (con margen de 1 minuto)
-	return now.After(nextPublicationTime) || now.Sub(nextPublicationTime).Abs() < time.Minute
+	return s.calculateNextPublicationTime(prog, lastTime)
 }
 
-// calculateNextPublicationTime calcula el momento de la siguiente publicación
-func (s *SchedulerService) calculateNextPublicationTime(lastTime time.Time, frecuencia string) time.Time {
-	switch frecuencia {
-	case "diaria":
-		return lastTime.Add(24 * time.Hour)
-	case "cada_2_dias":
-		return lastTime.Add(48 * time.Hour)
-	case "semanal":
-		return lastTime.Add(7 * 24 * time.Hour)
-	case "cada_2_semanas":
-		return lastTime.Add(14 * 24 * time.Hour)
-	default:
-		return lastTime.Add(24 * time.Hour) // Por defecto diaria
+// calculateNextPublicationTime calcula el primer horario de la recurrencia
+// de la programación estrictamente posterior a after
+func (s *SchedulerService) calculateNextPublicationTime(prog ProgramacionPublicacion, after time.Time) (time.Time, error) {
+	recurrencia, err := s.recurrenciaFor(prog)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return recurrencia.Next(after), nil
+}
+
+// recurrenciaFor construye la recurrencia de la programación. Los horarios se
+// interpretan en la zona horaria de la programación, por lo que un horario de
+// las 09:00 se mantiene a las 09:00 locales al cambiar el horario de verano.
+func (s *SchedulerService) recurrenciaFor(prog ProgramacionPublicacion) (Recurrencia, error) {
+	return ParseRecurrencia(prog, s.locationFor(prog))
+}
+
+// locationFor resuelve la zona horaria de la programación o de su usuario
+func (s *SchedulerService) locationFor(prog ProgramacionPublicacion) *time.Location {
+	if prog.ZonaHoraria != "" {
+		return zonaHorariaProgramacion(prog, nil)
 	}
+
+	return zonaHorariaProgramacion(prog, s.ownerOf(prog))
 }
 
-// getLastPublication obtiene la última publicación de una programación
+// calendarFor combina los horarios de silencio y las fechas bloqueadas del
+// usuario y de la programación
+func (s *SchedulerService) calendarFor(prog ProgramacionPublicacion) *calendarioBloqueos {
+	usuario := s.ownerOf(prog)
+	if usuario == nil {
+		return nuevoCalendarioBloqueos(zonaHorariaProgramacion(prog, nil), prog.Restricciones)
+	}
+
+	return nuevoCalendarioBloqueos(zonaHorariaProgramacion(prog, usuario), usuario.Restricciones, prog.Restricciones)
+}
+
+// ownerOf obtiene el usuario propietario de la programación, o nil si no existe
+func (s *SchedulerService) ownerOf(prog ProgramacionPublicacion) *Usuario {
+	if prog.UserID.IsZero() {
+		return nil
+	}
+
+	usuario, err := s.usuarios.GetUserByID(prog.UserID.Hex())
+	if err != nil {
+		return nil
+	}
+
+	return usuario
+}
+
+// getLastPublication obtiene la última publicación planificada de una programación
 func (s *SchedulerService) getLastPublication(programacionID primitive.ObjectID) (*HistorialPublicacion, error) {
-	collection := database.Collection("historial_publicaciones")
+	return s.historial.LastScheduled(programacionID)
+}
 
-	filter := bson.M{"programacion_id": programacionID}
+// enqueuePublication encola un trabajo por cada grupo objetivo para el
+// horario indicado; los trabajos no se ejecutan antes de disponibleDesde
+func (s *SchedulerService) enqueuePublication(prog ProgramacionPublicacion, horario, disponibleDesde time.Time) {
+	log.Printf("Encolando publicación programada: %s (%s)", prog.ID.Hex(), horario.In(s.locationFor(prog)).Format(time.RFC3339))
 
-	var historial HistorialPublicacion
-	err := collection.FindOne(context.Background(), filter).Decode(&historial)
+	if err := s.enqueueRun(prog, horario, disponibleDesde, false); err != nil {
+		log.Printf("Error encolando publicación de %s: %v", prog.ID.Hex(), err)
+	}
+}
+
+// enqueueRun registra la ejecución de un horario con la publicación que le
+// toca según la rotación y encola sus trabajos
+func (s *SchedulerService) enqueueRun(prog ProgramacionPublicacion, horario, disponibleDesde time.Time, manual bool) error {
+	// Obtener los grupos objetivo
+	grupos, err := s.getGruposObjetivo(prog.GruposObjetivo)
 	if err != nil {
-		if err.Error() == "mongo: no documents in result" {
-			return nil, nil // No hay publicaciones previas
+		return fmt.Errorf("error obteniendo grupos: %v", err)
+	}
+
+	if prog.PublicacionID, err = s.choosePublicacion(prog); err != nil {
+		return fmt.Errorf("error eligiendo publicación: %v", err)
+	}
+
+	// El registro de la ejecución marca el horario como tomado aunque el
+	// encolado se repita tras una caída
+	registrado, err := s.ledger.Start(prog, horario, grupos, manual)
+	if err != nil {
+		return fmt.Errorf("error registrando ejecución: %v", err)
+	}
+	if !registrado {
+		// Reencolado tras una caída: conservar la publicación elegida entonces
+		existente, err := s.ledger.Get(prog.ID, horario)
+		if err != nil {
+			return fmt.Errorf("error obteniendo ejecución: %v", err)
+		}
+		if existente.Estado == EjecucionOmitida {
+			return nil
+		}
+		prog.PublicacionID = existente.PublicacionID
+	}
+
+	// Los grupos de un mismo horario se publican espaciados
+	disponibles := s.limites.escalonar(disponibleDesde, len(grupos))
+
+	variantes, err := s.chooseVariantes(prog.PublicacionID, grupos)
+	if err != nil {
+		return fmt.Errorf("error eligiendo variantes: %v", err)
+	}
+
+	trabajos := make([]TrabajoPublicacion, len(grupos))
+	for i, grupo := range grupos {
+		trabajos[i] = TrabajoPublicacion{
+			ProgramacionID:  prog.ID,
+			PublicacionID:   prog.PublicacionID,
+			GrupoID:         grupo.ID,
+			UserID:          prog.UserID,
+			FechaProgramada: horario,
+			DisponibleDesde: disponibles[i],
+			Manual:          manual,
+			VarianteID:      variantes[grupo.ID],
 		}
-		return nil, err
 	}
 
-	return &historial, nil
+	nuevos, err := s.queue.Enqueue(trabajos)
+	if err != nil {
+		return fmt.Errorf("error encolando trabajos: %v", err)
+	}
+
+	if nuevos > 0 {
+		log.Printf("Encolados %d trabajos para la programación %s", nuevos, prog.ID.Hex())
+	}
+	return nil
 }
 
-// executePublication ejecuta una publicación programada
-func (s *SchedulerService) executePublication(prog ProgramacionPublicacion) {
-	log.Printf("Ejecutando publicación programada: %s", prog.ID.Hex())
+// choosePublicacion elige la publicación del próximo horario según la
+// rotación de la programación y las publicaciones de sus últimas ejecuciones
+func (s *SchedulerService) choosePublicacion(prog ProgramacionPublicacion) (primitive.ObjectID, error) {
+	publicaciones := publicacionesDe(prog)
+	if len(publicaciones) == 1 {
+		return publicaciones[0].PublicacionID, nil
+	}
 
-	// Obtener la publicación
-	publicacion, err := s.getPublicacion(prog.PublicacionID)
+	recientes, err := s.ledger.RecentPublicaciones(prog.ID, len(publicaciones))
 	if err != nil {
-		log.Printf("Error obteniendo publicación: %v", err)
-		return
+		return primitive.NilObjectID, err
 	}
 
-	// Obtener los grupos objetivo
-	grupos, err := s.getGruposObjetivo(prog.GruposObjetivo)
+	realizadas, err := s.ledger.CountPublished(prog.ID)
 	if err != nil {
-		log.Printf("Error obteniendo grupos: %v", err)
+		return primitive.NilObjectID, err
+	}
+
+	return elegirPublicacion(prog, recientes, realizadas), nil
+}
+
+// processJob ejecuta un trabajo de la cola. Los errores transitorios se
+// reintentan con backoff exponencial hasta agotar los intentos y los trabajos
+// frenados por el intervalo mínimo del grupo se posponen; el resultado final
+// queda registrado en el historial. Si Facebook rechaza el token del usuario,
+// el token se marca vencido y sus demás trabajos fallan sin llamar a la API.
+func (s *SchedulerService) processJob(trabajo TrabajoPublicacion) {
+	response, err := s.executeJob(trabajo)
+	interrumpida := s.settleJob(trabajo)
+
+	var diferido *errorDiferido
+	if errors.As(err, &diferido) {
+		log.Printf("Trabajo %s pospuesto hasta %s: %s", trabajo.ID.Hex(), diferido.hasta.Format(time.RFC3339), diferido.motivo)
+		if err := s.queue.Defer(trabajo, diferido.hasta, diferido.motivo); err != nil {
+			log.Printf("Error posponiendo trabajo %s: %v", trabajo.ID.Hex(), err)
+		}
+		return
+	}
+
+	if err != nil && esErrorTransitorio(err) && trabajo.Intentos < s.maxIntentos {
+		espera := backoffConJitter(trabajo.Intentos, s.backoffBase, maxBackoff)
+		log.Printf("Trabajo %s falló (intento %d de %d), reintentando en %s: %v", trabajo.ID.Hex(), trabajo.Intentos, s.maxIntentos, espera.Round(time.Second), err)
+		if err := s.queue.Retry(trabajo, s.clock.Now().Add(espera), err.Error()); err != nil {
+			log.Printf("Error reprogramando trabajo %s: %v", trabajo.ID.Hex(), err)
+		}
 		return
 	}
 
+	if graphErr := comoGraphError(err); graphErr != nil && graphErr.IsTokenExpired() {
+		log.Printf("Facebook rechazó el token del usuario %s, se marca vencido: %v", trabajo.UserID.Hex(), graphErr)
+		if err := s.usuarios.ExpireFacebookToken(trabajo.UserID.Hex(), s.clock.Now()); err != nil {
+			log.Printf("Error marcando vencido el token del usuario %s: %v", trabajo.UserID.Hex(), err)
+		}
+	}
+
+	// La reserva del grupo solo debe contar si el trabajo publicó
+	if err != nil {
+		if releaseErr := s.limites.release(trabajo); releaseErr != nil {
+			log.Printf("Error liberando la reserva del grupo del trabajo %s: %v", trabajo.ID.Hex(), releaseErr)
+		}
+	}
+
+	if !interrumpida.IsZero() {
+		log.Printf("Trabajo %s registrado como interrumpido terminó después del apagado", trabajo.ID.Hex())
+	}
+	s.saveHistorial(trabajo, response, err, interrumpida)
+
+	if ledgerErr := s.ledger.RecordGroupResult(trabajo, idPublicacionFeed(response), err); ledgerErr != nil {
+		log.Printf("Error registrando resultado del trabajo %s: %v", trabajo.ID.Hex(), ledgerErr)
+	}
+
+	var omitido *errorOmitido
+	switch {
+	case errors.As(err, &omitido):
+		log.Printf("Trabajo %s omitido: %s", trabajo.ID.Hex(), omitido.motivo)
+		err = s.queue.Ack(trabajo, TrabajoOmitido, omitido.motivo)
+	case err != nil:
+		log.Printf("Trabajo %s fallido tras %d intentos: %v", trabajo.ID.Hex(), trabajo.Intentos, err)
+		err = s.queue.Ack(trabajo, TrabajoFallido, err.Error())
+	default:
+		err = s.queue.Ack(trabajo, TrabajoCompletado, "")
+	}
+
+	if err != nil {
+		log.Printf("Error confirmando trabajo %s: %v", trabajo.ID.Hex(), err)
+	}
+}
+
+// executeJob publica la publicación del trabajo en su grupo
+func (s *SchedulerService) executeJob(trabajo TrabajoPublicacion) (*FacebookPostResponse, error) {
+	prog, err := s.getProgramacion(trabajo.ProgramacionID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, permanente(errors.New("la programación ya no existe"))
+		}
+		return nil, fmt.Errorf("error obteniendo programación: %v", err)
+	}
+
+	// Las publicaciones manuales se piden explícitamente aunque la programación esté pausada
+	if prog.Estado != "activa" && !trabajo.Manual {
+		return nil, permanente(fmt.Errorf("la programación está %s", prog.Estado))
+	}
+
+	// Los reintentos, los diferimientos y el espaciado pueden llevar el trabajo
+	// a un horario de silencio o una fecha bloqueada posterior a su encolado
+	if !trabajo.Manual {
+		now := s.clock.Now()
+		permitido, motivo := s.calendarFor(*prog).siguientePermitido(now)
+		if permitido.IsZero() {
+			return nil, omitir("%s: no hay un horario permitido para publicar", motivo)
+		}
+		if permitido.After(now) {
+			return nil, &errorDiferido{hasta: permitido, motivo: motivo}
+		}
+	}
+
+	// Obtener la publicación
+	publicacion, err := s.getPublicacion(trabajo.PublicacionID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, permanente(errors.New("la publicación ya no existe"))
+		}
+		return nil, fmt.Errorf("error obteniendo publicación: %v", err)
+	}
+	*publicacion = aplicarVariante(*publicacion, trabajo.VarianteID)
+
+	// Obtener el grupo objetivo
+	grupos, err := s.getGruposObjetivo([]primitive.ObjectID{trabajo.GrupoID})
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo grupo: %v", err)
+	}
+	if len(grupos) == 0 {
+		return nil, permanente(errors.New("el grupo ya no existe"))
+	}
+
 	// Obtener el usuario propietario de la programación
-	usuario, err := s.authService.GetUserByID(prog.UserID.Hex())
+	usuario, err := s.usuarios.GetUserByID(trabajo.UserID.Hex())
 	if err != nil {
-		log.Printf("Error obteniendo usuario: %v", err)
-		return
+		return nil, fmt.Errorf("error obteniendo usuario: %v", err)
 	}
 
 	// Verificar que el usuario tenga token de Facebook válido
-	if usuario.FacebookAccessToken == "" || time.Now().After(usuario.TokenExpiracion) {
-		log.Printf("Token de Facebook inválido para usuario %s", usuario.ID.Hex())
-		return
+	if usuario.FacebookAccessToken == "" || s.clock.Now().After(usuario.TokenExpiracion) {
+		return nil, permanente(fmt.Errorf("token de Facebook inválido para usuario %s", usuario.ID.Hex()))
 	}
 
-	// Publicar en cada grupo
-	for _, grupo := range grupos {
-		s.publishToGroup(prog, *publicacion, grupo, usuario.FacebookAccessToken)
+	// Esperar si la cuota de llamadas a la Graph API está por agotarse
+	if espera, motivo := s.facebook.UsageWait(usuario.FacebookAccessToken, s.clock.Now()); espera > 0 {
+		return nil, &errorDiferido{hasta: s.clock.Now().Add(espera), motivo: motivo}
 	}
+
+	// Límites anti-spam por grupo y por cuenta
+	if err := s.limites.check(trabajo, grupos[0], zonaHorariaProgramacion(*prog, usuario)); err != nil {
+		return nil, err
+	}
+
+	return s.publishToGroup(*publicacion, grupos[0], usuario.FacebookAccessToken)
 }
 
 // publishToGroup publica en un grupo específico
-func (s *SchedulerService) publishToGroup(prog ProgramacionPublicacion, publicacion Publicacion, grupo GrupoFacebook, accessToken string) {
+func (s *SchedulerService) publishToGroup(publicacion Publicacion, grupo GrupoFacebook, accessToken string) (*FacebookPostResponse, error) {
+	productos, err := s.productosDe(publicacion)
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo productos: %v", err)
+	}
+	fotos := fotosPublicacion(publicacion, productos)
+
 	// Crear el mensaje de publicación
 	postReq := FacebookPostRequest{
 		Message: s.buildMessage(publicacion),
-		Link:    publicacion.ImagenURL,
 	}
 
-	// Publicar en Facebook
-	response, err := s.facebookService.PostToGroup(accessToken, grupo.FacebookID, postReq)
+	// Con varias fotos se publica un álbum y con una sola, una foto con el
+	// mensaje como descripción
+	var response *FacebookPostResponse
+	switch len(fotos) {
+	case 0:
+		response, err = s.facebook.PostToGroup(accessToken, grupo.FacebookID, postReq)
+	case 1:
+		postReq.ImageURL = fotos[0].URL
+		response, err = s.facebook.PostPhotoFromURL(accessToken, grupo.FacebookID, postReq)
+	default:
+		response, err = s.facebook.PostAlbum(accessToken, grupo.FacebookID, postReq.Message, fotos)
+	}
+	if err != nil {
+		log.Printf("Error publicando en grupo %s: %v", grupo.Nombre, err)
+		return nil, err
+	}
+
+	log.Printf("Publicación exitosa en grupo %s: %s", grupo.Nombre, idPublicacionFeed(response))
+	return response, nil
+}
+
+// idPublicacionFeed devuelve el ID de la publicación en el feed del grupo; al
+// publicar una foto es el post_id de la respuesta y no el ID de la foto
+func idPublicacionFeed(response *FacebookPostResponse) string {
+	if response == nil {
+		return ""
+	}
+	if response.PostID != "" {
+		return response.PostID
+	}
+	return response.ID
+}
+
+// saveHistorial registra el resultado final de un trabajo en el historial y
+// devuelve el ID del registro. Si reemplazar no es NilObjectID, el resultado
+// reemplaza a ese registro en lugar de agregar uno nuevo.
+func (s *SchedulerService) saveHistorial(trabajo TrabajoPublicacion, response *FacebookPostResponse, err error, reemplazar primitive.ObjectID) primitive.ObjectID {
+	id := reemplazar
+	if id.IsZero() {
+		id = primitive.NewObjectID()
+	}
 
-	// Registrar en el historial
 	historial := HistorialPublicacion{
-		ID:               primitive.NewObjectID(),
-		ProgramacionID:   prog.ID,
-		PublicacionID:    publicacion.ID,
-		GrupoID:          grupo.ID,
-		FechaPublicacion: time.Now(),
-		CreatedAt:        time.Now(),
+		ID:               id,
+		ProgramacionID:   trabajo.ProgramacionID,
+		PublicacionID:    trabajo.PublicacionID,
+		GrupoID:          trabajo.GrupoID,
+		UserID:           trabajo.UserID,
+		FechaProgramada:  trabajo.FechaProgramada,
+		FechaPublicacion: s.clock.Now(),
+		Intentos:         trabajo.Intentos,
+		Manual:           trabajo.Manual,
+		VarianteID:       trabajo.VarianteID,
+		CreatedAt:        s.clock.Now(),
 	}
 
-	if err != nil {
+	var omitido *errorOmitido
+	switch {
+	case errors.As(err, &omitido):
+		historial.Estado = "omitida"
+		historial.MensajeError = omitido.motivo
+	case errors.Is(err, errPublicacionInterrumpida):
+		historial.Estado = "interrumpida"
+		historial.MensajeError = err.Error()
+	case err != nil:
 		historial.Estado = "fallida"
 		historial.MensajeError = err.Error()
-		log.Printf("Error publicando en grupo %s: %v", grupo.Nombre, err)
-	} else {
+	default:
 		historial.Estado = "exitosa"
-		historial.FacebookPostID = response.ID
-		log.Printf("Publicación exitosa en grupo %s: %s", grupo.Nombre, response.ID)
+		historial.FacebookPostID = idPublicacionFeed(response)
 	}
 
-	// Guardar historial
-	collection := database.Collection("historial_publicaciones")
-	_, err = collection.InsertOne(context.Background(), historial)
-	if err != nil {
-		log.Printf("Error guardando historial: %v", err)
+	var errGuardar error
+	if reemplazar.IsZero() {
+		errGuardar = s.historial.Insert(historial)
+	} else {
+		errGuardar = s.historial.Replace(historial)
 	}
+	if errGuardar != nil {
+		log.Printf("Error guardando historial: %v", errGuardar)
+	}
+	return id
 }
 
 // buildMessage construye el mensaje de publicación
@@ -252,34 +931,17 @@ func (s *SchedulerService) buildMessage(publicacion Publicacion) string {
 	return message
 }
 
+// getProgramacion obtiene una programación por ID
+func (s *SchedulerService) getProgramacion(id primitive.ObjectID) (*ProgramacionPublicacion, error) {
+	return s.programaciones.Get(id)
+}
+
 // getPublicacion obtiene una publicación por ID
 func (s *SchedulerService) getPublicacion(id primitive.ObjectID) (*Publicacion, error) {
-	collection := database.Collection("publicaciones")
-
-	var publicacion Publicacion
-	err := collection.FindOne(context.Background(), bson.M{"_id": id}).Decode(&publicacion)
-	if err != nil {
-		return nil, err
-	}
-
-	return &publicacion, nil
+	return s.publicaciones.Get(id)
 }
 
 // getGruposObjetivo obtiene los grupos objetivo por sus IDs
 func (s *SchedulerService) getGruposObjetivo(ids []primitive.ObjectID) ([]GrupoFacebook, error) {
-	collection := database.Collection("grupos")
-
-	filter := bson.M{"_id": bson.M{"$in": ids}}
-	cursor, err := collection.Find(context.Background(), filter)
-	if err != nil {
-		return nil, err
-	}
-	defer cursor.Close(context.Background())
-
-	var grupos []GrupoFacebook
-	if err := cursor.All(context.Background(), &grupos); err != nil {
-		return nil, err
-	}
-
-	return grupos, nil
+	return s.grupos.FindByIDs(ids)
 }
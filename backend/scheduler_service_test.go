@@ -0,0 +1,640 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// simulacion arma un scheduler sobre repositorios en memoria, un reloj
+// simulado y un Facebook que registra las publicaciones
+type simulacion struct {
+	t              *testing.T
+	reloj          *relojSimulado
+	programaciones *programacionesMemoria
+	publicaciones  *publicacionesMemoria
+	productos      *productosMemoria
+	ledger         *ledgerMemoria
+	cola           *colaMemoria
+	historial      *historialMemoria
+	facebook       *facebookSimulado
+	eventos        *eventosMemoria
+	usuarios       *usuariosMemoria
+	notificaciones *notificacionesMemoria
+	scheduler      *SchedulerService
+	usuario        Usuario
+	publicacion    Publicacion
+	grupos         []GrupoFacebook
+}
+
+func nuevaSimulacion(t *testing.T, inicio time.Time, grupos int) *simulacion {
+	// Sin espaciado ni límites anti-spam: las pruebas verifican solo los horarios
+	for _, clave := range []string{
+		"SCHEDULER_ESPACIADO_MIN_SEGUNDOS",
+		"SCHEDULER_ESPACIADO_MAX_SEGUNDOS",
+		"SCHEDULER_INTERVALO_GRUPO_MINUTOS",
+		"SCHEDULER_MAXIMO_DIARIO_GRUPO",
+		"SCHEDULER_MAXIMO_DIARIO_CUENTA",
+	} {
+		t.Setenv(clave, "0")
+	}
+	t.Setenv("SCHEDULER_BACKOFF_SEGUNDOS", "60")
+	t.Setenv("DEFAULT_TIMEZONE", "UTC")
+
+	reloj := &relojSimulado{now: inicio}
+	sim := &simulacion{
+		t:              t,
+		reloj:          reloj,
+		programaciones: &programacionesMemoria{datos: make(map[primitive.ObjectID]ProgramacionPublicacion)},
+		ledger:         &ledgerMemoria{clock: reloj},
+		cola:           &colaMemoria{clock: reloj, lease: jobLease},
+		historial:      &historialMemoria{},
+		facebook:       &facebookSimulado{clock: reloj},
+		eventos:        &eventosMemoria{},
+		productos:      &productosMemoria{datos: make(map[primitive.ObjectID]Producto)},
+		notificaciones: &notificacionesMemoria{},
+		usuario: Usuario{
+			ID:                  primitive.NewObjectID(),
+			FacebookAccessToken: "token",
+			TokenExpiracion:     inicio.AddDate(1, 0, 0),
+		},
+		publicacion: Publicacion{
+			ID:          primitive.NewObjectID(),
+			Titulo:      "Oferta",
+			Descripcion: "Descripción",
+		},
+	}
+
+	datosGrupos := make(map[primitive.ObjectID]GrupoFacebook)
+	for i := 0; i < grupos; i++ {
+		grupo := GrupoFacebook{ID: primitive.NewObjectID(), FacebookID: primitive.NewObjectID().Hex(), Nombre: "Grupo"}
+		sim.grupos = append(sim.grupos, grupo)
+		datosGrupos[grupo.ID] = grupo
+	}
+
+	sim.publicaciones = &publicacionesMemoria{datos: map[primitive.ObjectID]Publicacion{sim.publicacion.ID: sim.publicacion}}
+	sim.usuarios = &usuariosMemoria{datos: map[string]Usuario{sim.usuario.ID.Hex(): sim.usuario}}
+	sim.scheduler = NewSchedulerServiceWith(SchedulerDeps{
+		Clock:          reloj,
+		Usuarios:       sim.usuarios,
+		Facebook:       sim.facebook,
+		Lock:           lockMemoria{},
+		Queue:          sim.cola,
+		Ledger:         sim.ledger,
+		Programaciones: sim.programaciones,
+		Publicaciones:  sim.publicaciones,
+		Productos:      sim.productos,
+		Grupos:         &gruposMemoria{datos: datosGrupos},
+		Historial:      sim.historial,
+		Eventos:        sim.eventos,
+		Notificaciones: sim.notificaciones,
+	})
+
+	return sim
+}
+
+// programar guarda la programación apuntando a la publicación y los grupos
+// de la simulación
+func (sim *simulacion) programar(prog ProgramacionPublicacion) primitive.ObjectID {
+	prog.ID = primitive.NewObjectID()
+	prog.UserID = sim.usuario.ID
+	prog.PublicacionID = sim.publicacion.ID
+	prog.Estado = "activa"
+	for _, grupo := range sim.grupos {
+		prog.GruposObjetivo = append(prog.GruposObjetivo, grupo.ID)
+	}
+
+	sim.programaciones.datos[prog.ID] = prog
+	return prog.ID
+}
+
+// avanzar simula un tick del scheduler por minuto hasta la fecha indicada
+// (excluida); tras cada tick los workers vacían la cola
+func (sim *simulacion) avanzar(hasta time.Time) {
+	for sim.reloj.Now().Before(hasta) {
+		sim.scheduler.processPendingPublications()
+		sim.vaciarCola()
+		sim.reloj.Advance(time.Minute)
+	}
+}
+
+func (sim *simulacion) vaciarCola() {
+	for {
+		trabajo, err := sim.cola.Claim("prueba")
+		if err != nil {
+			sim.t.Fatalf("Claim: %v", err)
+		}
+		if trabajo == nil {
+			return
+		}
+		sim.scheduler.processJob(*trabajo)
+	}
+}
+
+// fechasPublicadas devuelve las fechas de las publicaciones en el grupo, en loc
+func (sim *simulacion) fechasPublicadas(grupo GrupoFacebook, loc *time.Location) []string {
+	var fechas []string
+	for _, p := range sim.facebook.publicaciones {
+		if p.grupo == grupo.FacebookID {
+			fechas = append(fechas, p.fecha.In(loc).Format("2006-01-02 15:04"))
+		}
+	}
+	return fechas
+}
+
+func compararFechas(t *testing.T, obtenidas, esperadas []string) {
+	t.Helper()
+
+	if len(obtenidas) != len(esperadas) {
+		t.Fatalf("se publicó %d veces, se esperaban %d\nobtenidas: %v\nesperadas: %v", len(obtenidas), len(esperadas), obtenidas, esperadas)
+	}
+	for i := range esperadas {
+		if obtenidas[i] != esperadas[i] {
+			t.Errorf("publicación %d: obtenida %s, esperada %s", i+1, obtenidas[i], esperadas[i])
+		}
+	}
+}
+
+func TestSchedulerSimulacion(t *testing.T) {
+	// Lunes 3 de marzo de 2025; en Nueva York el horario de verano empieza el domingo 9
+	inicio := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+	fin := func(dias int, hora int) *time.Time {
+		f := inicio.AddDate(0, 0, dias).Add(time.Duration(hora) * time.Hour)
+		return &f
+	}
+
+	casos := []struct {
+		nombre     string
+		prog       ProgramacionPublicacion
+		zona       string
+		dias       int
+		grupos     int
+		esperadas  []string
+		estadoFin  string
+		realizadas int
+	}{
+		{
+			nombre:    "diaria a las 09:00",
+			prog:      ProgramacionPublicacion{Frecuencia: "diaria", Horarios: []ConfiguracionHorario{{Hora: 9}}},
+			dias:      3,
+			grupos:    1,
+			esperadas: []string{"2025-03-03 09:00", "2025-03-04 09:00", "2025-03-05 09:00"},
+			estadoFin: "activa",
+		},
+		{
+			// Así guarda las programaciones el formulario, que no envía frecuencia
+			nombre:    "sin frecuencia es diaria",
+			prog:      ProgramacionPublicacion{Horarios: []ConfiguracionHorario{{Hora: 9}}},
+			dias:      2,
+			grupos:    1,
+			esperadas: []string{"2025-03-03 09:00", "2025-03-04 09:00"},
+			estadoFin: "activa",
+		},
+		{
+			nombre: "varios horarios por día",
+			prog:   ProgramacionPublicacion{Frecuencia: "diaria", Horarios: []ConfiguracionHorario{{Hora: 18, Minuto: 30}, {Hora: 9}}},
+			dias:   2,
+			grupos: 2,
+			esperadas: []string{
+				"2025-03-03 09:00", "2025-03-03 18:30",
+				"2025-03-04 09:00", "2025-03-04 18:30",
+			},
+			estadoFin: "activa",
+		},
+		{
+			nombre:    "cada 2 días",
+			prog:      ProgramacionPublicacion{Frecuencia: "cada_2_dias", Horarios: []ConfiguracionHorario{{Hora: 10}}},
+			dias:      6,
+			grupos:    1,
+			esperadas: []string{"2025-03-03 10:00", "2025-03-05 10:00", "2025-03-07 10:00"},
+			estadoFin: "activa",
+		},
+		{
+			nombre:    "semanal",
+			prog:      ProgramacionPublicacion{Frecuencia: "semanal", Horarios: []ConfiguracionHorario{{Hora: 8}}},
+			dias:      15,
+			grupos:    1,
+			esperadas: []string{"2025-03-03 08:00", "2025-03-10 08:00", "2025-03-17 08:00"},
+			estadoFin: "activa",
+		},
+		{
+			nombre:    "cron de lunes a viernes",
+			prog:      ProgramacionPublicacion{Frecuencia: "personalizada", Recurrencia: "0 9 * * 1-5"},
+			dias:      7,
+			grupos:    1,
+			esperadas: []string{"2025-03-03 09:00", "2025-03-04 09:00", "2025-03-05 09:00", "2025-03-06 09:00", "2025-03-07 09:00"},
+			estadoFin: "activa",
+		},
+		{
+			nombre:     "cantidad de publicaciones",
+			prog:       ProgramacionPublicacion{Frecuencia: "diaria", Horarios: []ConfiguracionHorario{{Hora: 9}}, CantidadPublicaciones: 2},
+			dias:       5,
+			grupos:     2,
+			esperadas:  []string{"2025-03-03 09:00", "2025-03-04 09:00"},
+			estadoFin:  "completada",
+			realizadas: 2,
+		},
+		{
+			nombre:     "fecha de fin",
+			prog:       ProgramacionPublicacion{Frecuencia: "diaria", Horarios: []ConfiguracionHorario{{Hora: 9}}, FechaFin: fin(2, 12)},
+			dias:       5,
+			grupos:     1,
+			esperadas:  []string{"2025-03-03 09:00", "2025-03-04 09:00", "2025-03-05 09:00"},
+			estadoFin:  "completada",
+			realizadas: 3,
+		},
+		{
+			nombre:    "horario local al cambiar al horario de verano",
+			prog:      ProgramacionPublicacion{Frecuencia: "diaria", Horarios: []ConfiguracionHorario{{Hora: 9}}},
+			zona:      "America/New_York",
+			dias:      9,
+			grupos:    1,
+			esperadas: []string{"2025-03-03 09:00", "2025-03-04 09:00", "2025-03-05 09:00", "2025-03-06 09:00", "2025-03-07 09:00", "2025-03-08 09:00", "2025-03-09 09:00", "2025-03-10 09:00", "2025-03-11 09:00"},
+			estadoFin: "activa",
+		},
+		{
+			// Las 02:30 del 9 de marzo no existen en Nueva York: se publica al
+			// terminar el salto (03:00) y no una hora antes
+			nombre:    "horario dentro del salto al horario de verano",
+			prog:      ProgramacionPublicacion{Frecuencia: "diaria", Horarios: []ConfiguracionHorario{{Hora: 2, Minuto: 30}}},
+			zona:      "America/New_York",
+			dias:      8,
+			grupos:    1,
+			esperadas: []string{"2025-03-03 02:30", "2025-03-04 02:30", "2025-03-05 02:30", "2025-03-06 02:30", "2025-03-07 02:30", "2025-03-08 02:30", "2025-03-09 03:00", "2025-03-10 02:30"},
+			estadoFin: "activa",
+		},
+		{
+			nombre:    "cron dentro del salto al horario de verano",
+			prog:      ProgramacionPublicacion{Frecuencia: "personalizada", Recurrencia: "30 2 * * 0"},
+			zona:      "America/New_York",
+			dias:      14,
+			grupos:    1,
+			esperadas: []string{"2025-03-09 03:00", "2025-03-16 02:30"},
+			estadoFin: "activa",
+		},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			loc := time.UTC
+			if caso.zona != "" {
+				var err error
+				if loc, err = time.LoadLocation(caso.zona); err != nil {
+					t.Skipf("zona horaria %s no disponible: %v", caso.zona, err)
+				}
+			}
+			desde := time.Date(inicio.Year(), inicio.Month(), inicio.Day(), 0, 0, 0, 0, loc)
+
+			sim := nuevaSimulacion(t, desde, caso.grupos)
+			prog := caso.prog
+			prog.FechaInicio = desde
+			prog.ZonaHoraria = caso.zona
+			id := sim.programar(prog)
+
+			sim.avanzar(desde.AddDate(0, 0, caso.dias))
+
+			for _, grupo := range sim.grupos {
+				compararFechas(t, sim.fechasPublicadas(grupo, loc), caso.esperadas)
+			}
+
+			if estado := sim.programaciones.datos[id].Estado; estado != caso.estadoFin {
+				t.Errorf("estado final %q, se esperaba %q", estado, caso.estadoFin)
+			}
+
+			if caso.realizadas > 0 {
+				conteos, _ := sim.ledger.CountSuccessful([]primitive.ObjectID{id})
+				if conteos[id] != caso.realizadas {
+					t.Errorf("ejecuciones exitosas %d, se esperaban %d", conteos[id], caso.realizadas)
+				}
+			}
+		})
+	}
+}
+
+func TestSchedulerReintentaErroresTransitorios(t *testing.T) {
+	inicio := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+	sim := nuevaSimulacion(t, inicio, 1)
+	sim.facebook.errores = []error{errors.New("timeout"), errors.New("timeout")}
+	id := sim.programar(ProgramacionPublicacion{
+		Frecuencia:            "diaria",
+		Horarios:              []ConfiguracionHorario{{Hora: 9}},
+		CantidadPublicaciones: 1,
+		FechaInicio:           inicio,
+	})
+
+	sim.avanzar(inicio.AddDate(0, 0, 2))
+
+	fechas := sim.fechasPublicadas(sim.grupos[0], time.UTC)
+	if len(fechas) != 1 {
+		t.Fatalf("se publicó %d veces, se esperaba 1: %v", len(fechas), fechas)
+	}
+	// Dos reintentos con backoff de hasta 60s y 120s
+	if fechas[0] < "2025-03-03 09:01" || fechas[0] > "2025-03-03 09:04" {
+		t.Errorf("la publicación reintentada salió a las %s", fechas[0])
+	}
+
+	if estado := sim.programaciones.datos[id].Estado; estado != "completada" {
+		t.Errorf("estado final %q, se esperaba completada", estado)
+	}
+	if trabajo := sim.cola.trabajos[0]; trabajo.Estado != TrabajoCompletado || trabajo.Intentos != 3 {
+		t.Errorf("trabajo %s con %d intentos, se esperaba completado con 3", trabajo.Estado, trabajo.Intentos)
+	}
+}
+
+func TestSchedulerReintentoRespetaHorasSilencio(t *testing.T) {
+	inicio := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+	sim := nuevaSimulacion(t, inicio, 1)
+	sim.facebook.errores = []error{errors.New("timeout")}
+	// El reintento de las 09:00 vence dentro del silencio que empieza a las 09:01
+	sim.programar(ProgramacionPublicacion{
+		Frecuencia:  "diaria",
+		Horarios:    []ConfiguracionHorario{{Hora: 9}},
+		FechaInicio: inicio,
+		Restricciones: RestriccionesPublicacion{
+			HorasSilencio: []HorarioSilencio{{Desde: ConfiguracionHorario{Hora: 9, Minuto: 1}, Hasta: ConfiguracionHorario{Hora: 12}}},
+		},
+	})
+
+	sim.avanzar(inicio.AddDate(0, 0, 2))
+
+	compararFechas(t, sim.fechasPublicadas(sim.grupos[0], time.UTC), []string{
+		"2025-03-03 12:00", "2025-03-04 09:00",
+	})
+}
+
+func TestSchedulerReintentoReservaCantidad(t *testing.T) {
+	inicio := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+	sim := nuevaSimulacion(t, inicio, 1)
+	sim.facebook.errores = []error{errors.New("timeout")}
+	// Un horario por minuto: el de las 09:00 sigue esperando su reintento
+	// cuando vence el de las 09:01
+	id := sim.programar(ProgramacionPublicacion{
+		Frecuencia:            "personalizada",
+		Recurrencia:           "* 9 * * *",
+		CantidadPublicaciones: 1,
+		FechaInicio:           inicio,
+	})
+
+	sim.avanzar(inicio.AddDate(0, 0, 1))
+
+	if fechas := sim.fechasPublicadas(sim.grupos[0], time.UTC); len(fechas) != 1 {
+		t.Fatalf("se publicó %d veces, se esperaba 1: %v", len(fechas), fechas)
+	}
+	if conteos, _ := sim.ledger.CountSuccessful([]primitive.ObjectID{id}); conteos[id] != 1 {
+		t.Errorf("ejecuciones exitosas %d, se esperaba 1", conteos[id])
+	}
+	if estado := sim.programaciones.datos[id].Estado; estado != "completada" {
+		t.Errorf("estado final %q, se esperaba completada", estado)
+	}
+}
+
+func TestSchedulerPausaNoRecuperaHorarios(t *testing.T) {
+	inicio := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+	sim := nuevaSimulacion(t, inicio, 1)
+	id := sim.programar(ProgramacionPublicacion{
+		Frecuencia:  "diaria",
+		Horarios:    []ConfiguracionHorario{{Hora: 9}},
+		FechaInicio: inicio,
+	})
+
+	sim.avanzar(inicio.AddDate(0, 0, 1))
+	if _, err := sim.scheduler.Pause(id); err != nil {
+		t.Fatalf("Pause: %v", err)
+	}
+	sim.avanzar(inicio.AddDate(0, 0, 3))
+	if _, err := sim.scheduler.Resume(id); err != nil {
+		t.Fatalf("Resume: %v", err)
+	}
+	sim.avanzar(inicio.AddDate(0, 0, 5))
+
+	compararFechas(t, sim.fechasPublicadas(sim.grupos[0], time.UTC), []string{
+		"2025-03-03 09:00", "2025-03-06 09:00", "2025-03-07 09:00",
+	})
+}
+
+func TestSchedulerStop(t *testing.T) {
+	casos := []struct {
+		nombre        string
+		espera        time.Duration // Plazo del apagado
+		errores       []error
+		estadoAlParar string // Estado en el historial al volver de Stop
+		estadoFinal   string // Estado en el historial al terminar la publicación
+		estadoTrabajo string
+	}{
+		{
+			nombre: "espera la publicación en curso", espera: 5 * time.Second,
+			estadoAlParar: "exitosa", estadoFinal: "exitosa", estadoTrabajo: TrabajoCompletado,
+		},
+		{
+			nombre: "la publicación interrumpida que termina reemplaza al registro", espera: 50 * time.Millisecond,
+			estadoAlParar: "interrumpida", estadoFinal: "exitosa", estadoTrabajo: TrabajoCompletado,
+		},
+		{
+			nombre: "la publicación interrumpida que falla reemplaza al registro", espera: 50 * time.Millisecond,
+			errores:       []error{permanente(errors.New("permiso denegado"))},
+			estadoAlParar: "interrumpida", estadoFinal: "fallida", estadoTrabajo: TrabajoFallido,
+		},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			inicio := time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC)
+			sim := nuevaSimulacion(t, inicio, 1)
+			sim.scheduler.workers = 1
+			sim.facebook.errores = caso.errores
+
+			publicando := make(chan struct{})
+			liberar := make(chan struct{})
+			sim.facebook.antesDePublicar = func() {
+				close(publicando)
+				<-liberar
+			}
+
+			id := sim.programar(ProgramacionPublicacion{Frecuencia: "diaria", FechaInicio: inicio})
+			if _, err := sim.scheduler.RunNow(id); err != nil {
+				t.Fatalf("RunNow: %v", err)
+			}
+
+			sim.scheduler.Start()
+			<-publicando
+
+			// La publicación termina antes del plazo solo en el primer caso
+			interrumpida := caso.estadoAlParar == "interrumpida"
+			if !interrumpida {
+				time.AfterFunc(20*time.Millisecond, func() { close(liberar) })
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), caso.espera)
+			defer cancel()
+			sim.scheduler.Stop(ctx)
+
+			if len(sim.historial.registros) != 1 || sim.historial.registros[0].Estado != caso.estadoAlParar {
+				t.Fatalf("historial al detener %+v, se esperaba un registro %q", sim.historial.registros, caso.estadoAlParar)
+			}
+
+			if interrumpida {
+				close(liberar)
+			}
+			sim.scheduler.wg.Wait()
+
+			if len(sim.historial.registros) != 1 {
+				t.Fatalf("%d registros en el historial, se esperaba 1", len(sim.historial.registros))
+			}
+			if estado := sim.historial.registros[0].Estado; estado != caso.estadoFinal {
+				t.Errorf("estado en el historial %q, se esperaba %q", estado, caso.estadoFinal)
+			}
+			if estado := sim.cola.trabajos[0].Estado; estado != caso.estadoTrabajo {
+				t.Errorf("estado del trabajo %q, se esperaba %q", estado, caso.estadoTrabajo)
+			}
+		})
+	}
+}
+
+func TestSchedulerRenuevaLease(t *testing.T) {
+	inicio := time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC)
+	sim := nuevaSimulacion(t, inicio, 1)
+	sim.scheduler.renovarLease = time.Millisecond
+
+	id := sim.programar(ProgramacionPublicacion{Frecuencia: "diaria", FechaInicio: inicio})
+	if _, err := sim.scheduler.RunNow(id); err != nil {
+		t.Fatalf("RunNow: %v", err)
+	}
+	trabajo, err := sim.cola.Claim("prueba")
+	if err != nil || trabajo == nil {
+		t.Fatalf("Claim: %v, %v", trabajo, err)
+	}
+
+	// La publicación dura el doble del lease; otra instancia no debe retomarla
+	sim.facebook.antesDePublicar = func() {
+		for i := 0; i < 4; i++ {
+			sim.reloj.Advance(jobLease / 2)
+			esperarLease(t, sim, trabajo.ID)
+
+			if otro, err := sim.cola.Claim("otra"); err != nil || otro != nil {
+				t.Fatalf("otra instancia retomó el trabajo en curso: %v, %v", otro, err)
+			}
+		}
+	}
+	sim.scheduler.runJob(*trabajo)
+
+	if len(sim.historial.registros) != 1 || sim.historial.registros[0].Estado != "exitosa" {
+		t.Fatalf("historial %+v, se esperaba una publicación exitosa", sim.historial.registros)
+	}
+	if estado := sim.cola.trabajos[0].Estado; estado != TrabajoCompletado {
+		t.Errorf("estado del trabajo %q, se esperaba %q", estado, TrabajoCompletado)
+	}
+}
+
+// esperarLease espera a que el worker renueve el lease del trabajo más allá
+// de la hora simulada
+func esperarLease(t *testing.T, sim *simulacion, id primitive.ObjectID) {
+	t.Helper()
+
+	limite := time.Now().Add(time.Second)
+	for !sim.cola.leaseHasta(id).After(sim.reloj.Now().Add(jobLease / 2)) {
+		if time.Now().After(limite) {
+			t.Fatal("no se renovó el lease del trabajo")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSchedulerMetricasAlSerLider(t *testing.T) {
+	inicio := time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC)
+	sim := nuevaSimulacion(t, inicio, 1)
+	sim.historial.registros = append(sim.historial.registros, HistorialPublicacion{
+		ID:               primitive.NewObjectID(),
+		UserID:           sim.usuario.ID,
+		Estado:           "exitosa",
+		FacebookPostID:   "grupo_1",
+		VarianteID:       primitive.NewObjectID(),
+		FechaPublicacion: inicio.Add(-2 * time.Hour),
+	})
+
+	// Las métricas no esperan al primer intervalo tras obtener el liderazgo
+	consultando := make(chan struct{})
+	sim.facebook.antesDeMetricas = func() { close(consultando) }
+
+	sim.scheduler.Start()
+	select {
+	case <-consultando:
+	case <-time.After(time.Second):
+		t.Error("no se consultaron las métricas al ser elegida líder")
+	}
+	sim.scheduler.Stop(context.Background())
+
+	if metricas := sim.historial.registros[0].Metricas; metricas == nil || !metricas.ActualizadoEn.Equal(inicio) {
+		t.Errorf("métricas %+v, se esperaban actualizadas", metricas)
+	}
+}
+
+func TestSchedulerStatus(t *testing.T) {
+	inicio := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+	sim := nuevaSimulacion(t, inicio, 2)
+	sim.facebook.errores = []error{permanente(errors.New("permiso denegado"))}
+	diaria := sim.programar(ProgramacionPublicacion{Frecuencia: "diaria", Horarios: []ConfiguracionHorario{{Hora: 9}}, FechaInicio: inicio})
+	futura := sim.programar(ProgramacionPublicacion{Frecuencia: "diaria", Horarios: []ConfiguracionHorario{{Hora: 8}}, FechaInicio: inicio.AddDate(0, 0, 1)})
+
+	sim.avanzar(inicio.Add(12 * time.Hour))
+	sim.scheduler.Tick()
+
+	estado, err := sim.scheduler.Status()
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+
+	if estado.UltimoTick == nil || !estado.UltimoTick.Equal(sim.reloj.Now()) {
+		t.Errorf("último tick %v, se esperaba %v", estado.UltimoTick, sim.reloj.Now())
+	}
+	if estado.Ultimas24Horas["exitosa"] != 1 || estado.Ultimas24Horas["fallida"] != 1 {
+		t.Errorf("resultados de las últimas 24 horas %v, se esperaba 1 exitosa y 1 fallida", estado.Ultimas24Horas)
+	}
+	if estado.Cola[TrabajoPendiente] != 0 || estado.Cola[TrabajoEnProceso] != 0 {
+		t.Errorf("cola %v, se esperaba vacía", estado.Cola)
+	}
+
+	if len(estado.ProximasProgramaciones) != 2 {
+		t.Fatalf("próximas programaciones %v, se esperaban 2", estado.ProximasProgramaciones)
+	}
+	proximas := []struct {
+		id      primitive.ObjectID
+		horario string
+	}{
+		{futura, "2025-03-04 08:00"},
+		{diaria, "2025-03-04 09:00"},
+	}
+	for i, p := range proximas {
+		obtenida := estado.ProximasProgramaciones[i]
+		if obtenida.ProgramacionID != p.id || obtenida.Horario.Format("2006-01-02 15:04") != p.horario {
+			t.Errorf("próxima %d: %s a las %s, se esperaba %s a las %s", i+1, obtenida.ProgramacionID.Hex(), obtenida.Horario.Format("2006-01-02 15:04"), p.id.Hex(), p.horario)
+		}
+	}
+}
+
+func TestSchedulerPublicaImagenComoFoto(t *testing.T) {
+	inicio := time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC)
+	sim := nuevaSimulacion(t, inicio, 1)
+	sim.publicacion.ImagenURL = "https://example.com/oferta.jpg"
+	sim.publicaciones.datos[sim.publicacion.ID] = sim.publicacion
+
+	id := sim.programar(ProgramacionPublicacion{Frecuencia: "diaria", FechaInicio: inicio})
+	if _, err := sim.scheduler.RunNow(id); err != nil {
+		t.Fatalf("RunNow: %v", err)
+	}
+	sim.vaciarCola()
+
+	if len(sim.facebook.publicaciones) != 1 {
+		t.Fatalf("se publicó %d veces, se esperaba 1", len(sim.facebook.publicaciones))
+	}
+	if fotos := sim.facebook.publicaciones[0].fotos; len(fotos) != 1 || fotos[0].URL != sim.publicacion.ImagenURL {
+		t.Errorf("fotos publicadas %v, se esperaba %q", fotos, sim.publicacion.ImagenURL)
+	}
+
+	// Se guarda la publicación del feed y no el ID de la foto
+	if postID := sim.historial.registros[0].FacebookPostID; postID != sim.grupos[0].FacebookID+"_1" {
+		t.Errorf("post guardado en el historial %q", postID)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// renovacionTokensInterval es cada cuánto la instancia líder renueva los
+// tokens de Facebook próximos a vencer
+const renovacionTokensInterval = 6 * time.Hour
+
+// NotificacionTokenFacebook es el tipo de las notificaciones de tokens de
+// Facebook que no pudieron renovarse
+const NotificacionTokenFacebook = "token_facebook"
+
+// renewFacebookTokens intercambia por uno nuevo cada token de Facebook que
+// vence dentro de la anticipación configurada. Los errores transitorios se
+// reintentan en la siguiente renovación; si el token ya venció o Facebook lo
+// rechaza, se notifica al usuario que debe volver a conectar Facebook.
+func (s *SchedulerService) renewFacebookTokens() {
+	now := s.clock.Now()
+
+	usuarios, err := s.usuarios.FindExpiringFacebookTokens(now.Add(s.renovarTokens))
+	if err != nil {
+		log.Printf("Error obteniendo tokens de Facebook por vencer: %v", err)
+		return
+	}
+
+	for _, usuario := range usuarios {
+		if !now.Before(usuario.TokenExpiracion) {
+			s.notifyTokenNoRenovado(usuario, "El acceso a Facebook venció el %s. Las programaciones no se publicarán hasta que vuelvas a conectar Facebook.")
+			continue
+		}
+
+		// La renovación puede esperar a que baje el uso de la Graph API
+		if espera, _ := s.facebook.UsageWait(usuario.FacebookAccessToken, now); espera > 0 {
+			continue
+		}
+
+		token, err := s.facebook.RefreshLongLivedToken(usuario.FacebookAccessToken)
+		switch {
+		case errors.Is(err, ErrFacebookAppNoConfigurada):
+			log.Printf("No se renuevan los tokens de Facebook: %v", err)
+			return
+		case err != nil && esErrorTransitorio(err):
+			log.Printf("Error renovando el token de Facebook del usuario %s, se reintentará: %v", usuario.ID.Hex(), err)
+			continue
+		case err != nil:
+			log.Printf("Facebook rechazó la renovación del token del usuario %s: %v", usuario.ID.Hex(), err)
+			s.notifyTokenNoRenovado(usuario, "No se pudo renovar el acceso a Facebook, que vence el %s. Vuelve a conectar Facebook para que las programaciones sigan publicándose.")
+			// Un token que Facebook ya no acepta deja de usarse para publicar
+			if graphErr := comoGraphError(err); graphErr != nil && graphErr.IsTokenExpired() {
+				if err := s.usuarios.ExpireFacebookToken(usuario.ID.Hex(), now); err != nil {
+					log.Printf("Error marcando vencido el token del usuario %s: %v", usuario.ID.Hex(), err)
+				}
+			}
+			continue
+		}
+
+		expiracion := expiracionToken(token, now)
+		if err := s.usuarios.UpdateFacebookTokens(usuario.ID.Hex(), usuario.FacebookUserID, token.AccessToken, expiracion); err != nil {
+			log.Printf("Error guardando el token renovado del usuario %s: %v", usuario.ID.Hex(), err)
+			continue
+		}
+		log.Printf("Token de Facebook del usuario %s renovado hasta %s", usuario.ID.Hex(), expiracion.Format(time.RFC3339))
+	}
+}
+
+// notifyTokenNoRenovado avisa al usuario que su token de Facebook venció o
+// no pudo renovarse; mensaje recibe la fecha de vencimiento en la zona del
+// usuario. Se notifica una sola vez por token: la clave usa un hash del token
+// para no guardarlo en la notificación.
+func (s *SchedulerService) notifyTokenNoRenovado(usuario Usuario, mensaje string) {
+	loc, err := cargarZonaHoraria(usuario.ZonaHoraria)
+	if err != nil {
+		loc = zonaHorariaPorDefecto()
+	}
+
+	notificacion := Notificacion{
+		UserID:    usuario.ID,
+		Tipo:      NotificacionTokenFacebook,
+		Mensaje:   fmt.Sprintf(mensaje, usuario.TokenExpiracion.In(loc).Format("02/01/2006 15:04")),
+		Clave:     fmt.Sprintf("%s:%x", NotificacionTokenFacebook, sha256.Sum256([]byte(usuario.FacebookAccessToken))),
+		CreatedAt: s.clock.Now(),
+	}
+
+	creada, err := s.notificaciones.Notify(notificacion)
+	if err != nil {
+		log.Printf("Error notificando al usuario %s: %v", usuario.ID.Hex(), err)
+		return
+	}
+	if creada {
+		log.Printf("Se notificó al usuario %s que su token de Facebook no puede renovarse", usuario.ID.Hex())
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSchedulerRenuevaTokensFacebook(t *testing.T) {
+	inicio := time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC)
+	tokenRechazado := nuevoGraphError("error al renovar token", http.StatusBadRequest,
+		[]byte(`{"error":{"message":"Error validating access token","type":"OAuthException","code":190,"error_subcode":460}}`))
+
+	casos := []struct {
+		nombre         string
+		vence          time.Time
+		errores        []error
+		renovado       bool
+		venceFinal     time.Time // Vencimiento esperado si el token no se renueva
+		notificaciones int
+	}{
+		{nombre: "vence pronto", vence: inicio.AddDate(0, 0, 3), renovado: true},
+		{nombre: "lejos del vencimiento", vence: inicio.AddDate(0, 0, 30), venceFinal: inicio.AddDate(0, 0, 30)},
+		{nombre: "ya vencido", vence: inicio.Add(-time.Hour), venceFinal: inicio.Add(-time.Hour), notificaciones: 1},
+		// El token rechazado se marca vencido y no se vuelve a intentar renovarlo
+		{nombre: "Facebook rechaza el token", vence: inicio.AddDate(0, 0, 3), errores: []error{tokenRechazado}, venceFinal: inicio, notificaciones: 1},
+		{nombre: "error transitorio", vence: inicio.AddDate(0, 0, 3), errores: []error{errors.New("timeout"), errors.New("timeout")}, venceFinal: inicio.AddDate(0, 0, 3)},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			sim := nuevaSimulacion(t, inicio, 1)
+			sim.scheduler.renovarTokens = 7 * 24 * time.Hour
+			usuario := sim.usuarios.datos[sim.usuario.ID.Hex()]
+			usuario.TokenExpiracion = caso.vence
+			sim.usuarios.datos[usuario.ID.Hex()] = usuario
+			sim.facebook.erroresRenovacion = caso.errores
+
+			// La segunda renovación no repite la notificación
+			sim.scheduler.renewFacebookTokens()
+			sim.scheduler.renewFacebookTokens()
+
+			actual := sim.usuarios.datos[usuario.ID.Hex()]
+			if caso.renovado {
+				if actual.FacebookAccessToken != "renovado-1" || !actual.TokenExpiracion.Equal(inicio.Add(duracionTokenLargo)) {
+					t.Errorf("token %q vence el %s, se esperaba renovado por 60 días", actual.FacebookAccessToken, actual.TokenExpiracion)
+				}
+			} else if actual.FacebookAccessToken != "token" || !actual.TokenExpiracion.Equal(caso.venceFinal) {
+				t.Errorf("token %q vence el %s, se esperaba sin cambios", actual.FacebookAccessToken, actual.TokenExpiracion)
+			}
+
+			if len(sim.notificaciones.datos) != caso.notificaciones {
+				t.Fatalf("%d notificaciones, se esperaban %d: %+v", len(sim.notificaciones.datos), caso.notificaciones, sim.notificaciones.datos)
+			}
+			for _, n := range sim.notificaciones.datos {
+				if n.UserID != usuario.ID || n.Tipo != NotificacionTokenFacebook || !strings.Contains(n.Mensaje, caso.vence.Format("02/01/2006 15:04")) {
+					t.Errorf("notificación %+v", n)
+				}
+			}
+		})
+	}
+}
+
+func TestSchedulerRenuevaTokensAlSerLider(t *testing.T) {
+	inicio := time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC)
+	sim := nuevaSimulacion(t, inicio, 1)
+	sim.scheduler.renovarTokens = 7 * 24 * time.Hour
+	usuario := sim.usuarios.datos[sim.usuario.ID.Hex()]
+	usuario.TokenExpiracion = inicio.AddDate(0, 0, 3)
+	sim.usuarios.datos[usuario.ID.Hex()] = usuario
+
+	// La renovación no espera al primer intervalo tras obtener el liderazgo
+	renovando := make(chan struct{})
+	sim.facebook.antesDeRenovar = func() { close(renovando) }
+
+	sim.scheduler.Start()
+	select {
+	case <-renovando:
+	case <-time.After(time.Second):
+		t.Error("no se renovaron los tokens al ser elegida líder")
+	}
+	sim.scheduler.Stop(context.Background())
+
+	if actual := sim.usuarios.datos[usuario.ID.Hex()]; actual.FacebookAccessToken != "renovado-1" {
+		t.Errorf("token %q, se esperaba renovado", actual.FacebookAccessToken)
+	}
+}
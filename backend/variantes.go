@@ -0,0 +1,249 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// engagementInterval es cada cuánto la instancia líder actualiza la
+	// interacción de las publicaciones con variantes
+	engagementInterval = time.Hour
+
+	// engagementVentana es la antigüedad máxima de las publicaciones cuya
+	// interacción se sigue actualizando
+	engagementVentana = 7 * 24 * time.Hour
+
+	// engagementLote limita las publicaciones consultadas por ciclo
+	engagementLote = 200
+)
+
+// completarVariantes asigna un ID a las variantes nuevas de una publicación
+func completarVariantes(publicacion *Publicacion) {
+	for i := range publicacion.Variantes {
+		if publicacion.Variantes[i].ID.IsZero() {
+			publicacion.Variantes[i].ID = primitive.NewObjectID()
+		}
+	}
+}
+
+// aplicarVariante reemplaza el título y la descripción por los de la variante
+func aplicarVariante(publicacion Publicacion, varianteID primitive.ObjectID) Publicacion {
+	if varianteID.IsZero() {
+		return publicacion
+	}
+
+	for _, v := range publicacion.Variantes {
+		if v.ID == varianteID {
+			if v.Titulo != "" {
+				publicacion.Titulo = v.Titulo
+			}
+			if v.Descripcion != "" {
+				publicacion.Descripcion = v.Descripcion
+			}
+			break
+		}
+	}
+
+	return publicacion
+}
+
+// chooseVariantes elige la variante que recibe cada grupo: la ganadora si ya
+// se promovió una, o la siguiente en orden según las publicaciones previas de
+// esa publicación en el grupo. Devuelve nil si la publicación no tiene variantes.
+func (s *SchedulerService) chooseVariantes(publicacionID primitive.ObjectID, grupos []GrupoFacebook) (map[primitive.ObjectID]primitive.ObjectID, error) {
+	publicacion, err := s.getPublicacion(publicacionID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			// El trabajo fallará al ejecutarse con el motivo correspondiente
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(publicacion.Variantes) == 0 {
+		return nil, nil
+	}
+
+	elegidas := make(map[primitive.ObjectID]primitive.ObjectID, len(grupos))
+
+	for _, grupo := range grupos {
+		if ganadora, ok := publicacion.VariantesGanadoras[grupo.ID.Hex()]; ok && tieneVariante(*publicacion, ganadora) {
+			elegidas[grupo.ID] = ganadora
+			continue
+		}
+
+		previas, err := s.historial.CountVariantes(publicacionID, grupo.ID)
+		if err != nil {
+			return nil, err
+		}
+		elegidas[grupo.ID] = publicacion.Variantes[previas%len(publicacion.Variantes)].ID
+	}
+
+	return elegidas, nil
+}
+
+// tieneVariante indica si la variante sigue existiendo en la publicación
+func tieneVariante(publicacion Publicacion, varianteID primitive.ObjectID) bool {
+	for _, v := range publicacion.Variantes {
+		if v.ID == varianteID {
+			return true
+		}
+	}
+	return false
+}
+
+// collectEngagement actualiza la interacción de las publicaciones recientes
+// con variantes y promueve las variantes ganadoras de las publicaciones que
+// lo tienen activado
+func (s *SchedulerService) collectEngagement() {
+	now := s.clock.Now()
+
+	registros, err := s.historial.PendingEngagement(now.Add(-engagementVentana), now.Add(-engagementInterval), engagementLote)
+	if err != nil {
+		log.Printf("Error obteniendo publicaciones para métricas: %v", err)
+		return
+	}
+
+	tokens := make(map[primitive.ObjectID]string)
+	publicaciones := make(map[primitive.ObjectID]bool)
+	for _, registro := range registros {
+		token, ok := tokens[registro.UserID]
+		if !ok {
+			if usuario, err := s.usuarios.GetUserByID(registro.UserID.Hex()); err == nil && now.Before(usuario.TokenExpiracion) {
+				token = usuario.FacebookAccessToken
+			}
+			tokens[registro.UserID] = token
+		}
+		if token == "" {
+			continue
+		}
+		// La interacción se vuelve a consultar más tarde si la cuota está por agotarse
+		if espera, _ := s.facebook.UsageWait(token, now); espera > 0 {
+			continue
+		}
+
+		engagement, err := s.facebook.GetPostEngagement(token, registro.FacebookPostID)
+		if err != nil {
+			log.Printf("Error obteniendo interacción de %s: %v", registro.FacebookPostID, err)
+			continue
+		}
+
+		metricas := MetricasPublicacion{
+			Reacciones:    engagement.Reactions.Summary.TotalCount,
+			Comentarios:   engagement.Comments.Summary.TotalCount,
+			Compartidos:   engagement.Shares.Count,
+			ActualizadoEn: now,
+		}
+		if err := s.historial.SetMetricas(registro.ID, metricas); err != nil {
+			log.Printf("Error guardando interacción de %s: %v", registro.FacebookPostID, err)
+			continue
+		}
+		publicaciones[registro.PublicacionID] = true
+	}
+
+	for publicacionID := range publicaciones {
+		if err := s.promoteWinners(publicacionID); err != nil {
+			log.Printf("Error promoviendo variantes de %s: %v", publicacionID.Hex(), err)
+		}
+	}
+}
+
+// promoteWinners fija la variante ganadora de cada grupo cuando la
+// publicación lo pide y todas sus variantes tienen el mínimo de publicaciones
+func (s *SchedulerService) promoteWinners(publicacionID primitive.ObjectID) error {
+	publicacion, err := s.getPublicacion(publicacionID)
+	if err != nil {
+		return err
+	}
+	if !publicacion.PromoverGanadora || len(publicacion.Variantes) < 2 {
+		return nil
+	}
+
+	rendimientos, err := reporteVariantes(s.historial, *publicacion)
+	if err != nil {
+		return err
+	}
+
+	porGrupo := make(map[primitive.ObjectID][]RendimientoVariante)
+	for _, r := range rendimientos {
+		porGrupo[r.GrupoID] = append(porGrupo[r.GrupoID], r)
+	}
+
+	ganadoras := make(map[string]primitive.ObjectID)
+	for grupoID, resultados := range porGrupo {
+		if _, promovida := publicacion.VariantesGanadoras[grupoID.Hex()]; promovida {
+			continue
+		}
+		if len(resultados) < len(publicacion.Variantes) {
+			continue
+		}
+
+		suficientes := true
+		for _, r := range resultados {
+			if r.Publicaciones < s.minimoPromocion {
+				suficientes = false
+				break
+			}
+		}
+		if !suficientes {
+			continue
+		}
+
+		for _, r := range resultados {
+			if r.Ganadora {
+				ganadoras[grupoID.Hex()] = r.VarianteID
+				log.Printf("Variante %q promovida para la publicación %s en el grupo %s", r.Nombre, publicacionID.Hex(), grupoID.Hex())
+			}
+		}
+	}
+
+	if len(ganadoras) == 0 {
+		return nil
+	}
+
+	return s.publicaciones.SetVariantesGanadoras(publicacionID, ganadoras)
+}
+
+// reporteVariantes agrega la interacción de cada variante de la publicación
+// por grupo y marca la de mayor interacción promedio en cada grupo. Las
+// variantes que ya no tiene la publicación quedan fuera del reporte.
+func reporteVariantes(historial HistorialRepository, publicacion Publicacion) ([]RendimientoVariante, error) {
+	totales, err := historial.VariantTotals(publicacion.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	nombres := make(map[primitive.ObjectID]string, len(publicacion.Variantes))
+	for _, v := range publicacion.Variantes {
+		nombres[v.ID] = v.Nombre
+	}
+
+	rendimientos := []RendimientoVariante{}
+	for _, r := range totales {
+		if _, existe := nombres[r.VarianteID]; existe {
+			rendimientos = append(rendimientos, r)
+		}
+	}
+
+	mejores := make(map[primitive.ObjectID]int)
+	for i := range rendimientos {
+		r := &rendimientos[i]
+		r.Nombre = nombres[r.VarianteID]
+		if r.Publicaciones > 0 {
+			r.Interaccion = float64(r.Reacciones+r.Comentarios+r.Compartidos) / float64(r.Publicaciones)
+		}
+
+		mejor, ok := mejores[r.GrupoID]
+		if !ok || r.Interaccion > rendimientos[mejor].Interaccion {
+			mejores[r.GrupoID] = i
+		}
+	}
+	for _, i := range mejores {
+		rendimientos[i].Ganadora = true
+	}
+
+	return rendimientos, nil
+}
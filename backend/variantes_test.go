@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestPromocionIgnoraVariantesEliminadas(t *testing.T) {
+	inicio := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+	sim := nuevaSimulacion(t, inicio, 1)
+	sim.scheduler.minimoPromocion = 1
+
+	a, b, eliminada := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	publicacion := sim.publicacion
+	publicacion.PromoverGanadora = true
+	publicacion.Variantes = []VariantePublicacion{{ID: a, Nombre: "A"}, {ID: b, Nombre: "B"}}
+	sim.publicaciones.datos[publicacion.ID] = publicacion
+
+	// La variante eliminada tuvo la mayor interacción antes de quitarla
+	grupo := sim.grupos[0].ID
+	for variante, reacciones := range map[primitive.ObjectID]int{a: 1, b: 5, eliminada: 100} {
+		sim.historial.registros = append(sim.historial.registros, HistorialPublicacion{
+			ID:            primitive.NewObjectID(),
+			PublicacionID: publicacion.ID,
+			GrupoID:       grupo,
+			VarianteID:    variante,
+			Estado:        "exitosa",
+			Metricas:      &MetricasPublicacion{Reacciones: reacciones},
+		})
+	}
+
+	rendimientos, err := reporteVariantes(sim.historial, publicacion)
+	if err != nil {
+		t.Fatalf("reporteVariantes: %v", err)
+	}
+	if len(rendimientos) != 2 {
+		t.Fatalf("el reporte tiene %d variantes, se esperaban 2: %+v", len(rendimientos), rendimientos)
+	}
+	for _, r := range rendimientos {
+		if r.Ganadora != (r.VarianteID == b) {
+			t.Errorf("variante %q ganadora %v", r.Nombre, r.Ganadora)
+		}
+	}
+
+	if err := sim.scheduler.promoteWinners(publicacion.ID); err != nil {
+		t.Fatalf("promoteWinners: %v", err)
+	}
+	if ganadora := sim.publicaciones.datos[publicacion.ID].VariantesGanadoras[grupo.Hex()]; ganadora != b {
+		t.Errorf("variante promovida %s, se esperaba B (%s)", ganadora.Hex(), b.Hex())
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// zonaHorariaPorDefecto devuelve la zona usada cuando ni la programación ni el
+// usuario tienen una configurada (DEFAULT_TIMEZONE o la zona del servidor)
+func zonaHorariaPorDefecto() *time.Location {
+	nombre := os.Getenv("DEFAULT_TIMEZONE")
+	if nombre == "" {
+		return time.Local
+	}
+
+	loc, err := time.LoadLocation(nombre)
+	if err != nil {
+		log.Printf("DEFAULT_TIMEZONE inválida %q, usando la zona del servidor", nombre)
+		return time.Local
+	}
+
+	return loc
+}
+
+// cargarZonaHoraria valida un nombre de zona IANA (por ejemplo
+// "America/Havana"); el nombre vacío corresponde a la zona por defecto
+func cargarZonaHoraria(nombre string) (*time.Location, error) {
+	if nombre == "" {
+		return zonaHorariaPorDefecto(), nil
+	}
+
+	loc, err := time.LoadLocation(nombre)
+	if err != nil {
+		return nil, fmt.Errorf("zona horaria inválida %q", nombre)
+	}
+
+	return loc, nil
+}
+
+// zonaHorariaProgramacion resuelve la zona de una programación: la suya
+// propia, la de su usuario o la zona por defecto
+func zonaHorariaProgramacion(prog ProgramacionPublicacion, usuario *Usuario) *time.Location {
+	nombre := prog.ZonaHoraria
+	if nombre == "" && usuario != nil {
+		nombre = usuario.ZonaHoraria
+	}
+
+	loc, err := cargarZonaHoraria(nombre)
+	if err != nil {
+		log.Printf("Programación %s: %v, usando la zona por defecto", prog.ID.Hex(), err)
+		return zonaHorariaPorDefecto()
+	}
+
+	return loc
+}
+
+// programacionEnZona expresa las fechas de la programación en su zona horaria
+// para que la API las devuelva con el desplazamiento explícito
+func programacionEnZona(prog ProgramacionPublicacion, loc *time.Location) ProgramacionPublicacion {
+	prog.FechaInicio = prog.FechaInicio.In(loc)
+	if prog.FechaFin != nil {
+		fechaFin := prog.FechaFin.In(loc)
+		prog.FechaFin = &fechaFin
+	}
+	prog.CreatedAt = prog.CreatedAt.In(loc)
+	prog.UpdatedAt = prog.UpdatedAt.In(loc)
+	return prog
+}
+
+// resolverZonaHoraria resuelve la zona de una programación consultando,
+// si hace falta, la zona configurada por su usuario
+func resolverZonaHoraria(prog ProgramacionPublicacion) *time.Location {
+	if prog.ZonaHoraria != "" || prog.UserID.IsZero() {
+		return zonaHorariaProgramacion(prog, nil)
+	}
+
+	var usuario Usuario
+	err := database.Collection("usuarios").FindOne(context.Background(), bson.M{"_id": prog.UserID}).Decode(&usuario)
+	if err != nil {
+		return zonaHorariaProgramacion(prog, nil)
+	}
+
+	return zonaHorariaProgramacion(prog, &usuario)
+}
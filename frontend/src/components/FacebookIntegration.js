@@ -35,6 +35,16 @@ const FacebookIntegration = () => {
     }
   };
 
+  // El backend responde con este código cuando Facebook rechaza el token
+  // guardado; hay que volver a conectar Facebook, no iniciar sesión de nuevo
+  const esTokenFacebookExpirado = (error) =>
+    error.response?.data?.codigo === 'facebook_token_expirado';
+
+  const pedirReconexion = () => {
+    setFacebookStatus((estado) => ({ ...estado, valid: false }));
+    alert('El acceso a Facebook venció. Vuelve a conectar Facebook.');
+  };
+
   const loadGroups = async () => {
     setLoadingGroups(true);
     try {
@@ -42,6 +52,9 @@ const FacebookIntegration = () => {
       setGroups(response.data.groups || []);
     } catch (error) {
       console.error('Error loading groups:', error);
+      if (esTokenFacebookExpirado(error)) {
+        pedirReconexion();
+      }
     }
     setLoadingGroups(false);
   };
@@ -99,6 +112,10 @@ const FacebookIntegration = () => {
         
         alert('Publicación exitosa! ID: ' + response.data.post_id);
       } catch (error) {
+        if (esTokenFacebookExpirado(error)) {
+          pedirReconexion();
+          return;
+        }
         alert('Error en publicación: ' + (error.response?.data?.error || error.message));
       }
     }
@@ -70,6 +70,10 @@ const Programaciones = () => {
 
       if (editingProgramacion) {
         await programacionesApi.update(editingProgramacion.id, data);
+        // El estado solo cambia con las acciones de pausar y reanudar
+        if (formData.estado !== editingProgramacion.estado) {
+          await cambiarEstado(editingProgramacion);
+        }
       } else {
         await programacionesApi.create(data);
       }
@@ -81,6 +85,21 @@ const Programaciones = () => {
     }
   };
 
+  const cambiarEstado = (programacion) => (
+    programacion.estado === 'activa'
+      ? programacionesApi.pausar(programacion.id)
+      : programacionesApi.reanudar(programacion.id)
+  );
+
+  const handleToggleEstado = async (programacion) => {
+    try {
+      await cambiarEstado(programacion);
+      loadProgramaciones();
+    } catch (error) {
+      console.error('Error changing programacion state:', error);
+    }
+  };
+
   const handleDelete = async (id) => {
     if (window.confirm('¿Estás seguro de que deseas eliminar esta programación?')) {
       try {
@@ -238,6 +257,7 @@ const Programaciones = () => {
                   <Edit size={16} />
                 </button>
                 <button
+                  onClick={() => handleToggleEstado(programacion)}
                   className="p-2 text-gray-400 hover:text-green-500"
                   title={programacion.estado === 'activa' ? 'Pausar' : 'Activar'}
                 >
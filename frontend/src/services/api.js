@@ -45,7 +45,9 @@ export const programacionesApi = {
   getAll: () => api.get('/programaciones'),
   create: (programacion) => api.post('/programaciones', programacion),
   update: (id, programacion) => api.put(`/programaciones/${id}`, programacion),
-  delete: (id) => api.delete(`/programaciones/${id}`)
+  delete: (id) => api.delete(`/programaciones/${id}`),
+  pausar: (id) => api.post(`/programaciones/${id}/pausar`),
+  reanudar: (id) => api.post(`/programaciones/${id}/reanudar`)
 };
 
 export default api;